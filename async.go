@@ -0,0 +1,78 @@
+package soap
+
+import "context"
+
+// A Future holds the result of a call made by CallAsync.
+type Future struct {
+	done chan struct{}
+	err  error
+}
+
+func newFuture() *Future {
+	return &Future{done: make(chan struct{})}
+}
+
+func (f *Future) complete(err error) {
+	f.err = err
+	close(f.done)
+}
+
+// Done returns a channel that is closed when the call completes.
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Err returns the error of a completed call. It must not be called
+// before the channel returned by Done is closed.
+func (f *Future) Err() error {
+	return f.err
+}
+
+// Wait waits for the call to complete and returns its error. If ctx
+// is done first, Wait returns ctx.Err(); the call continues.
+func (f *Future) Wait(ctx context.Context) error {
+	select {
+	case <-f.done:
+		return f.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// CallAsync starts a call in a new goroutine, and returns a Future
+// that completes when Call would have returned. resp must not be used
+// until then. If c.MaxAsync calls are already in progress, CallAsync
+// blocks until one of them completes, so that a loop issuing many
+// calls does not outpace the service. If ctx is done while waiting,
+// the returned Future has already completed with ctx.Err().
+func (c *Client) CallAsync(ctx context.Context, action string, req, resp interface{}, opts ...CallOption) *Future {
+	f := newFuture()
+	sem := c.asyncSlots()
+	if sem != nil {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			f.complete(ctx.Err())
+			return f
+		}
+	}
+	go func() {
+		err := c.Call(ctx, action, req, resp, opts...)
+		if sem != nil {
+			<-sem
+		}
+		f.complete(err)
+	}()
+	return f
+}
+
+// asyncSlots returns the semaphore bounding calls made by CallAsync,
+// or nil if they are not bounded.
+func (c *Client) asyncSlots() chan struct{} {
+	c.asyncOnce.Do(func() {
+		if c.MaxAsync > 0 {
+			c.asyncSem = make(chan struct{}, c.MaxAsync)
+		}
+	})
+	return c.asyncSem
+}
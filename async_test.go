@@ -0,0 +1,117 @@
+package soap
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReceiver(t *testing.T) {
+	recv := new(Receiver)
+	rs := httptest.NewServer(recv)
+	defer rs.Close()
+	recv.Address = rs.URL
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		wsa, err := readAddressing(data)
+		if err != nil {
+			t.Error(err)
+		}
+		if wsa.Action != "Echo" || wsa.MessageID == "" || wsa.ReplyTo == nil {
+			t.Errorf("missing addressing headers: %s", data)
+		}
+		if want := "http://" + r.Host; wsa.To != want {
+			t.Errorf("request addressed to %q, want %q", wsa.To, want)
+		}
+		w.WriteHeader(http.StatusAccepted)
+		go func() {
+			reply := `<soapenv:Envelope xmlns:soapenv="` + NsSoapEnv + `">
+<soapenv:Header><wsa:RelatesTo xmlns:wsa="` + NsWSA + `">` + wsa.MessageID + `</wsa:RelatesTo></soapenv:Header>
+<soapenv:Body><t:EchoResponse xmlns:t="urn:test"><value>later</value></t:EchoResponse></soapenv:Body>
+</soapenv:Envelope>`
+			rsp, err := http.Post(wsa.ReplyTo.Address, "text/xml", strings.NewReader(reply))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			rsp.Body.Close()
+			if rsp.StatusCode != http.StatusAccepted {
+				t.Errorf("receiver responded %s", rsp.Status)
+			}
+		}()
+	}))
+	defer srv.Close()
+
+	c := &Client{Failover: NewFailover(srv.URL)}
+	var out echoResponse
+	f := recv.Call(context.Background(), c, "Echo", echoRequest{Value: "now"}, &out)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := f.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if out.Value != "later" {
+		t.Errorf("got %q, want %q", out.Value, "later")
+	}
+
+	rsp, err := http.Post(rs.URL, "text/xml", strings.NewReader(`<Envelope><Body/></Envelope>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusNotFound {
+		t.Errorf("unrelated message: got %s, want 404", rsp.Status)
+	}
+}
+
+func TestCorrelator(t *testing.T) {
+	c := &Correlator{Timeout: 50 * time.Millisecond}
+	var out echoResponse
+	id, f := c.Track(&out)
+	_, expired := c.Track(nil)
+	if c.Len() != 2 {
+		t.Fatalf("%d outstanding requests, want 2", c.Len())
+	}
+
+	reply := `<soapenv:Envelope xmlns:soapenv="` + NsSoapEnv + `">
+<soapenv:Header><wsa:RelatesTo xmlns:wsa="` + NsWSA + `">` + id + `</wsa:RelatesTo></soapenv:Header>
+<soapenv:Body><t:EchoResponse xmlns:t="urn:test"><value>hi</value></t:EchoResponse></soapenv:Body>
+</soapenv:Envelope>`
+	if ok, err := c.Deliver([]byte(reply)); !ok || err != nil {
+		t.Fatalf("Deliver: %v, %v", ok, err)
+	}
+	if err := f.Wait(context.Background()); err != nil || out.Value != "hi" {
+		t.Errorf("got %q, %v", out.Value, err)
+	}
+	if ok, _ := c.Deliver([]byte(reply)); ok {
+		t.Error("reply delivered twice")
+	}
+	if err := expired.Wait(context.Background()); err != ErrNoReply {
+		t.Errorf("got %v, want ErrNoReply", err)
+	}
+	if c.Len() != 0 {
+		t.Errorf("%d outstanding requests, want 0", c.Len())
+	}
+}
+
+func TestCorrelatorFlattener(t *testing.T) {
+	c := &Correlator{Flattener: &Flattener{DuplicateIDs: DuplicateFirst}}
+	var out echoResponse
+	id, f := c.Track(&out)
+	reply := `<soapenv:Envelope xmlns:soapenv="` + NsSoapEnv + `">
+<soapenv:Header><wsa:RelatesTo xmlns:wsa="` + NsWSA + `">` + id + `</wsa:RelatesTo></soapenv:Header>
+<soapenv:Body><t:EchoResponse xmlns:t="urn:test"><value href="#id0"/></t:EchoResponse>
+<multiRef id="id0">first</multiRef><multiRef id="id0">second</multiRef></soapenv:Body>
+</soapenv:Envelope>`
+	if ok, err := c.Deliver([]byte(reply)); !ok || err != nil {
+		t.Fatalf("Deliver: %v, %v", ok, err)
+	}
+	if err := f.Wait(context.Background()); err != nil || out.Value != "first" {
+		t.Errorf("got %q, %v", out.Value, err)
+	}
+}
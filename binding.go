@@ -0,0 +1,102 @@
+package soap
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// A Binding carries SOAP messages between a Client and a service over
+// a protocol other than HTTP. Bindings are used through the
+// http.RoundTripper returned by BindingTransport, so that the Client's
+// retries, limits and other settings apply to them as they do to HTTP.
+type Binding interface {
+	// Exchange sends a request message to the service at the
+	// endpoint u, such as tcp://host:port, and returns the
+	// response message. A nil response with a nil error means the
+	// service accepted a one-way message without replying. Faults
+	// are returned as response messages, not as errors.
+	Exchange(ctx context.Context, u *url.URL, action string, msg []byte) ([]byte, error)
+}
+
+// BindingTransport returns an http.RoundTripper that sends the SOAP
+// messages of HTTP requests through b, to be used as the Transport of
+// a Client whose URL names an endpoint of the binding. Response
+// messages are returned with a 200 status, or 202 if empty, and the
+// media type of the request. Request bodies must not be compressed.
+func BindingTransport(b Binding) http.RoundTripper {
+	return bindingTransport{b}
+}
+
+type bindingTransport struct {
+	b Binding
+}
+
+func (t bindingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var msg []byte
+	if req.Body != nil {
+		var err error
+		msg, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	data, err := t.b.Exchange(req.Context(), req.URL, requestAction(req), msg)
+	if err != nil {
+		return nil, err
+	}
+	rsp := &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        make(http.Header),
+		Body:          io.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Request:       req,
+	}
+	if len(data) == 0 {
+		rsp.Status, rsp.StatusCode = "202 Accepted", http.StatusAccepted
+	} else if mt, _, err := mime.ParseMediaType(req.Header.Get("Content-Type")); err == nil {
+		rsp.Header.Set("Content-Type", mt)
+	}
+	return rsp, nil
+}
+
+// CloseIdleConnections closes the idle connections of the binding,
+// if it keeps any.
+func (t bindingTransport) CloseIdleConnections() {
+	if b, ok := t.b.(interface{ CloseIdleConnections() }); ok {
+		b.CloseIdleConnections()
+	}
+}
+
+type oneWayKey struct{}
+
+// OneWay reports whether ctx is the context of a request sent by
+// Client.Send to a one-way operation, for which a Binding should not
+// wait for a response message.
+func OneWay(ctx context.Context) bool {
+	v, _ := ctx.Value(oneWayKey{}).(bool)
+	return v
+}
+
+// requestAction returns the SOAP action of an HTTP request, as set
+// by SetAction for either version of SOAP.
+func requestAction(req *http.Request) string {
+	if a := req.Header.Get("SOAPAction"); a != "" {
+		if s, err := strconv.Unquote(a); err == nil {
+			return s
+		}
+		return strings.Trim(a, `"`)
+	}
+	_, params, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	return params["action"]
+}
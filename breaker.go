@@ -0,0 +1,194 @@
+package soap
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by a Client whose Breaker is rejecting
+// calls.
+var ErrCircuitOpen = errors.New("soap: circuit breaker is open")
+
+// A BreakerState is the state of a circuit breaker.
+type BreakerState int
+
+const (
+	// Calls are allowed, and their failures are counted.
+	BreakerClosed BreakerState = iota
+	// Calls are rejected with ErrCircuitOpen.
+	BreakerOpen
+	// A limited number of probe calls are allowed, to test
+	// whether the endpoint has recovered.
+	BreakerHalfOpen
+)
+
+// A Breaker is a circuit breaker. Once the proportion of failed calls
+// reaches FailureRatio, the breaker opens and calls fail immediately
+// with ErrCircuitOpen, rather than waiting on an endpoint that is
+// down. After Cooldown, the breaker lets probe calls through, and
+// closes again if they succeed. The zero value is a usable Breaker
+// with default settings. A Breaker is safe for concurrent use and
+// should be shared by all Clients talking to the same endpoint.
+type Breaker struct {
+	// FailureRatio is the proportion of failed calls at which
+	// the breaker opens. If zero, 0.5 is used.
+	FailureRatio float64
+
+	// MinCalls is the number of calls that must be made within
+	// Window before the breaker may open. If zero, 10 is used.
+	MinCalls int
+
+	// Window is the period over which calls are counted. If
+	// zero, one minute is used.
+	Window time.Duration
+
+	// Cooldown is how long the breaker stays open before
+	// allowing probe calls. If zero, 30 seconds is used.
+	Cooldown time.Duration
+
+	// Probes is the number of successful probe calls needed to
+	// close the breaker, and the number of probes allowed at once.
+	// If zero, 1 is used.
+	Probes int
+
+	// IsFailure reports whether an error counts against the
+	// endpoint. If nil, transport errors and HTTP 5xx statuses
+	// without a SOAP Fault are failures; faults, which show the
+	// service is responding, are not.
+	IsFailure func(error) bool
+
+	mu          sync.Mutex
+	state       BreakerState
+	gen         int // incremented on every state change
+	windowStart time.Time
+	calls       int
+	failures    int
+	openedAt    time.Time
+	inflight    int
+	successes   int
+}
+
+// State returns the current state of the breaker.
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(time.Now())
+	return b.state
+}
+
+func (b *Breaker) isFailure(err error) bool {
+	if b.IsFailure != nil {
+		return b.IsFailure(err)
+	}
+	switch err := err.(type) {
+	case nil, *Fault:
+		return false
+	case *StatusError:
+		return err.StatusCode >= http.StatusInternalServerError
+	}
+	return true
+}
+
+func (b *Breaker) setState(s BreakerState, now time.Time) {
+	b.state = s
+	b.gen++
+	b.windowStart = now
+	b.calls, b.failures = 0, 0
+	b.inflight, b.successes = 0, 0
+	if s == BreakerOpen {
+		b.openedAt = now
+	}
+}
+
+// advance performs time-based state transitions.
+func (b *Breaker) advance(now time.Time) {
+	switch b.state {
+	case BreakerClosed:
+		if now.Sub(b.windowStart) >= durationOr(b.Window, time.Minute) {
+			b.windowStart = now
+			b.calls, b.failures = 0, 0
+		}
+	case BreakerOpen:
+		if now.Sub(b.openedAt) >= durationOr(b.Cooldown, 30*time.Second) {
+			b.setState(BreakerHalfOpen, now)
+		}
+	}
+}
+
+// allow reports whether a call may proceed. If it may, the returned
+// function must be called with the call's result.
+func (b *Breaker) allow() (func(error), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(time.Now())
+	switch b.state {
+	case BreakerOpen:
+		return nil, ErrCircuitOpen
+	case BreakerHalfOpen:
+		if b.inflight >= intOr(b.Probes, 1) {
+			return nil, ErrCircuitOpen
+		}
+		b.inflight++
+	}
+	gen := b.gen
+	return func(err error) { b.record(gen, err) }, nil
+}
+
+func (b *Breaker) record(gen int, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if gen != b.gen {
+		return
+	}
+	if errors.Is(err, context.Canceled) {
+		// abandoned, e.g. a hedged request; says nothing
+		// about the endpoint
+		if b.state == BreakerHalfOpen {
+			b.inflight--
+		}
+		return
+	}
+	now, failed := time.Now(), b.isFailure(err)
+	switch b.state {
+	case BreakerClosed:
+		b.calls++
+		if failed {
+			b.failures++
+		}
+		ratio := float64(b.failures) / float64(b.calls)
+		if b.calls >= intOr(b.MinCalls, 10) && ratio >= floatOr(b.FailureRatio, 0.5) {
+			b.setState(BreakerOpen, now)
+		}
+	case BreakerHalfOpen:
+		b.inflight--
+		if failed {
+			b.setState(BreakerOpen, now)
+		} else if b.successes++; b.successes >= intOr(b.Probes, 1) {
+			b.setState(BreakerClosed, now)
+		}
+	}
+}
+
+func durationOr(d, def time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return def
+}
+
+func intOr(n, def int) int {
+	if n > 0 {
+		return n
+	}
+	return def
+}
+
+func floatOr(f, def float64) float64 {
+	if f > 0 {
+		return f
+	}
+	return def
+}
@@ -0,0 +1,43 @@
+package soap
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	b := &Breaker{MinCalls: 4, Cooldown: 20 * time.Millisecond}
+	fail := errors.New("connection refused")
+
+	for i := 0; i < 4; i++ {
+		done, err := b.allow()
+		if err != nil {
+			t.Fatalf("call %d rejected: %v", i, err)
+		}
+		if i%2 == 0 {
+			done(fail)
+		} else {
+			done(&Fault{Code: "Client"})
+		}
+	}
+	if s := b.State(); s != BreakerOpen {
+		t.Fatalf("state is %v after 50%% failures, want open", s)
+	}
+	if _, err := b.allow(); err != ErrCircuitOpen {
+		t.Fatalf("got %v, want ErrCircuitOpen", err)
+	}
+
+	time.Sleep(25 * time.Millisecond)
+	done, err := b.allow()
+	if err != nil {
+		t.Fatalf("probe rejected: %v", err)
+	}
+	if _, err := b.allow(); err != ErrCircuitOpen {
+		t.Errorf("second concurrent probe allowed")
+	}
+	done(nil)
+	if s := b.State(); s != BreakerClosed {
+		t.Errorf("state is %v after successful probe, want closed", s)
+	}
+}
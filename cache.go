@@ -0,0 +1,164 @@
+package soap
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Cache stores response messages for reuse by later calls. Keys
+// are opaque strings computed by the Client.
+type Cache interface {
+	// Get returns the message stored under key, if it has not
+	// expired.
+	Get(key string) ([]byte, bool)
+
+	// Set stores a message under key for the duration ttl.
+	Set(key string, data []byte, ttl time.Duration)
+}
+
+// A MemoryCache is a Cache holding messages in memory, evicting the
+// least recently used when full. It is safe for concurrent use.
+type MemoryCache struct {
+	mu      sync.Mutex
+	max     int
+	lru     *list.List // of *cacheEntry, most recently used first
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key     string
+	data    []byte
+	expires time.Time
+}
+
+// NewMemoryCache returns a MemoryCache holding up to maxEntries
+// messages, or any number if maxEntries is not positive.
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	return &MemoryCache{
+		max:     maxEntries,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Get implements the Cache interface.
+func (c *MemoryCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*cacheEntry)
+	if time.Now().After(e.expires) {
+		c.lru.Remove(el)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	return e.data, true
+}
+
+// Set implements the Cache interface.
+func (c *MemoryCache) Set(key string, data []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := &cacheEntry{key: key, data: data, expires: time.Now().Add(ttl)}
+	if el, ok := c.entries[key]; ok {
+		el.Value = e
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(e)
+	for c.max > 0 && c.lru.Len() > c.max {
+		el := c.lru.Back()
+		c.lru.Remove(el)
+		delete(c.entries, el.Value.(*cacheEntry).key)
+	}
+}
+
+// Len returns the number of messages in the cache, including those
+// that have expired but not yet been evicted.
+func (c *MemoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// cacheKey returns the key of the response to a request message sent
+// to an endpoint. It is a hash of the endpoint, the action and the
+// canonical form of the request's Body, so that requests differing
+// only in their headers, namespace prefixes, attribute order or
+// insignificant white space share a key. The QNames of xsi:type and
+// arrayType values are hashed with their namespaces, not prefixes.
+func cacheKey(endpoint, action string, msg []byte) (string, error) {
+	h := sha256.New()
+	io.WriteString(h, endpoint+"\x00"+action+"\x00")
+	d := xml.NewDecoder(bytes.NewReader(msg))
+	depth, inBody := 0, false
+	var scopes []map[string]string // namespace prefixes in scope, by depth
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return "", err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			depth++
+			var parent map[string]string
+			if len(scopes) > 0 {
+				parent = scopes[len(scopes)-1]
+			}
+			scopes = append(scopes, nsScope(parent, tok.Attr))
+			if depth == 2 && tok.Name.Local == "Body" {
+				inBody = true
+			} else if inBody {
+				writeCanonicalStart(h, scopes[len(scopes)-1], tok)
+			}
+		case xml.EndElement:
+			scopes = scopes[:len(scopes)-1]
+			depth--
+			if depth == 1 {
+				inBody = false
+			} else if inBody {
+				io.WriteString(h, "\x00>")
+			}
+		case xml.CharData:
+			if inBody && len(bytes.TrimSpace(tok)) > 0 {
+				io.WriteString(h, "\x00#")
+				h.Write(tok)
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeCanonicalStart writes an element's expanded name and its
+// attributes, other than namespace declarations, in sorted order.
+// QName values are resolved against the prefixes in scope.
+func writeCanonicalStart(w io.Writer, scope map[string]string, start xml.StartElement) {
+	io.WriteString(w, "\x00<"+start.Name.Space+" "+start.Name.Local)
+	var attrs []string
+	for _, a := range start.Attr {
+		if a.Name.Space == "xmlns" || a.Name.Space == "" && a.Name.Local == "xmlns" {
+			continue
+		}
+		value := a.Value
+		if q, ok := parseQNameAttr(scope, a); ok {
+			value = "{" + q.name.Space + "}" + q.name.Local + q.suffix
+		}
+		attrs = append(attrs, a.Name.Space+" "+a.Name.Local+"="+value)
+	}
+	sort.Strings(attrs)
+	io.WriteString(w, "\x00@"+strings.Join(attrs, "\x00@"))
+}
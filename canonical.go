@@ -0,0 +1,259 @@
+package soap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const nsXML = "http://www.w3.org/XML/1998/namespace"
+
+// canonicalize writes an XML document to w in the canonical form of
+// messages written by an Encoder with Canonical set. Every namespace
+// is declared once, on the root element, with its prefix in preferred,
+// if any and not already taken, or else the prefix it was first
+// declared with or, if it was only the default namespace or its prefix
+// is taken, a prefix of the form nsN, numbered in order of first use.
+// As no default namespace is declared, the QNames in the values of
+// xsi:type and SOAP-ENC:arrayType attributes are rewritten with the
+// new prefixes, so that they keep their meaning; QNames elsewhere,
+// such as in the text of elements, are not. Attributes are sorted by
+// namespace and local name, elements are written with start and end
+// tags, and text is escaped minimally.
+func canonicalize(w io.Writer, data []byte, preferred map[string]string) error {
+	var toks []xml.Token
+	qnames := make(map[[2]int]qnameValue)
+	scopes := []map[string]string{nil}
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			scope := nsScope(scopes[len(scopes)-1], tok.Attr)
+			scopes = append(scopes, scope)
+			for j, a := range tok.Attr {
+				if q, ok := parseQNameAttr(scope, a); ok {
+					qnames[[2]int{len(toks), j}] = q
+				}
+			}
+		case xml.EndElement:
+			scopes = scopes[:len(scopes)-1]
+		}
+		toks = append(toks, xml.CopyToken(tok))
+	}
+	prefixes := canonicalPrefixes(toks, qnames, preferred)
+
+	var buf bytes.Buffer
+	root := true
+	for i, tok := range toks {
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			buf.WriteByte('<')
+			writeQName(&buf, prefixes, tok.Name)
+			if root {
+				decls := make([]xml.Attr, 0, len(prefixes))
+				for uri, prefix := range prefixes {
+					if uri != nsXML {
+						decls = append(decls, xml.Attr{Name: xml.Name{Local: prefix}, Value: uri})
+					}
+				}
+				sort.Slice(decls, func(i, j int) bool { return decls[i].Name.Local < decls[j].Name.Local })
+				for _, a := range decls {
+					buf.WriteString(" xmlns:" + a.Name.Local + `="`)
+					escapeCanonical(&buf, a.Value, true)
+					buf.WriteByte('"')
+				}
+				root = false
+			}
+			attrs := make([]xml.Attr, 0, len(tok.Attr))
+			for j, a := range tok.Attr {
+				if isNamespaceDecl(a) {
+					continue
+				}
+				if q, ok := qnames[[2]int{i, j}]; ok {
+					a.Value = q.name.Local + q.suffix
+					if q.name.Space != "" {
+						a.Value = prefixes[q.name.Space] + ":" + a.Value
+					}
+				}
+				attrs = append(attrs, a)
+			}
+			sort.Slice(attrs, func(i, j int) bool {
+				if attrs[i].Name.Space != attrs[j].Name.Space {
+					return attrs[i].Name.Space < attrs[j].Name.Space
+				}
+				return attrs[i].Name.Local < attrs[j].Name.Local
+			})
+			for _, a := range attrs {
+				buf.WriteByte(' ')
+				writeQName(&buf, prefixes, a.Name)
+				buf.WriteString(`="`)
+				escapeCanonical(&buf, a.Value, true)
+				buf.WriteByte('"')
+			}
+			buf.WriteByte('>')
+		case xml.EndElement:
+			buf.WriteString("</")
+			writeQName(&buf, prefixes, tok.Name)
+			buf.WriteByte('>')
+		case xml.CharData:
+			escapeCanonical(&buf, string(tok), false)
+		case xml.Comment:
+			buf.WriteString("<!--" + string(tok) + "-->")
+		case xml.ProcInst:
+			buf.WriteString("<?" + tok.Target)
+			if len(tok.Inst) > 0 {
+				buf.WriteString(" " + string(tok.Inst))
+			}
+			buf.WriteString("?>")
+		case xml.Directive:
+			buf.WriteString("<!" + string(tok) + ">")
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// A qnameValue is the QName in the value of an attribute, with its
+// namespace resolved, followed by suffix, such as the dimensions of
+// an arrayType.
+type qnameValue struct {
+	name   xml.Name
+	suffix string
+}
+
+// parseQNameAttr returns the QName in the value of an xsi:type or
+// SOAP-ENC:arrayType attribute, given the namespaces in scope. It
+// returns false for other attributes, and for values whose prefix is
+// not declared.
+func parseQNameAttr(scope map[string]string, a xml.Attr) (qnameValue, bool) {
+	if a.Name != (xml.Name{Space: NsXSI, Local: "type"}) && a.Name != (xml.Name{Space: Encoding, Local: "arrayType"}) {
+		return qnameValue{}, false
+	}
+	value, suffix := a.Value, ""
+	if i := strings.IndexByte(value, '['); i >= 0 {
+		value, suffix = value[:i], value[i:]
+	}
+	prefix, local, ok := strings.Cut(value, ":")
+	if !ok {
+		prefix, local = "", value
+	}
+	ns, declared := scope[prefix]
+	if !declared && prefix != "" {
+		return qnameValue{}, false
+	}
+	return qnameValue{name: xml.Name{Space: ns, Local: local}, suffix: suffix}, true
+}
+
+// canonicalPrefixes returns the prefixes of the namespaces used or
+// declared in a document, by namespace, preferring those in preferred.
+// The namespaces of qnames, keyed by the indexes of their token and
+// attribute, count as used.
+func canonicalPrefixes(toks []xml.Token, qnames map[[2]int]qnameValue, preferred map[string]string) map[string]string {
+	var order []string
+	declared := make(map[string]string)
+	seen := map[string]bool{"": true, nsXML: true}
+	use := func(uri string) {
+		if !seen[uri] {
+			seen[uri] = true
+			order = append(order, uri)
+		}
+	}
+	for i, tok := range toks {
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		use(start.Name.Space)
+		for j, a := range start.Attr {
+			if q, ok := qnames[[2]int{i, j}]; ok {
+				use(q.name.Space)
+			}
+			switch {
+			case a.Name.Space == "xmlns":
+				if _, ok := declared[a.Value]; !ok {
+					declared[a.Value] = a.Name.Local
+				}
+				use(a.Value)
+			case isNamespaceDecl(a):
+				use(a.Value)
+			default:
+				use(a.Name.Space)
+			}
+		}
+	}
+
+	prefixes := map[string]string{nsXML: "xml"}
+	taken := map[string]bool{"xml": true, "xmlns": true}
+	for _, uri := range order {
+		if p := preferred[uri]; p != "" && !taken[p] {
+			prefixes[uri], taken[p] = p, true
+		}
+	}
+	for _, uri := range order {
+		if _, ok := prefixes[uri]; ok {
+			continue
+		}
+		if p := declared[uri]; p != "" && !taken[p] {
+			prefixes[uri], taken[p] = p, true
+		}
+	}
+	n := 0
+	for _, uri := range order {
+		if _, ok := prefixes[uri]; ok {
+			continue
+		}
+		p := ""
+		for p == "" || taken[p] {
+			n++
+			p = "ns" + strconv.Itoa(n)
+		}
+		prefixes[uri], taken[p] = p, true
+	}
+	return prefixes
+}
+
+func isNamespaceDecl(a xml.Attr) bool {
+	return a.Name.Space == "xmlns" || a.Name.Space == "" && a.Name.Local == "xmlns"
+}
+
+func writeQName(buf *bytes.Buffer, prefixes map[string]string, name xml.Name) {
+	if name.Space != "" {
+		buf.WriteString(prefixes[name.Space])
+		buf.WriteByte(':')
+	}
+	buf.WriteString(name.Local)
+}
+
+// escapeCanonical writes s with the characters that cannot appear
+// literally in text, or in attribute values if attr is set, escaped.
+func escapeCanonical(buf *bytes.Buffer, s string, attr bool) {
+	for _, r := range s {
+		switch {
+		case r == '&':
+			buf.WriteString("&amp;")
+		case r == '<':
+			buf.WriteString("&lt;")
+		case r == '>' && !attr:
+			buf.WriteString("&gt;")
+		case r == '"' && attr:
+			buf.WriteString("&quot;")
+		case r == '\t' && attr:
+			buf.WriteString("&#x9;")
+		case r == '\n' && attr:
+			buf.WriteString("&#xA;")
+		case r == '\r':
+			buf.WriteString("&#xD;")
+		default:
+			buf.WriteRune(r)
+		}
+	}
+}
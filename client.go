@@ -0,0 +1,756 @@
+package soap
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Client sends SOAP requests to a service over HTTP. URL must
+// be set before the Client is used.
+type Client struct {
+	// URL is the address of the service endpoint. It is not
+	// used if Failover is set.
+	URL string
+
+	// Version is the version of SOAP spoken by the service.
+	// The zero value is SOAP 1.1.
+	Version Version
+
+	// Failover, if non-nil, holds the addresses of a service
+	// published at more than one endpoint.
+	Failover *Failover
+
+	// Profile, if non-nil, selects the settings needed to
+	// interoperate with the service's SOAP implementation, such
+	// as AxisV1 or DotNetWSE.
+	Profile *Profile
+
+	// UserAgent, if not empty, is sent as the User-Agent
+	// header of every request.
+	UserAgent string
+
+	// Header holds HTTP headers added to every request, such as
+	// API keys or routing headers required by a gateway. Headers
+	// set by the Client for the SOAP protocol take precedence.
+	Header http.Header
+
+	// If Username is not empty, Username and Password are sent
+	// with every request using preemptive HTTP Basic
+	// authentication, without waiting for a challenge.
+	Username, Password string
+
+	// HTTPClient is used to send requests. If nil, a client
+	// using Transport is used.
+	HTTPClient *http.Client
+
+	// Transport is used to send requests when HTTPClient is nil.
+	// If both are nil, http.DefaultTransport is used. Sharing a
+	// Transport between Clients shares its connection pool.
+	Transport http.RoundTripper
+
+	// DialContext, if non-nil, opens the connections of the
+	// Client when HTTPClient and Transport are nil, as for a
+	// service reached through a local socket or a tunnel.
+	// Otherwise, endpoints may also be Unix domain sockets,
+	// named by URLs such as unix:///run/gateway.sock/services/Quote,
+	// the path of the socket followed by that of the request.
+	DialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// Trace, if non-nil, is called with the timings of every
+	// HTTP request made by the Client, once the response body
+	// has been closed or the request has failed.
+	Trace func(*CallTrace)
+
+	// Retry controls whether failed calls are attempted again.
+	// If nil, calls are attempted only once.
+	Retry *RetryPolicy
+
+	// Reauth maps fault codes to functions that renew an expired
+	// session. When a call fails with a Fault whose code matches a
+	// key, with or without its namespace prefix, the function is
+	// called and, if it succeeds, the call is attempted once more.
+	// The functions may be called concurrently.
+	Reauth map[string]func(context.Context) error
+
+	// Session, if non-nil, keeps cookies and session headers
+	// set by the service and returns them on later requests.
+	// It should be used instead of HTTPClient.Jar.
+	Session *Session
+
+	// Breaker, if non-nil, is consulted before every attempt
+	// to send a request, and records its outcome.
+	Breaker *Breaker
+
+	// Limiter, if non-nil, limits the rate at which requests
+	// are sent to the service.
+	Limiter Limiter
+
+	// Operations holds settings for individual operations,
+	// keyed by SOAPAction.
+	Operations map[string]Operation
+
+	// Hedge, if non-nil, enables hedged requests for
+	// operations marked Idempotent.
+	Hedge *HedgePolicy
+
+	// Cache, if non-nil, keeps the responses to operations
+	// marked Idempotent with a positive CacheTTL, which are
+	// reused by calls with the same endpoint, action and Body.
+	// Faults are not cached. As the headers of requests are not
+	// compared, a Cache should not be shared by Clients whose
+	// headers select different responses, such as those of
+	// different users.
+	Cache Cache
+
+	// Timeouts bounds the duration of calls. It may be
+	// overridden for individual operations.
+	Timeouts Timeouts
+
+	// CompressRequests compresses request bodies with gzip.
+	// The service must support Content-Encoding on requests.
+	// Compressed responses are requested and decoded regardless,
+	// unless disabled in the HTTP transport.
+	CompressRequests bool
+
+	// If ExpectContinue is positive, requests with bodies of at
+	// least that many bytes, or streamed bodies of unknown length,
+	// carry an "Expect: 100-continue" header, so that a service
+	// rejecting the request, for instance because it is not
+	// authorized, can do so before the body is sent. The Transport
+	// must have a non-zero ExpectContinueTimeout.
+	ExpectContinue int
+
+	// Prefixes, if not empty, maps namespaces to the prefixes
+	// they are written with in requests, as with Encoder.Prefixes,
+	// for services that match prefixes literally. Every request
+	// is then put in canonical form, which costs a second pass
+	// over the message.
+	Prefixes map[string]string
+
+	// HeaderOrder, if not empty, orders the entries of the SOAP
+	// Header of requests, as with Encoder.HeaderOrder, whatever
+	// the order in which they were added.
+	HeaderOrder []xml.Name
+
+	// MaxAsync, if positive, is the maximum number of calls
+	// started by CallAsync that are in progress at once.
+	MaxAsync int
+
+	asyncOnce sync.Once
+	asyncSem  chan struct{}
+
+	dialOnce   sync.Once
+	dialClient *http.Client
+}
+
+// An Operation holds a Client's settings for a single operation.
+type Operation struct {
+	// Limiter, if non-nil, limits the rate at which requests
+	// for the operation are sent, in addition to Client.Limiter.
+	Limiter Limiter
+
+	// Idempotent marks operations that may safely be sent more
+	// than once, such as read-only queries.
+	Idempotent bool
+
+	// CacheTTL, if positive, is the time for which responses
+	// to the operation are kept in Client.Cache. It is ignored
+	// unless the operation is Idempotent.
+	CacheTTL time.Duration
+
+	// Timeouts overrides the non-zero fields of
+	// Client.Timeouts for the operation.
+	Timeouts Timeouts
+
+	// Stream encodes requests as they are sent, rather than
+	// in memory beforehand, for operations with very large
+	// requests. The request is encoded again for every attempt,
+	// and must not be modified until the call returns.
+	Stream bool
+}
+
+// A StatusError is returned by a Client when the service responds
+// with a non-2xx HTTP status and no SOAP Fault.
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return "soap: unexpected HTTP status " + e.Status
+}
+
+// Clone returns a new Client with the settings of c, which may then
+// be changed without affecting c, as for a tenant of a multi-tenant
+// application calling its own endpoint with its own credentials. The
+// maps of c are copied, while the values referred to by its other
+// fields, such as its Session, Breaker and Cache, are shared; set them
+// to new values if the clone must not share their state. The clone
+// shares the connections of c, including those opened with its
+// DialContext, which the clone keeps using even if its DialContext is
+// changed; set HTTPClient or Transport to use other connections. Calls
+// started by CallAsync are limited by the MaxAsync of each Client
+// separately.
+func (c *Client) Clone() *Client {
+	d := &Client{
+		URL:              c.URL,
+		Version:          c.Version,
+		Failover:         c.Failover,
+		Profile:          c.Profile,
+		UserAgent:        c.UserAgent,
+		Header:           c.Header.Clone(),
+		Username:         c.Username,
+		Password:         c.Password,
+		HTTPClient:       c.HTTPClient,
+		Transport:        c.Transport,
+		DialContext:      c.DialContext,
+		Trace:            c.Trace,
+		Retry:            c.Retry,
+		Session:          c.Session,
+		Breaker:          c.Breaker,
+		Limiter:          c.Limiter,
+		Hedge:            c.Hedge,
+		Cache:            c.Cache,
+		Timeouts:         c.Timeouts,
+		CompressRequests: c.CompressRequests,
+		ExpectContinue:   c.ExpectContinue,
+		HeaderOrder:      c.HeaderOrder,
+		MaxAsync:         c.MaxAsync,
+	}
+	if c.Reauth != nil {
+		d.Reauth = make(map[string]func(context.Context) error, len(c.Reauth))
+		for k, v := range c.Reauth {
+			d.Reauth[k] = v
+		}
+	}
+	if c.Prefixes != nil {
+		d.Prefixes = make(map[string]string, len(c.Prefixes))
+		for k, v := range c.Prefixes {
+			d.Prefixes[k] = v
+		}
+	}
+	if c.Operations != nil {
+		d.Operations = make(map[string]Operation, len(c.Operations))
+		for k, v := range c.Operations {
+			d.Operations[k] = v
+		}
+	}
+	if c.HTTPClient == nil && c.Transport == nil && c.DialContext != nil {
+		shared := c.httpClient()
+		d.dialOnce.Do(func() { d.dialClient = shared })
+	}
+	return d
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	if c.Transport != nil {
+		return &http.Client{Transport: c.Transport}
+	}
+	if c.DialContext != nil {
+		c.dialOnce.Do(func() {
+			tr := http.DefaultTransport.(*http.Transport).Clone()
+			tr.DialContext = c.DialContext
+			c.dialClient = &http.Client{Transport: tr}
+		})
+		return c.dialClient
+	}
+	return defaultClient
+}
+
+// A CallOption modifies a single call made by a Client.
+type CallOption func(*exchange)
+
+// WithEndpoint sends a call to url, instead of the Client's URL
+// or Failover endpoints.
+func WithEndpoint(url string) CallOption {
+	return func(x *exchange) { x.endpoint = url }
+}
+
+// WithSOAPHeader adds entries to the SOAP Header of a call's request.
+// Each entry is encoded as by xml.Marshal.
+func WithSOAPHeader(entries ...interface{}) CallOption {
+	return func(x *exchange) { x.header = append(x.header, entries...) }
+}
+
+// WithEncodingStyle sets the encodingStyle attribute of the Envelope
+// of a call's request, usually to Encoding.
+func WithEncodingStyle(uri string) CallOption {
+	return func(x *exchange) { x.style = uri }
+}
+
+// WithResponseHeader stores the entries of the SOAP Header of a
+// call's response in h, including a response carrying a Fault. If the
+// Header cannot be read, h is left empty.
+func WithResponseHeader(h *Header) CallOption {
+	return func(x *exchange) {
+		x.observe(func(data []byte) { *h, _ = ReadHeader(data) })
+	}
+}
+
+// An exchange holds the state of a single call.
+type exchange struct {
+	action   string
+	version  Version
+	header   []interface{} // entries of the SOAP Header
+	style    string        // encodingStyle of the Envelope
+	prefixes map[string]string
+	body     []byte
+	msg      interface{} // the request, encoded for each request if stream is set
+	stream   bool
+	encoding string // Content-Encoding of body
+	endpoint string
+
+	toNS string // namespace of a wsa:To header naming the endpoint
+	to   string // endpoint named by the wsa:To header of body
+
+	oneWay bool // no response message is expected
+
+	headerOrder []xml.Name // order of the entries of the SOAP Header
+
+	// onResponse, if non-nil, is called with the response
+	// message of a call, including one containing a Fault.
+	onResponse func([]byte)
+
+	get   bool       // use the SOAP 1.2 HTTP GET binding
+	query url.Values // query parameters of a GET request
+}
+
+// observe adds fn to the functions called with the response message.
+func (x *exchange) observe(fn func([]byte)) {
+	if prev := x.onResponse; prev != nil {
+		x.onResponse = func(data []byte) { prev(data); fn(data) }
+		return
+	}
+	x.onResponse = fn
+}
+
+// encoder returns the settings of the Encoder used for a request
+// to the endpoint url.
+func (x *exchange) encoder(url string) Encoder {
+	header := x.header
+	if x.toNS != "" {
+		header = append([]interface{}{wsaText{xml.Name{Space: x.toNS, Local: "To"}, url}}, header...)
+	}
+	return Encoder{Version: x.version, Header: header, HeaderOrder: x.headerOrder, EncodingStyle: x.style, Prefixes: x.prefixes}
+}
+
+// bodyReader returns a reader for the body of a request to the
+// endpoint url. The body is encoded again if its wsa:To header names
+// another endpoint, as when failing over.
+func (x *exchange) bodyReader(url string) (io.Reader, error) {
+	if x.stream {
+		return pipeEnvelope(x.msg, x.encoder(url), x.encoding == "gzip"), nil
+	}
+	if x.toNS == "" || url == x.to {
+		return bytes.NewReader(x.body), nil
+	}
+	body, err := marshalEnvelope(x.msg, x.encoder(url))
+	if err == nil && x.encoding == "gzip" {
+		body, err = gzipBytes(body)
+	}
+	return bytes.NewReader(body), err
+}
+
+// Call sends req as the sole entry of a SOAP Body to the service, or
+// each of its elements if it is of type Entries, and decodes the
+// first entry of the response Body into resp. If resp is of type
+// Entries, every entry of the response Body is decoded, each into the
+// element at the same position; entries beyond the last element are
+// decoded into it if it points to a slice, and skipped otherwise.
+// Document links in the response are dereferenced, as with
+// Unmarshal. If resp is nil, the response Body is discarded. If the
+// service responds with a SOAP Fault, it is returned as an error of
+// type *Fault. Responses with a non-2xx status that do not carry a
+// Fault are returned as an error of type *StatusError.
+func (c *Client) Call(ctx context.Context, action string, req, resp interface{}, opts ...CallOption) error {
+	return c.reauthCall(ctx, func() error {
+		return c.call(ctx, action, req, resp, opts)
+	})
+}
+
+// Send sends req as the sole entry of a SOAP Body to a one-way
+// operation, which has no response message. Any 2xx response, usually
+// 202 Accepted or 204 No Content with an empty body, is a success, and
+// its body is discarded without being decoded. Faults and other
+// statuses are reported as they are by Call.
+func (c *Client) Send(ctx context.Context, action string, req interface{}, opts ...CallOption) error {
+	opts = append(opts[:len(opts):len(opts)], func(x *exchange) { x.oneWay = true })
+	return c.Call(ctx, action, req, nil, opts...)
+}
+
+// reauthCall runs call, running it once more after renewing the
+// session if it fails with a Fault registered in c.Reauth.
+func (c *Client) reauthCall(ctx context.Context, call func() error) error {
+	err := call()
+	if f, ok := err.(*Fault); ok {
+		if renew := c.reauth(f); renew != nil {
+			if err := renew(ctx); err != nil {
+				return err
+			}
+			return call()
+		}
+	}
+	return err
+}
+
+// reauth returns the function registered in c.Reauth for a fault,
+// or nil.
+func (c *Client) reauth(f *Fault) func(context.Context) error {
+	if fn, ok := c.Reauth[f.Code]; ok {
+		return fn
+	}
+	if i := strings.LastIndex(f.Code, ":"); i >= 0 {
+		return c.Reauth[f.Code[i+1:]]
+	}
+	return nil
+}
+
+func (c *Client) call(ctx context.Context, action string, req, resp interface{}, opts []CallOption) error {
+	if t := c.timeouts(action); t.Call > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.Call)
+		defer cancel()
+	}
+	x := &exchange{action: action, version: c.Version, prefixes: c.Prefixes, headerOrder: c.HeaderOrder}
+	for _, opt := range opts {
+		opt(x)
+	}
+	if c.CompressRequests {
+		x.encoding = "gzip"
+	}
+	var err error
+	x.msg, x.to = req, c.firstEndpoint(x)
+	if c.Operations[action].Stream {
+		x.stream = true
+	} else if x.body, err = marshalEnvelope(req, x.encoder(x.to)); err != nil {
+		return err
+	}
+	var key string
+	if ttl := c.cacheTTL(x); ttl > 0 {
+		if key, err = cacheKey(x.endpoint+"\x00"+c.URL, action, x.body); err != nil {
+			return err
+		}
+		if data, ok := c.Cache.Get(key); ok {
+			if x.onResponse != nil {
+				x.onResponse(data)
+			}
+			if resp == nil {
+				return nil
+			}
+			return unmarshalBody(c.Profile.flattener(), data, resp)
+		}
+	}
+	if c.CompressRequests && !x.stream {
+		if x.body, err = gzipBytes(x.body); err != nil {
+			return err
+		}
+	}
+	data, err := c.roundTrip(ctx, x)
+	if x.onResponse != nil && len(data) > 0 {
+		x.onResponse(data)
+	}
+	if err != nil {
+		return err
+	}
+	if key != "" {
+		c.Cache.Set(key, data, c.cacheTTL(x))
+	}
+	if resp == nil {
+		return nil
+	}
+	return unmarshalBody(c.Profile.flattener(), data, resp)
+}
+
+// cacheTTL returns the time for which the response to a call may be
+// cached, or 0 if it may not.
+func (c *Client) cacheTTL(x *exchange) time.Duration {
+	op := c.Operations[x.action]
+	if c.Cache == nil || !op.Idempotent || x.stream || x.get || x.oneWay {
+		return 0
+	}
+	return op.CacheTTL
+}
+
+// roundTrip sends a SOAP message, retrying according to c.Retry,
+// and returns the response message.
+func (c *Client) roundTrip(ctx context.Context, x *exchange) ([]byte, error) {
+	send := c.send
+	if c.Hedge != nil && c.Operations[x.action].Idempotent {
+		send = c.hedge
+	}
+	for attempt := 1; ; attempt++ {
+		data, err := send(ctx, x)
+		if err == nil || !c.Retry.wait(ctx, attempt, err) {
+			return data, err
+		}
+	}
+}
+
+// send makes a single attempt at a call.
+func (c *Client) send(ctx context.Context, x *exchange) ([]byte, error) {
+	for _, l := range []Limiter{c.Limiter, c.Operations[x.action].Limiter} {
+		if l != nil {
+			if err := l.Wait(ctx); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if c.Breaker == nil {
+		return c.dispatch(ctx, x)
+	}
+	done, err := c.Breaker.allow()
+	if err != nil {
+		return nil, err
+	}
+	data, err := c.dispatch(ctx, x)
+	done(err)
+	return data, err
+}
+
+// firstEndpoint returns the endpoint a call is sent to first.
+func (c *Client) firstEndpoint(x *exchange) string {
+	if x.endpoint != "" {
+		return x.endpoint
+	}
+	if c.Failover != nil {
+		if urls := c.Failover.endpoints(); len(urls) > 0 {
+			return urls[0]
+		}
+	}
+	return c.URL
+}
+
+// dispatch sends a request to the endpoint chosen for the call,
+// failing over to other endpoints if c.Failover is set.
+func (c *Client) dispatch(ctx context.Context, x *exchange) ([]byte, error) {
+	if x.endpoint != "" {
+		return c.post(ctx, x, x.endpoint)
+	}
+	if c.Failover == nil {
+		return c.post(ctx, x, c.URL)
+	}
+	var err error
+	for _, url := range c.Failover.endpoints() {
+		var data []byte
+		data, err = c.post(ctx, x, url)
+		if ctx.Err() != nil || !c.Failover.failed(url, err) {
+			return data, err
+		}
+	}
+	return nil, err
+}
+
+// post sends a SOAP message in an HTTP POST request, or a GET
+// request for the SOAP 1.2 GET binding, and reads the response
+// message.
+func (c *Client) post(ctx context.Context, x *exchange, url string) ([]byte, error) {
+	if x.get {
+		req, err := NewGetRequest(url, x.query)
+		if err != nil {
+			return nil, err
+		}
+		return c.request(ctx, x, req)
+	}
+	opts := []RequestOption{WithAction(x.action)}
+	if x.version == V12 {
+		opts = append(opts, WithSOAP12())
+	}
+	body, err := x.bodyReader(url)
+	if err != nil {
+		return nil, err
+	}
+	req, err := NewRequest(url, body, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if x.encoding != "" {
+		req.Header.Set("Content-Encoding", x.encoding)
+	}
+	if c.ExpectContinue > 0 && (x.stream || len(x.body) >= c.ExpectContinue) {
+		req.Header.Set("Expect", "100-continue")
+	}
+	if x.stream {
+		req.GetBody = func() (io.ReadCloser, error) {
+			body, _ := x.bodyReader(url)
+			return body.(io.ReadCloser), nil
+		}
+	}
+	return c.request(ctx, x, req)
+}
+
+// request adds the Client's headers and credentials to a request,
+// sends it, and reads the response message.
+func (c *Client) request(ctx context.Context, x *exchange, req *http.Request) ([]byte, error) {
+	for k, v := range c.Header {
+		if req.Header.Get(k) == "" {
+			req.Header[k] = append([]string(nil), v...)
+		}
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", x.version.accept())
+	}
+	if req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", AcceptEncoding())
+	}
+	if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	if c.Session != nil {
+		c.Session.prepare(req)
+	}
+
+	if x.oneWay {
+		ctx = context.WithValue(ctx, oneWayKey{}, true)
+	}
+	rsp, err := c.do(ctx, x.action, req)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+	if c.Session != nil {
+		c.Session.capture(rsp)
+	}
+	if x.oneWay && rsp.StatusCode/100 == 2 {
+		_, err := io.Copy(io.Discard, rsp.Body)
+		return nil, err
+	}
+
+	data, err := readMessage(rsp.Header, rsp.Body, c.Profile)
+	if _, ok := err.(*Fault); !ok && rsp.StatusCode/100 != 2 {
+		return nil, &StatusError{StatusCode: rsp.StatusCode, Status: rsp.Status}
+	}
+	return data, err
+}
+
+// do sends a single HTTP request, reporting its timings to c.Trace
+// and enforcing the phase timeouts of the operation.
+func (c *Client) do(ctx context.Context, action string, req *http.Request) (*http.Response, error) {
+	var hooks []func(error)
+	if c.Trace != nil {
+		tr := newTracer(action, req.URL.String())
+		ctx = httptrace.WithClientTrace(ctx, tr.clientTrace())
+		hooks = append(hooks, func(err error) { c.Trace(tr.finish(err)) })
+	}
+	if t := c.timeouts(action); t.Connect > 0 || t.ResponseHeader > 0 {
+		var stop func(error)
+		ctx, stop = withPhaseTimeouts(ctx, t)
+		hooks = append(hooks, stop)
+	}
+	done := func(err error) {
+		for _, fn := range hooks {
+			fn(err)
+		}
+	}
+	rsp, err := c.httpClient().Do(req.WithContext(ctx))
+	if err != nil {
+		if cause, ok := context.Cause(ctx).(*TimeoutError); ok {
+			err = cause
+		}
+		done(err)
+		return nil, err
+	}
+	if len(hooks) > 0 {
+		rsp.Body = &closeHook{ReadCloser: rsp.Body, fn: func() { done(nil) }}
+	}
+	return rsp, nil
+}
+
+// A closeHook calls fn when the response body is closed.
+type closeHook struct {
+	io.ReadCloser
+	once sync.Once
+	fn   func()
+}
+
+func (b *closeHook) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.fn)
+	return err
+}
+
+// unmarshalBody decodes the first entry of the Body of a SOAP
+// message into v, after dereferencing document links with f. If v is
+// of type Entries, each entry is decoded into the element of v at the
+// same position, skipping nil elements. Entries beyond the last
+// element are decoded into it if it points to a slice, which collects
+// them, and are skipped otherwise.
+func unmarshalBody(f *Flattener, data []byte, v interface{}) error {
+	flat, err := f.Flatten(data)
+	if err != nil {
+		return err
+	}
+	targets, multi := v.(Entries)
+	if !multi {
+		targets = Entries{v}
+	}
+	d := xml.NewDecoder(bytes.NewReader(flat))
+	depth, inBody, n := 0, false, 0
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			if n == 0 {
+				return errors.New("soap: response Body is empty")
+			}
+			return nil
+		} else if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if depth == 2 && tok.Name.Local == "Body" {
+				inBody = true
+			} else if depth == 3 && inBody {
+				if !multi {
+					return d.DecodeElement(v, &tok)
+				}
+				if err := decodeEntry(d, &tok, targets, n); err != nil {
+					return err
+				}
+				depth--
+				n++
+			}
+		case xml.EndElement:
+			depth--
+			if depth == 1 {
+				inBody = false
+			}
+		}
+	}
+}
+
+// decodeEntry decodes the nth entry of a Body into its target among
+// targets, as described for unmarshalBody.
+func decodeEntry(d *xml.Decoder, start *xml.StartElement, targets Entries, n int) error {
+	var target interface{}
+	if n < len(targets) {
+		target = targets[n]
+	} else if len(targets) > 0 {
+		last := reflect.ValueOf(targets[len(targets)-1])
+		if last.Kind() == reflect.Ptr && last.Elem().Kind() == reflect.Slice &&
+			last.Elem().Type() != reflect.TypeOf([]byte(nil)) {
+			target = targets[len(targets)-1]
+		}
+	}
+	if target == nil {
+		return d.Skip()
+	}
+	return d.DecodeElement(target, start)
+}
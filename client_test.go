@@ -0,0 +1,848 @@
+package soap
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"encoding/xml"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type echoRequest struct {
+	XMLName xml.Name `xml:"urn:test Echo"`
+	Value   string   `xml:"value"`
+}
+
+type echoResponse struct {
+	XMLName xml.Name `xml:"urn:test EchoResponse"`
+	Value   string   `xml:"value"`
+}
+
+// echoHandler responds to Echo requests with the value sent,
+// resolved through a multiRef to exercise flattening.
+func echoHandler(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var msg struct {
+			Body struct {
+				Echo echoRequest
+			}
+		}
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		if err := xml.Unmarshal(data, &msg); err != nil {
+			t.Errorf("server: %v\n%s", err, data)
+		}
+		w.Header().Set("Content-Type", "text/xml")
+		io.WriteString(w, `<soapenv:Envelope xmlns:soapenv="`+NsSoapEnv+`">
+<soapenv:Body>
+  <t:EchoResponse xmlns:t="urn:test"><value href="#id0"/></t:EchoResponse>
+  <multiRef id="id0">`+msg.Body.Echo.Value+`</multiRef>
+</soapenv:Body>
+</soapenv:Envelope>`)
+	}
+}
+
+func faultHandler(code string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/xml")
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, `<soapenv:Envelope xmlns:soapenv="`+NsSoapEnv+`">
+<soapenv:Body><soapenv:Fault>
+  <faultcode>`+code+`</faultcode>
+  <faultstring>something went wrong</faultstring>
+</soapenv:Fault></soapenv:Body>
+</soapenv:Envelope>`)
+	}
+}
+
+func TestCall(t *testing.T) {
+	srv := httptest.NewServer(echoHandler(t))
+	defer srv.Close()
+
+	var traces []*CallTrace
+	c := &Client{URL: srv.URL, Trace: func(ct *CallTrace) { traces = append(traces, ct) }}
+
+	var out echoResponse
+	if err := c.Call(context.Background(), "Echo", echoRequest{Value: "hello"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Value != "hello" {
+		t.Errorf("got %q, want %q", out.Value, "hello")
+	}
+	if len(traces) != 1 {
+		t.Fatalf("got %d traces, want 1", len(traces))
+	}
+	if ct := traces[0]; ct.Action != "Echo" || ct.FirstByte <= 0 || ct.Total < ct.FirstByte {
+		t.Errorf("unexpected trace %+v", ct)
+	}
+}
+
+func TestSOAP12(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != `application/soap+xml; action="urn:Echo"; charset=utf-8` {
+			t.Errorf("Content-Type = %s", ct)
+		}
+		if !strings.HasPrefix(r.Header.Get("Accept"), "application/soap+xml") {
+			t.Errorf("Accept = %s", r.Header.Get("Accept"))
+		}
+		data, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(data), NsSoap12Env) {
+			t.Errorf("request is not a SOAP 1.2 envelope: %s", data)
+		}
+		w.Header().Set("Content-Type", "application/soap+xml; charset=utf-8")
+		io.WriteString(w, `<soap:Envelope xmlns:soap="`+NsSoap12Env+`"><soap:Body>
+<t:EchoResponse xmlns:t="urn:test"><value>hello</value></t:EchoResponse>
+</soap:Body></soap:Envelope>`)
+	}))
+	defer srv.Close()
+
+	c := &Client{URL: srv.URL, Version: V12}
+	var out echoResponse
+	if err := c.Call(context.Background(), "urn:Echo", echoRequest{Value: "hello"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Value != "hello" {
+		t.Errorf("got %q, want %q", out.Value, "hello")
+	}
+
+	html := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, "<html><body>maintenance</body></html>")
+	}))
+	defer html.Close()
+	c.URL = html.URL
+	c.Profile = &Profile{StrictMediaType: true}
+	if err := c.Call(context.Background(), "urn:Echo", echoRequest{}, &out); err == nil || !strings.Contains(err.Error(), "Content-Type") {
+		t.Errorf("text/html response: got %v, want a Content-Type error", err)
+	}
+}
+
+func TestCallFault(t *testing.T) {
+	srv := httptest.NewServer(faultHandler("soapenv:Server"))
+	defer srv.Close()
+
+	c := &Client{URL: srv.URL}
+	err := c.Call(context.Background(), "Echo", echoRequest{}, nil)
+	if f, ok := err.(*Fault); !ok || !strings.HasSuffix(f.Code, "Server") {
+		t.Errorf("expected Server fault, got %v", err)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	var calls int
+	var handler http.HandlerFunc
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		handler(w, r)
+	}))
+	defer srv.Close()
+
+	echo := echoHandler(t)
+	handler = func(w http.ResponseWriter, r *http.Request) {
+		if calls < 3 {
+			faultHandler("soapenv:Server.userException")(w, r)
+		} else {
+			echo(w, r)
+		}
+	}
+	c := &Client{URL: srv.URL, Retry: &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}}
+	var out echoResponse
+	if err := c.Call(context.Background(), "Echo", echoRequest{Value: "x"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Errorf("server called %d times, want 3", calls)
+	}
+
+	calls = 0
+	handler = faultHandler("soapenv:Client")
+	if err := c.Call(context.Background(), "Echo", echoRequest{}, nil); err == nil {
+		t.Error("expected Client fault")
+	}
+	if calls != 1 {
+		t.Errorf("server called %d times for Client fault, want 1", calls)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	for _, p := range []*RetryPolicy{
+		{BaseDelay: time.Second},
+		{BaseDelay: time.Second, MaxDelay: time.Hour},
+	} {
+		for _, attempt := range []int{1, 10, 64, 1000} {
+			d := p.delay(attempt)
+			if d < p.BaseDelay/2 || p.MaxDelay > 0 && d > p.MaxDelay {
+				t.Errorf("%+v: attempt %d: delay %v", *p, attempt, d)
+			}
+		}
+	}
+}
+
+func TestReauth(t *testing.T) {
+	var session string
+	echo := echoHandler(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Session") != session || session == "" {
+			faultHandler("sf:INVALID_SESSION_ID")(w, r)
+		} else {
+			echo(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	var logins int
+	c := &Client{
+		URL: srv.URL,
+		Reauth: map[string]func(context.Context) error{
+			"INVALID_SESSION_ID": func(context.Context) error {
+				logins++
+				session = "s1"
+				return nil
+			},
+		},
+	}
+	c.HTTPClient = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		r.Header.Set("X-Session", session)
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+	var out echoResponse
+	if err := c.Call(context.Background(), "Echo", echoRequest{Value: "x"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if logins != 1 || out.Value != "x" {
+		t.Errorf("got %d logins and value %q", logins, out.Value)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestHeaders(t *testing.T) {
+	var calls int
+	echo := echoHandler(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.UserAgent() != "test-agent/1.0" || r.Header.Get("X-Api-Key") != "k" {
+			t.Errorf("default headers not sent: %v", r.Header)
+		}
+		if calls == 1 {
+			http.SetCookie(w, &http.Cookie{Name: "JSESSIONID", Value: "abc"})
+			w.Header().Set("X-Session-Token", "t1")
+		} else {
+			if c, err := r.Cookie("JSESSIONID"); err != nil || c.Value != "abc" {
+				t.Errorf("cookie not sent: %v", err)
+			}
+			if h := r.Header.Get("X-Session-Token"); h != "t1" {
+				t.Errorf("got session header %q, want t1", h)
+			}
+		}
+		echo(w, r)
+	}))
+	defer srv.Close()
+
+	c := &Client{
+		URL:       srv.URL,
+		Session:   NewSession("X-Session-Token"),
+		UserAgent: "test-agent/1.0",
+		Header:    http.Header{"X-Api-Key": {"k"}},
+	}
+	for i := 0; i < 2; i++ {
+		if err := c.Call(context.Background(), "Echo", echoRequest{}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if v := c.Session.Header("x-session-token"); v != "t1" {
+		t.Errorf("captured %q, want t1", v)
+	}
+}
+
+func TestHedge(t *testing.T) {
+	var mu sync.Mutex
+	var calls int
+	echo := echoHandler(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls++
+		n := calls
+		mu.Unlock()
+		if n == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(300 * time.Millisecond):
+			}
+			return
+		}
+		echo(w, r)
+	}))
+	defer srv.Close()
+
+	c := &Client{
+		URL:        srv.URL,
+		Hedge:      &HedgePolicy{Delay: 20 * time.Millisecond},
+		Operations: map[string]Operation{"Echo": {Idempotent: true}},
+	}
+	start := time.Now()
+	var out echoResponse
+	if err := c.Call(context.Background(), "Echo", echoRequest{Value: "h"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond || out.Value != "h" {
+		t.Errorf("got %q after %v", out.Value, d)
+	}
+}
+
+func TestFailover(t *testing.T) {
+	dead := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer dead.Close()
+	var hits int
+	echo := echoHandler(t)
+	live := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		echo(w, r)
+	}))
+	defer live.Close()
+
+	c := &Client{Failover: NewFailover(dead.URL, live.URL)}
+	for i := 0; i < 2; i++ {
+		if err := c.Call(context.Background(), "Echo", echoRequest{}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if hits != 2 {
+		t.Errorf("live endpoint got %d requests, want 2", hits)
+	}
+	if eps := c.Failover.endpoints(); eps[0] != live.URL {
+		t.Errorf("dead endpoint not marked down: %v", eps)
+	}
+
+	err := c.Call(context.Background(), "Echo", echoRequest{}, nil, WithEndpoint(dead.URL))
+	if err, ok := err.(*StatusError); !ok || err.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("WithEndpoint: got %v, want 503", err)
+	}
+}
+
+func TestFailoverAddressTo(t *testing.T) {
+	dead := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer dead.Close()
+	var to []string
+	echo := echoHandler(t)
+	live := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := decodedBody(r.Header, r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		data, _ := io.ReadAll(body)
+		wsa, err := readAddressing(data)
+		if err != nil {
+			t.Error(err)
+		}
+		to = append(to, wsa.To)
+		r.Body = io.NopCloser(bytes.NewReader(data))
+		r.Header.Del("Content-Encoding")
+		echo(w, r)
+	}))
+	defer live.Close()
+
+	for _, compress := range []bool{false, true} {
+		to = nil
+		c := &Client{Failover: NewFailover(dead.URL, live.URL), CompressRequests: compress}
+		if err := rmCall(context.Background(), c, "Echo", echoRequest{}, nil); err != nil {
+			t.Fatal(err)
+		}
+		if len(to) != 1 || to[0] != live.URL {
+			t.Errorf("compress %v: live endpoint got requests addressed to %q, want %q", compress, to, live.URL)
+		}
+	}
+}
+
+func TestTimeouts(t *testing.T) {
+	echo := echoHandler(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("SOAPAction") == `"Slow"` {
+			time.Sleep(100 * time.Millisecond)
+		}
+		echo(w, r)
+	}))
+	defer srv.Close()
+
+	c := &Client{
+		URL:        srv.URL,
+		Timeouts:   Timeouts{ResponseHeader: 20 * time.Millisecond},
+		Operations: map[string]Operation{"Slow": {Timeouts: Timeouts{ResponseHeader: time.Second}}},
+	}
+	if err := c.Call(context.Background(), "Fast", echoRequest{}, nil); err != nil {
+		t.Errorf("Fast: %v", err)
+	}
+	if err := c.Call(context.Background(), "Slow", echoRequest{}, nil); err != nil {
+		t.Errorf("Slow: %v", err)
+	}
+	c.Operations = nil
+	err := c.Call(context.Background(), "Slow", echoRequest{}, nil)
+	if err, ok := err.(*TimeoutError); !ok || err.Phase != "response header" {
+		t.Errorf("got %v, want response header timeout", err)
+	}
+}
+
+func TestCompression(t *testing.T) {
+	echo := echoHandler(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "gzip" {
+			t.Error("request not compressed")
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Body = io.NopCloser(zr)
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		defer zw.Close()
+		echo(gzipResponseWriter{w, zw}, r)
+	}))
+	defer srv.Close()
+
+	// Setting Accept-Encoding disables transparent decompression
+	// in http.Transport.
+	c := &Client{URL: srv.URL, CompressRequests: true}
+	c.HTTPClient = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		r.Header.Set("Accept-Encoding", "gzip")
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+	var out echoResponse
+	if err := c.Call(context.Background(), "Echo", echoRequest{Value: "z"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Value != "z" {
+		t.Errorf("got %q, want z", out.Value)
+	}
+}
+
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	zw *gzip.Writer
+}
+
+func (w gzipResponseWriter) Write(b []byte) (int, error) { return w.zw.Write(b) }
+
+func TestStreamRequests(t *testing.T) {
+	var calls int
+	echo := echoHandler(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.ContentLength != -1 {
+			t.Errorf("streamed request has Content-Length %d", r.ContentLength)
+		}
+		if calls == 1 {
+			faultHandler("soapenv:Server")(w, r)
+			return
+		}
+		echo(w, r)
+	}))
+	defer srv.Close()
+
+	c := &Client{
+		URL:        srv.URL,
+		Retry:      &RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond},
+		Operations: map[string]Operation{"Echo": {Stream: true}},
+	}
+	var out echoResponse
+	if err := c.Call(context.Background(), "Echo", echoRequest{Value: "s"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Value != "s" || calls != 2 {
+		t.Errorf("got %q after %d calls", out.Value, calls)
+	}
+}
+
+func TestGet(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			t.Errorf("got %s request, want GET", r.Method)
+		}
+		if a := r.Header.Get("Accept"); a != "application/soap+xml" {
+			t.Errorf("Accept = %s", a)
+		}
+		w.Header().Set("Content-Type", "application/soap+xml")
+		io.WriteString(w, `<soap:Envelope xmlns:soap="`+NsSoap12Env+`"><soap:Body>
+<t:EchoResponse xmlns:t="urn:test"><value>`+r.URL.Query().Get("value")+`</value></t:EchoResponse>
+</soap:Body></soap:Envelope>`)
+	}))
+	defer srv.Close()
+
+	c := &Client{URL: srv.URL + "/echo?lang=en"}
+	var out echoResponse
+	if err := c.Get(context.Background(), "Echo", url.Values{"value": {"hello"}}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Value != "hello" {
+		t.Errorf("got %q, want %q", out.Value, "hello")
+	}
+}
+
+func TestSend(t *testing.T) {
+	var status int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		if status == http.StatusInternalServerError {
+			faultHandler("soapenv:Client")(w, r)
+			return
+		}
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	c := &Client{URL: srv.URL}
+	for _, status = range []int{http.StatusAccepted, http.StatusNoContent} {
+		if err := c.Send(context.Background(), "Notify", echoRequest{}); err != nil {
+			t.Errorf("status %d: %v", status, err)
+		}
+	}
+	status = http.StatusInternalServerError
+	if err, ok := c.Send(context.Background(), "Notify", echoRequest{}).(*Fault); !ok {
+		t.Errorf("got %v, want *Fault", err)
+	}
+}
+
+func TestCallAsync(t *testing.T) {
+	var mu sync.Mutex
+	var active, peak int
+	echo := echoHandler(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		if active++; active > peak {
+			peak = active
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		echo(w, r)
+		mu.Lock()
+		active--
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	c := &Client{URL: srv.URL, MaxAsync: 3}
+	out := make([]echoResponse, 10)
+	futures := make([]*Future, len(out))
+	for i := range out {
+		futures[i] = c.CallAsync(context.Background(), "Echo", echoRequest{Value: strconv.Itoa(i)}, &out[i])
+	}
+	for i, f := range futures {
+		if err := f.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if out[i].Value != strconv.Itoa(i) {
+			t.Errorf("call %d: got %q", i, out[i].Value)
+		}
+	}
+	if peak > c.MaxAsync {
+		t.Errorf("%d calls in progress, want at most %d", peak, c.MaxAsync)
+	}
+}
+
+func TestProfile(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, `<soapenv:Envelope xmlns:soapenv="`+NsSoapEnv+`"><soapenv:Body>
+<EchoResponse><soapenv:Fault><faultcode>soapenv:Server</faultcode><faultstring>backend down</faultstring></soapenv:Fault></EchoResponse>
+</soapenv:Body></soapenv:Envelope>`)
+	}))
+	defer srv.Close()
+
+	c := &Client{URL: srv.URL}
+	err := c.Call(context.Background(), "Echo", echoRequest{Value: "x"}, new(echoResponse))
+	if _, ok := err.(*Fault); ok || err == nil {
+		t.Errorf("default profile: got %v, want media type error", err)
+	}
+	c.Profile = SAPPI
+	err = c.Call(context.Background(), "Echo", echoRequest{Value: "x"}, new(echoResponse))
+	if f, ok := err.(*Fault); !ok || f.String != "backend down" {
+		t.Errorf("SAP PI profile: got %v, want fault", err)
+	}
+
+	ts, err := DotNetWSE.ParseTime("2024-03-01T12:30:00.1234567")
+	if err != nil || ts.Nanosecond() != 123456700 {
+		t.Errorf("got %v, %v", ts, err)
+	}
+	if s := SAPPI.FormatTime(ts); s != "2024-03-01T12:30:00" {
+		t.Errorf("formatted as %s", s)
+	}
+}
+
+func TestCache(t *testing.T) {
+	var hits int
+	echo := echoHandler(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		echo(w, r)
+	}))
+	defer srv.Close()
+
+	cache := NewMemoryCache(1)
+	c := &Client{
+		URL:        srv.URL,
+		Cache:      cache,
+		Operations: map[string]Operation{"Echo": {Idempotent: true, CacheTTL: time.Minute}},
+	}
+	for _, v := range []string{"a", "a", "b", "b", "a"} {
+		var out echoResponse
+		if err := c.Call(context.Background(), "Echo", echoRequest{Value: v}, &out); err != nil {
+			t.Fatal(err)
+		}
+		if out.Value != v {
+			t.Errorf("got %q, want %q", out.Value, v)
+		}
+	}
+	// "a" is evicted by "b" from the single-entry cache
+	if hits != 3 {
+		t.Errorf("service got %d requests, want 3", hits)
+	}
+	if n := cache.Len(); n != 1 {
+		t.Errorf("cache holds %d entries, want 1", n)
+	}
+
+	k1, err := cacheKey("", "Echo", []byte(`<s:Envelope xmlns:s="`+NsSoapEnv+`"><s:Header><id>1</id></s:Header>`+
+		`<s:Body><a:Get xmlns:a="urn:x" one="1" two="2"> <a:Key>k</a:Key> </a:Get></s:Body></s:Envelope>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	k2, err := cacheKey("", "Echo", []byte(`<Envelope xmlns="`+NsSoapEnv+`"><Header><id>2</id></Header>`+
+		`<Body><Get xmlns="urn:x" two="2" one="1"><Key>k</Key></Get></Body></Envelope>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k1 != k2 {
+		t.Error("equivalent requests have different cache keys")
+	}
+
+	typed := func(ns, decl string) string {
+		key, err := cacheKey("", "Echo", []byte(`<s:Envelope xmlns:s="`+NsSoapEnv+`" xmlns:t="`+ns+`"><s:Body>`+
+			`<Get xmlns:xsi="`+NsXSI+`"`+decl+` xsi:type="t:Ref">k</Get></s:Body></s:Envelope>`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	if typed("urn:a", "") == typed("urn:b", "") {
+		t.Error("requests with xsi:type in different namespaces share a cache key")
+	}
+	if typed("urn:a", "") != typed("urn:b", ` xmlns:t="urn:a"`) {
+		t.Error("requests with the same xsi:type have different cache keys")
+	}
+}
+
+func TestCallEntries(t *testing.T) {
+	type status struct {
+		Code string `xml:"code"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/xml")
+		io.WriteString(w, `<s:Envelope xmlns:s="`+NsSoapEnv+`"><s:Body>
+<t:EchoResponse xmlns:t="urn:test"><value>v</value></t:EchoResponse>
+<Status><code>1</code></Status>
+<Status><code>2</code></Status>
+</s:Body></s:Envelope>`)
+	}))
+	defer srv.Close()
+
+	c := &Client{URL: srv.URL}
+	var out echoResponse
+	var statuses []status
+	if err := c.Call(context.Background(), "Echo", echoRequest{}, Entries{&out, &statuses}); err != nil {
+		t.Fatal(err)
+	}
+	if out.Value != "v" || len(statuses) != 2 || statuses[1].Code != "2" {
+		t.Errorf("got %+v and %+v", out, statuses)
+	}
+
+	var first status
+	if err := c.Call(context.Background(), "Echo", echoRequest{}, Entries{nil, &first}); err != nil {
+		t.Fatal(err)
+	}
+	if first.Code != "1" {
+		t.Errorf("got status %+v, want code 1", first)
+	}
+}
+
+func TestClone(t *testing.T) {
+	var tenants []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenants = append(tenants, r.Header.Get("X-Tenant"))
+		echoHandler(t)(w, r)
+	}))
+	defer srv.Close()
+
+	var dials int32
+	base := &Client{
+		URL:        srv.URL,
+		Header:     http.Header{"X-Tenant": {"base"}},
+		Operations: map[string]Operation{"Echo": {Idempotent: true}},
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+	child := base.Clone()
+	child.Header.Set("X-Tenant", "child")
+	child.Operations["Echo"] = Operation{}
+
+	for _, c := range []*Client{base, child} {
+		var out echoResponse
+		if err := c.Call(context.Background(), "Echo", echoRequest{Value: "hi"}, &out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := strings.Join(tenants, " "); got != "base child" {
+		t.Errorf("requests sent for tenants %q, want %q", got, "base child")
+	}
+	if !base.Operations["Echo"].Idempotent {
+		t.Error("changing the clone's Operations changed the base Client")
+	}
+	if n := atomic.LoadInt32(&dials); n != 1 {
+		t.Errorf("%d connections opened, want 1 shared by both Clients", n)
+	}
+}
+
+func TestResponseHeader(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/xml")
+		io.WriteString(w, `<s:Envelope xmlns:s="`+NsSoapEnv+`" xmlns:wsa="`+NsWSA+`"><s:Header>
+<wsa:MessageID s:mustUnderstand="1"> urn:uuid:1 </wsa:MessageID>
+<t:Session xmlns:t="urn:test" t:expires="60"><t:id>abc</t:id></t:Session>
+</s:Header><s:Body><t:EchoResponse xmlns:t="urn:test"><value>v</value></t:EchoResponse></s:Body></s:Envelope>`)
+	}))
+	defer srv.Close()
+
+	c := &Client{URL: srv.URL}
+	var h Header
+	if err := c.Call(context.Background(), "Echo", echoRequest{}, &echoResponse{}, WithResponseHeader(&h)); err != nil {
+		t.Fatal(err)
+	}
+	if len(h) != 2 {
+		t.Fatalf("got %d header entries, want 2", len(h))
+	}
+	id := h.Get(NsWSA, "MessageID")
+	if id == nil {
+		t.Fatal("no MessageID header")
+	}
+	if got := id.Text(); got != "urn:uuid:1" {
+		t.Errorf("MessageID is %q, want %q", got, "urn:uuid:1")
+	}
+	if !id.MustUnderstand() {
+		t.Error("MessageID is not marked mustUnderstand")
+	}
+	session := h.Get("", "Session")
+	if session == nil {
+		t.Fatal("no Session header")
+	}
+	if v, ok := session.Attr("urn:test", "expires"); !ok || v != "60" {
+		t.Errorf("expires attribute is %q, %v; want %q", v, ok, "60")
+	}
+	if session.MustUnderstand() {
+		t.Error("Session is marked mustUnderstand")
+	}
+	if h.Get("urn:other", "Session") != nil {
+		t.Error("Get matched an entry in another namespace")
+	}
+}
+
+func TestContentEncoding(t *testing.T) {
+	RegisterDecoder("x-reverse", func(r io.Reader) (io.Reader, error) {
+		data, err := io.ReadAll(r)
+		for i, j := 0, len(data)-1; i < j; i, j = i+1, j-1 {
+			data[i], data[j] = data[j], data[i]
+		}
+		return bytes.NewReader(data), err
+	})
+	if ae := AcceptEncoding(); !strings.Contains(ae, "deflate") || !strings.Contains(ae, "x-reverse") {
+		t.Errorf("Accept-Encoding %q", ae)
+	}
+	msg := `<soapenv:Envelope xmlns:soapenv="` + NsSoapEnv + `"><soapenv:Body><value>42</value></soapenv:Body></soapenv:Envelope>`
+	for _, raw := range []bool{false, true} {
+		var buf bytes.Buffer
+		var zw io.WriteCloser
+		if raw {
+			zw, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+		} else {
+			zw = zlib.NewWriter(&buf)
+		}
+		io.WriteString(zw, msg)
+		zw.Close()
+		// reverse the deflated bytes, to be decoded after inflating
+		data := buf.Bytes()
+		for i, j := 0, len(data)-1; i < j; i, j = i+1, j-1 {
+			data[i], data[j] = data[j], data[i]
+		}
+		resp := &http.Response{
+			Header: http.Header{"Content-Type": {"text/xml"}, "Content-Encoding": {"deflate, x-reverse"}},
+			Body:   io.NopCloser(bytes.NewReader(data)),
+		}
+		var v struct {
+			Value int `xml:"Body>value"`
+		}
+		if err := Parse(resp, &v); err != nil || v.Value != 42 {
+			t.Errorf("raw deflate %v: got %d, %v", raw, v.Value, err)
+		}
+	}
+
+	resp := &http.Response{
+		Header: http.Header{"Content-Encoding": {"compress"}},
+		Body:   io.NopCloser(strings.NewReader(msg)),
+	}
+	if err := Parse(resp, new(struct{})); err == nil {
+		t.Error("unsupported encoding accepted")
+	}
+}
+
+func TestRegisteredDecoderCall(t *testing.T) {
+	reverse := func(data []byte) []byte {
+		for i, j := 0, len(data)-1; i < j; i, j = i+1, j-1 {
+			data[i], data[j] = data[j], data[i]
+		}
+		return data
+	}
+	// a stand-in for a Brotli decoder, which the standard library lacks
+	RegisterDecoder("br", func(r io.Reader) (io.Reader, error) {
+		data, err := io.ReadAll(r)
+		return bytes.NewReader(reverse(data)), err
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ae := r.Header.Get("Accept-Encoding"); !strings.Contains(ae, "br") || !strings.Contains(ae, "gzip") {
+			t.Errorf("Accept-Encoding = %q", ae)
+		}
+		w.Header().Set("Content-Type", "text/xml")
+		w.Header().Set("Content-Encoding", "br")
+		w.Write(reverse([]byte(`<s:Envelope xmlns:s="` + NsSoapEnv + `"><s:Body>
+<t:EchoResponse xmlns:t="urn:test"><value>compressed</value></t:EchoResponse></s:Body></s:Envelope>`)))
+	}))
+	defer srv.Close()
+
+	var out echoResponse
+	if err := (&Client{URL: srv.URL}).Call(context.Background(), "Echo", echoRequest{}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Value != "compressed" {
+		t.Errorf("got %q, want %q", out.Value, "compressed")
+	}
+}
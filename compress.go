@@ -0,0 +1,110 @@
+package soap
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// decodedBody returns a reader for a message body with any
+// Content-Encoding given in h removed. Responses are normally
+// decompressed by http.Transport, but not when the Accept-Encoding
+// header was set explicitly or the Transport does not handle
+// compression. Encodings without a registered decoder are an error.
+func decodedBody(h http.Header, body io.Reader) (io.Reader, error) {
+	codings := strings.Split(h.Get("Content-Encoding"), ",")
+	for i := len(codings) - 1; i >= 0; i-- {
+		coding := strings.ToLower(strings.TrimSpace(codings[i]))
+		if coding == "" || coding == "identity" {
+			continue
+		}
+		decodersMu.RLock()
+		decode, ok := decoders[coding]
+		decodersMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("soap: unsupported Content-Encoding %q", coding)
+		}
+		var err error
+		if body, err = decode(body); err != nil {
+			return nil, err
+		}
+	}
+	return body, nil
+}
+
+var (
+	decodersMu sync.RWMutex
+	decoders   = map[string]func(io.Reader) (io.Reader, error){
+		"gzip":    gunzip,
+		"x-gzip":  gunzip,
+		"deflate": inflate,
+	}
+)
+
+// RegisterDecoder registers a function decoding message bodies with
+// the given Content-Encoding, in addition to the gzip and deflate
+// encodings decoded by the package. Clients offer every registered
+// encoding in the Accept-Encoding header of their requests, as
+// returned by AcceptEncoding, and decode responses in any of them.
+// Brotli ("br") is not decoded by default, as the standard library has no Brotli decoder and the
+// package takes on no dependencies; programs receiving it register
+// one, such as the reader of github.com/andybalholm/brotli:
+//
+//	soap.RegisterDecoder("br", func(r io.Reader) (io.Reader, error) {
+//		return brotli.NewReader(r), nil
+//	})
+func RegisterDecoder(encoding string, decode func(io.Reader) (io.Reader, error)) {
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+	decoders[strings.ToLower(encoding)] = decode
+}
+
+// AcceptEncoding returns the Content-Encodings that can be decoded,
+// as a value for the Accept-Encoding header of requests. Setting the
+// header disables the transparent decompression of http.Transport,
+// leaving responses to be decoded by the package.
+func AcceptEncoding() string {
+	decodersMu.RLock()
+	defer decodersMu.RUnlock()
+	var codings []string
+	for coding := range decoders {
+		codings = append(codings, coding)
+	}
+	sort.Strings(codings)
+	return strings.Join(codings, ", ")
+}
+
+func gunzip(r io.Reader) (io.Reader, error) {
+	return gzip.NewReader(r)
+}
+
+// inflate decodes the deflate encoding, which is specified as the
+// zlib format, but is sent as raw deflate data by some servers.
+func inflate(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	if h, err := br.Peek(2); err == nil && h[0]&0x0f == 8 && (uint16(h[0])<<8|uint16(h[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+// gzipBytes returns the gzip compression of b.
+func gzipBytes(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(b); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,126 @@
+package soap
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrNoReply is the error of a request tracked by a Correlator that
+// received no reply within the Correlator's Timeout.
+var ErrNoReply = errors.New("soap: no reply received")
+
+// A Correlator tracks outstanding requests of asynchronous message
+// exchanges, and matches inbound messages to them by their
+// WS-Addressing RelatesTo header. The zero value is ready to use.
+type Correlator struct {
+	// Timeout bounds the time to wait for the reply to a
+	// request. If zero, requests wait until they are canceled.
+	Timeout time.Duration
+
+	// Flattener dereferences the document links of replies.
+	// If nil, DefaultFlattener is used.
+	Flattener *Flattener
+
+	mu      sync.Mutex
+	pending map[string]*pendingReply
+}
+
+type pendingReply struct {
+	resp   interface{}
+	flat   *Flattener
+	future *Future
+	timer  *time.Timer
+}
+
+// Track generates a message ID for a new request, and returns it
+// along with a Future that completes when the reply to the request
+// is delivered, once its Body has been decoded into resp. If resp
+// is nil, the Body of the reply is discarded. The message ID must
+// be sent as the MessageID header of the request.
+func (c *Correlator) Track(resp interface{}) (messageID string, f *Future) {
+	return c.track(resp, c.Flattener)
+}
+
+// track is Track, decoding the reply with flat, or DefaultFlattener
+// if it is nil.
+func (c *Correlator) track(resp interface{}, flat *Flattener) (string, *Future) {
+	if flat == nil {
+		flat = DefaultFlattener
+	}
+	id := NewMessageID()
+	p := &pendingReply{resp: resp, flat: flat, future: newFuture()}
+	c.mu.Lock()
+	if c.pending == nil {
+		c.pending = make(map[string]*pendingReply)
+	}
+	c.pending[id] = p
+	if c.Timeout > 0 {
+		p.timer = time.AfterFunc(c.Timeout, func() { c.Cancel(id, ErrNoReply) })
+	}
+	c.mu.Unlock()
+	return id, p.future
+}
+
+// Cancel stops tracking the request with the given message ID,
+// completing its Future with err. It reports whether the request
+// was outstanding.
+func (c *Correlator) Cancel(messageID string, err error) bool {
+	if p := c.take(messageID); p != nil {
+		p.future.complete(err)
+		return true
+	}
+	return false
+}
+
+// Deliver matches a SOAP message to the request named by its
+// RelatesTo header, and completes the request's Future with the
+// message's Fault, if any, or the result of decoding its Body. It
+// reports whether the message related to an outstanding request;
+// an error is returned only if the message cannot be parsed.
+func (c *Correlator) Deliver(data []byte) (bool, error) {
+	wsa, err := readAddressing(data)
+	if err != nil {
+		return false, err
+	}
+	fault := checkFault(data)
+	if _, ok := fault.(*Fault); fault != nil && !ok {
+		return false, fault
+	}
+	p := c.take(wsa.RelatesTo)
+	if p == nil {
+		return false, nil
+	}
+	switch {
+	case fault != nil:
+		p.future.complete(fault)
+	case p.resp != nil:
+		p.future.complete(unmarshalBody(p.flat, data, p.resp))
+	default:
+		p.future.complete(nil)
+	}
+	return true, nil
+}
+
+// Len returns the number of outstanding requests.
+func (c *Correlator) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.pending)
+}
+
+// take removes and returns the outstanding request with the given
+// message ID, or nil if there is none.
+func (c *Correlator) take(id string) *pendingReply {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p := c.pending[id]
+	if p == nil {
+		return nil
+	}
+	delete(c.pending, id)
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	return p
+}
@@ -0,0 +1,247 @@
+package soap
+
+import (
+	"encoding/xml"
+	"fmt"
+	"reflect"
+)
+
+// Namespaces of the .NET DataSet serialization.
+const (
+	NsDiffgram = "urn:schemas-microsoft-com:xml-diffgram-v1"
+	NsMSData   = "urn:schemas-microsoft-com:xml-msdata"
+)
+
+// A DataSet is a .NET DataSet, as returned by ASP.NET web services
+// with methods returning System.Data.DataSet. These serialize it as
+// an inline XML Schema describing its tables, followed by a diffgram
+// holding their rows. A DataSet is decoded from the element that
+// contains both, usually named after the method's result:
+//
+//	var resp struct {
+//		Result soap.DataSet `xml:"GetCustomersResult"`
+//	}
+//
+// Only the current version of each row is decoded. The original
+// versions of modified rows, and deleted rows, kept in the
+// diffgram's before section, are ignored.
+type DataSet struct {
+	// Name is the name of the DataSet, such as "NewDataSet".
+	Name string
+
+	// Tables holds the tables of the DataSet, in the order they
+	// are declared in the schema, followed by any tables with
+	// rows that the schema does not declare.
+	Tables []*DataTable
+}
+
+// A DataTable is a table of a DataSet.
+type DataTable struct {
+	Name string
+
+	// Columns holds the columns declared in the schema for the
+	// table.
+	Columns []DataColumn
+
+	Rows []DataRow
+}
+
+// A DataColumn describes a column of a DataTable.
+type DataColumn struct {
+	Name string
+
+	// Type is the XML Schema type of the column, such as
+	// "xs:string" or "xs:int".
+	Type string
+}
+
+// A DataRow is a row of a DataTable.
+type DataRow struct {
+	// ID is the diffgr:id of the row, such as "Table1".
+	ID string
+
+	// State is the diffgr:hasChanges attribute of the row:
+	// "inserted", "modified", or empty for an unchanged row.
+	State string
+
+	// Values holds the values of the row, by column name. The
+	// values of null columns are absent.
+	Values map[string]string
+
+	raw []byte
+}
+
+// Table returns the table with the given name, or nil.
+func (ds *DataSet) Table(name string) *DataTable {
+	for _, t := range ds.Tables {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// Decode decodes the rows of the table into v, which must be a
+// pointer to a slice. Each row is decoded into a new element of the
+// slice with xml.Unmarshal, so struct fields are matched to columns
+// by their tags.
+func (t *DataTable) Decode(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("soap: cannot decode table %s into %T", t.Name, v)
+	}
+	slice := rv.Elem()
+	for _, row := range t.Rows {
+		elem := reflect.New(slice.Type().Elem())
+		if err := xml.Unmarshal(row.raw, elem.Interface()); err != nil {
+			return err
+		}
+		slice.Set(reflect.Append(slice, elem.Elem()))
+	}
+	return nil
+}
+
+type dataSetSchema struct {
+	Elements []struct {
+		Name   string `xml:"name,attr"`
+		Tables []struct {
+			Name    string `xml:"name,attr"`
+			Columns []struct {
+				Name string `xml:"name,attr"`
+				Type string `xml:"type,attr"`
+			} `xml:"complexType>sequence>element"`
+		} `xml:"complexType>choice>element"`
+	} `xml:"element"`
+}
+
+type dataRow struct {
+	Attr   []xml.Attr `xml:",any,attr"`
+	Fields []struct {
+		XMLName xml.Name
+		Value   string `xml:",chardata"`
+	} `xml:",any"`
+}
+
+// UnmarshalXML decodes a DataSet from the element containing its
+// schema and diffgram.
+func (ds *DataSet) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*ds = DataSet{}
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			var err error
+			switch {
+			case tok.Name.Space == NsXSD && tok.Name.Local == "schema":
+				err = ds.decodeSchema(d, tok)
+			case tok.Name.Space == NsDiffgram && tok.Name.Local == "diffgram":
+				err = ds.decodeDiffgram(d)
+			default:
+				err = d.Skip()
+			}
+			if err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+func (ds *DataSet) decodeSchema(d *xml.Decoder, start xml.StartElement) error {
+	var schema dataSetSchema
+	if err := d.DecodeElement(&schema, &start); err != nil {
+		return err
+	}
+	for _, el := range schema.Elements {
+		if ds.Name == "" {
+			ds.Name = el.Name
+		}
+		for _, t := range el.Tables {
+			table := ds.table(t.Name)
+			for _, c := range t.Columns {
+				table.Columns = append(table.Columns, DataColumn{Name: c.Name, Type: c.Type})
+			}
+		}
+	}
+	return nil
+}
+
+// table returns the table with the given name, adding it if the
+// DataSet has none.
+func (ds *DataSet) table(name string) *DataTable {
+	if t := ds.Table(name); t != nil {
+		return t
+	}
+	t := &DataTable{Name: name}
+	ds.Tables = append(ds.Tables, t)
+	return t
+}
+
+// decodeDiffgram decodes the current rows of the diffgram, whose
+// start element has been read from d.
+func (ds *DataSet) decodeDiffgram(d *xml.Decoder) error {
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if tok.Name.Space == NsDiffgram {
+				// the before and errors sections
+				if err := d.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			ds.Name = tok.Name.Local
+			if err := ds.decodeRows(d); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+func (ds *DataSet) decodeRows(d *xml.Decoder) error {
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			raw, err := copyElement(d, tok)
+			if err != nil {
+				return err
+			}
+			var r dataRow
+			if err := xml.Unmarshal(raw, &r); err != nil {
+				return err
+			}
+			row := DataRow{Values: make(map[string]string), raw: raw}
+			for _, a := range r.Attr {
+				if a.Name.Space == NsDiffgram {
+					switch a.Name.Local {
+					case "id":
+						row.ID = a.Value
+					case "hasChanges":
+						row.State = a.Value
+					}
+				}
+			}
+			for _, f := range r.Fields {
+				row.Values[f.XMLName.Local] = f.Value
+			}
+			table := ds.table(tok.Name.Local)
+			table.Rows = append(table.Rows, row)
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
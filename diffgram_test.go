@@ -0,0 +1,64 @@
+package soap
+
+import "testing"
+
+const diffgramResponse = `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+<soap:Body><GetCustomersResponse xmlns="http://tempuri.org/"><GetCustomersResult>
+<xs:schema id="NewDataSet" xmlns="" xmlns:xs="http://www.w3.org/2001/XMLSchema" xmlns:msdata="urn:schemas-microsoft-com:xml-msdata">
+  <xs:element name="NewDataSet" msdata:IsDataSet="true"><xs:complexType><xs:choice minOccurs="0" maxOccurs="unbounded">
+    <xs:element name="Customers"><xs:complexType><xs:sequence>
+      <xs:element name="ID" type="xs:int" minOccurs="0"/>
+      <xs:element name="Name" type="xs:string" minOccurs="0"/>
+      <xs:element name="Email" type="xs:string" minOccurs="0"/>
+    </xs:sequence></xs:complexType></xs:element>
+  </xs:choice></xs:complexType></xs:element>
+</xs:schema>
+<diffgr:diffgram xmlns:msdata="urn:schemas-microsoft-com:xml-msdata" xmlns:diffgr="urn:schemas-microsoft-com:xml-diffgram-v1">
+  <NewDataSet xmlns="">
+    <Customers diffgr:id="Customers1" msdata:rowOrder="0"><ID>1</ID><Name>Alice</Name><Email>alice@example.com</Email></Customers>
+    <Customers diffgr:id="Customers2" msdata:rowOrder="1" diffgr:hasChanges="modified"><ID>2</ID><Name>Bob</Name></Customers>
+  </NewDataSet>
+  <diffgr:before>
+    <Customers diffgr:id="Customers2" msdata:rowOrder="1"><ID>2</ID><Name>Robert</Name></Customers>
+  </diffgr:before>
+</diffgr:diffgram>
+</GetCustomersResult></GetCustomersResponse></soap:Body></soap:Envelope>`
+
+func TestDataSet(t *testing.T) {
+	var resp struct {
+		Result DataSet `xml:"Body>GetCustomersResponse>GetCustomersResult"`
+	}
+	if err := Unmarshal([]byte(diffgramResponse), &resp); err != nil {
+		t.Fatal(err)
+	}
+	ds := resp.Result
+	table := ds.Table("Customers")
+	if ds.Name != "NewDataSet" || len(ds.Tables) != 1 || table == nil {
+		t.Fatalf("got %+v", ds)
+	}
+	if len(table.Columns) != 3 || table.Columns[0] != (DataColumn{"ID", "xs:int"}) {
+		t.Errorf("got columns %+v", table.Columns)
+	}
+	if len(table.Rows) != 2 {
+		t.Fatalf("got %d rows", len(table.Rows))
+	}
+	bob := table.Rows[1]
+	if bob.ID != "Customers2" || bob.State != "modified" || bob.Values["Name"] != "Bob" {
+		t.Errorf("got row %+v", bob)
+	}
+	if _, ok := bob.Values["Email"]; ok {
+		t.Error("null column has a value")
+	}
+
+	var customers []struct {
+		ID    int    `xml:"ID"`
+		Name  string `xml:"Name"`
+		Email string `xml:"Email"`
+	}
+	if err := table.Decode(&customers); err != nil {
+		t.Fatal(err)
+	}
+	if len(customers) != 2 || customers[0].ID != 1 || customers[0].Email != "alice@example.com" || customers[1].Name != "Bob" {
+		t.Errorf("decoded %+v", customers)
+	}
+}
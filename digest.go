@@ -0,0 +1,335 @@
+package soap
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DigestTransport is an http.RoundTripper that authenticates requests
+// using HTTP Digest authentication, as described in RFC 7616. The MD5,
+// SHA-256 and SHA-512-256 algorithms and their session variants are
+// supported, with the "auth" and "auth-int" qualities of protection.
+//
+// Once a server has issued a challenge, later requests are
+// authenticated preemptively using the same nonce, until the server
+// reports it is stale. Requests whose bodies cannot be rewound, because
+// GetBody is nil, are sent only once; if the server challenges them,
+// the 401 response is returned. A DigestTransport is safe for
+// concurrent use.
+type DigestTransport struct {
+	Username, Password string
+
+	// Transport is used to send requests. If nil,
+	// http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	mu   sync.Mutex
+	chal *digestChallenge
+	nc   uint32
+}
+
+type digestChallenge struct {
+	realm, nonce, opaque, algorithm, qop string
+	userhash                             bool
+}
+
+func (t *DigestTransport) transport() http.RoundTripper {
+	if t.Transport != nil {
+		return t.Transport
+	}
+	return http.DefaultTransport
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *DigestTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	chal := t.chal
+	t.mu.Unlock()
+
+	first := req
+	if chal != nil {
+		var err error
+		if first, err = t.authorize(req, chal); err != nil {
+			return nil, err
+		}
+	}
+	rsp, err := t.transport().RoundTrip(first)
+	if err != nil || rsp.StatusCode != http.StatusUnauthorized {
+		return rsp, err
+	}
+	next := parseDigestChallenge(rsp.Header.Values("WWW-Authenticate"))
+	if next == nil || (req.Body != nil && req.GetBody == nil) {
+		return rsp, nil
+	}
+	io.Copy(io.Discard, rsp.Body)
+	rsp.Body.Close()
+
+	t.mu.Lock()
+	t.chal, t.nc = next, 0
+	t.mu.Unlock()
+
+	retry, err := t.authorize(req, next)
+	if err != nil {
+		return nil, err
+	}
+	return t.transport().RoundTrip(retry)
+}
+
+// authorize returns a copy of req with an Authorization header
+// answering chal, and a fresh body.
+func (t *DigestTransport) authorize(req *http.Request, chal *digestChallenge) (*http.Request, error) {
+	r := req.Clone(req.Context())
+	var body []byte
+	if req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = rc
+		if chal.qop == "auth-int" {
+			if body, err = io.ReadAll(rc); err != nil {
+				return nil, err
+			}
+			rc.Close()
+			if r.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	t.mu.Lock()
+	t.nc++
+	nc := t.nc
+	t.mu.Unlock()
+
+	cnonce, err := newCnonce()
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Authorization", chal.authorization(t.Username, t.Password,
+		req.Method, req.URL.RequestURI(), body, nc, cnonce))
+	return r, nil
+}
+
+func newCnonce() (string, error) {
+	var b [24]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b[:]), nil
+}
+
+func (c *digestChallenge) hash() func() hash.Hash {
+	switch strings.TrimSuffix(strings.ToUpper(c.algorithm), "-SESS") {
+	case "SHA-256":
+		return sha256.New
+	case "SHA-512-256":
+		return sha512.New512_256
+	}
+	return md5.New
+}
+
+// authorization computes the credentials answering the challenge.
+func (c *digestChallenge) authorization(username, password, method, uri string, body []byte, nc uint32, cnonce string) string {
+	newHash := c.hash()
+	h := func(s string) string {
+		d := newHash()
+		io.WriteString(d, s)
+		return hex.EncodeToString(d.Sum(nil))
+	}
+
+	ha1 := h(username + ":" + c.realm + ":" + password)
+	if strings.HasSuffix(strings.ToUpper(c.algorithm), "-SESS") {
+		ha1 = h(ha1 + ":" + c.nonce + ":" + cnonce)
+	}
+	a2 := method + ":" + uri
+	if c.qop == "auth-int" {
+		a2 += ":" + h(string(body))
+	}
+	ncs := fmt.Sprintf("%08x", nc)
+
+	var response string
+	if c.qop == "" {
+		response = h(ha1 + ":" + c.nonce + ":" + h(a2))
+	} else {
+		response = h(strings.Join([]string{ha1, c.nonce, ncs, cnonce, c.qop, h(a2)}, ":"))
+	}
+	if c.userhash {
+		username = h(username + ":" + c.realm)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Digest username=%s, realm=%s, uri=%s", quote(username), quote(c.realm), quote(uri))
+	if c.algorithm != "" {
+		fmt.Fprintf(&b, ", algorithm=%s", c.algorithm)
+	}
+	fmt.Fprintf(&b, ", nonce=%s", quote(c.nonce))
+	if c.qop != "" {
+		fmt.Fprintf(&b, ", nc=%s, cnonce=%s, qop=%s", ncs, quote(cnonce), c.qop)
+	}
+	fmt.Fprintf(&b, ", response=%s", quote(response))
+	if c.opaque != "" {
+		fmt.Fprintf(&b, ", opaque=%s", quote(c.opaque))
+	}
+	if c.userhash {
+		b.WriteString(", userhash=true")
+	}
+	return b.String()
+}
+
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// parseDigestChallenge selects the strongest Digest challenge from
+// WWW-Authenticate header values, or returns nil if there is none.
+func parseDigestChallenge(headers []string) *digestChallenge {
+	var best *digestChallenge
+	rank := func(c *digestChallenge) int {
+		switch strings.TrimSuffix(strings.ToUpper(c.algorithm), "-SESS") {
+		case "", "MD5":
+			return 1
+		case "SHA-256":
+			return 2
+		case "SHA-512-256":
+			return 3
+		}
+		return 0
+	}
+	for _, h := range headers {
+		for _, ch := range parseChallenges(h) {
+			if !strings.EqualFold(ch.scheme, "Digest") {
+				continue
+			}
+			c := &digestChallenge{
+				realm:     ch.params["realm"],
+				nonce:     ch.params["nonce"],
+				opaque:    ch.params["opaque"],
+				algorithm: ch.params["algorithm"],
+				userhash:  strings.EqualFold(ch.params["userhash"], "true"),
+			}
+			if qop, ok := ch.params["qop"]; ok {
+				for _, q := range strings.Split(qop, ",") {
+					q = strings.TrimSpace(q)
+					if q == "auth" || (q == "auth-int" && c.qop == "") {
+						c.qop = q
+					}
+				}
+				if c.qop == "" {
+					continue
+				}
+			}
+			if rank(c) > 0 && (best == nil || rank(c) > rank(best)) {
+				best = c
+			}
+		}
+	}
+	return best
+}
+
+// An authChallenge is a challenge from a WWW-Authenticate header.
+type authChallenge struct {
+	scheme string
+	token  string // token68 form, as used by Negotiate and NTLM
+	params map[string]string
+}
+
+// parseChallenges parses a WWW-Authenticate header value, which
+// may hold several comma-separated challenges (RFC 7235 section 4.1).
+func parseChallenges(s string) []authChallenge {
+	var list []authChallenge
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			return list
+		}
+		var scheme string
+		scheme, s = authToken(s)
+		if scheme == "" {
+			return list
+		}
+		ch := authChallenge{scheme: scheme, params: make(map[string]string)}
+
+		// A token68 credential follows the scheme directly,
+		// and is not followed by '='.
+		rest := strings.TrimLeft(s, " \t")
+		if tok, after := authToken68(rest); tok != "" {
+			if a := strings.TrimLeft(after, " \t"); a == "" || a[0] == ',' {
+				ch.token, s = tok, after
+				list = append(list, ch)
+				continue
+			}
+		}
+		s = rest
+		for {
+			s = strings.TrimLeft(s, " \t,")
+			key, after := authToken(s)
+			after = strings.TrimLeft(after, " \t")
+			if key == "" || after == "" || after[0] != '=' {
+				break
+			}
+			var val string
+			val, s = authValue(strings.TrimLeft(after[1:], " \t"))
+			ch.params[strings.ToLower(key)] = val
+		}
+		list = append(list, ch)
+	}
+}
+
+func isTokenChar(c byte) bool {
+	return c > ' ' && c < 0x7f && !strings.ContainsRune("()<>@,;:\\\"/[]?={}", rune(c))
+}
+
+func authToken(s string) (tok, rest string) {
+	i := 0
+	for i < len(s) && isTokenChar(s[i]) {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+func authToken68(s string) (tok, rest string) {
+	i := 0
+	for i < len(s) && (isTokenChar(s[i]) || s[i] == '/') && s[i] != '=' {
+		i++
+	}
+	for i < len(s) && s[i] == '=' {
+		i++
+	}
+	if i > 0 && s[i-1] == '=' && strings.Trim(s[:i], "=") == "" {
+		return "", s
+	}
+	return s[:i], s[i:]
+}
+
+// authValue parses a token or quoted-string.
+func authValue(s string) (val, rest string) {
+	if s == "" || s[0] != '"' {
+		return authToken(s)
+	}
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				b.WriteByte(s[i])
+			}
+		case '"':
+			return b.String(), s[i+1:]
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), ""
+}
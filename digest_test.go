@@ -0,0 +1,73 @@
+package soap
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Example from RFC 7616, section 3.9.1.
+func TestDigestRFC7616(t *testing.T) {
+	hdr := []string{`Digest realm="http-auth@example.org", qop="auth, auth-int", algorithm=MD5, nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`,
+		`Digest realm="http-auth@example.org", qop="auth, auth-int", algorithm=SHA-256, nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`,
+	}
+	tests := []struct {
+		algorithm, response string
+	}{
+		{"MD5", "8ca523f5e9506fed4657c9700eebdbec"},
+		{"SHA-256", "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1"},
+	}
+	best := parseDigestChallenge(hdr)
+	if best == nil || best.algorithm != "SHA-256" || best.qop != "auth" {
+		t.Fatalf("got challenge %+v, want SHA-256 with qop=auth", best)
+	}
+	for i, tt := range tests {
+		c := parseDigestChallenge(hdr[i : i+1])
+		auth := c.authorization("Mufasa", "Circle of Life", "GET", "/dir/index.html", nil, 1,
+			"f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ")
+		if !strings.Contains(auth, `response="`+tt.response+`"`) {
+			t.Errorf("%s: got %s", tt.algorithm, auth)
+		}
+	}
+}
+
+func TestParseChallenges(t *testing.T) {
+	list := parseChallenges(`Negotiate, NTLM TlRMTVNTUAACAAAA==, Basic realm="a \"b\"", Digest nonce=x`)
+	if len(list) != 4 {
+		t.Fatalf("got %d challenges: %+v", len(list), list)
+	}
+	if list[1].token != "TlRMTVNTUAACAAAA==" || list[2].params["realm"] != `a "b"` || list[3].params["nonce"] != "x" {
+		t.Errorf("got %+v", list)
+	}
+}
+
+func TestDigestTransport(t *testing.T) {
+	var unauthorized int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		body, _ := io.ReadAll(r.Body)
+		if !strings.HasPrefix(auth, "Digest ") || string(body) != "payload" {
+			unauthorized++
+			w.Header().Set("WWW-Authenticate", `Digest realm="r", qop="auth", nonce="n1", algorithm=SHA-256`)
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	c := &http.Client{Transport: &DigestTransport{Username: "u", Password: "p"}}
+	for i := 0; i < 3; i++ {
+		rsp, err := c.Post(srv.URL, "text/xml", strings.NewReader("payload"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rsp.Body.Close()
+		if rsp.StatusCode != http.StatusOK {
+			t.Fatalf("request %d: %s", i, rsp.Status)
+		}
+	}
+	if unauthorized != 1 {
+		t.Errorf("server challenged %d times, want 1", unauthorized)
+	}
+}
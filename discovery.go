@@ -0,0 +1,251 @@
+package soap
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// NsWSD is the namespace of WS-Discovery, as published in April 2005
+// and used by DPWS and ONVIF devices.
+const NsWSD = "http://schemas.xmlsoap.org/ws/2005/04/discovery"
+
+// DiscoveryAddr is the IPv4 multicast address and port on which
+// WS-Discovery messages are exchanged.
+const DiscoveryAddr = "239.255.255.250:3702"
+
+// The To address of multicast WS-Discovery messages.
+const discoveryTo = "urn:schemas-xmlsoap-org:ws:2005:04:discovery"
+
+// ProbeOptions holds the parameters of a WS-Discovery probe.
+type ProbeOptions struct {
+	// Types, if not empty, restricts the probe to devices
+	// implementing all of the given types, such as
+	// {"http://www.onvif.org/ver10/network/wsdl", "NetworkVideoTransmitter"}.
+	Types []xml.Name
+
+	// Scopes, if not empty, restricts the probe to devices in
+	// all of the given scopes.
+	Scopes []string
+
+	// Timeout is the time to wait for responses. If zero, two
+	// seconds are used. The probe also ends when its context is
+	// done.
+	Timeout time.Duration
+
+	// Addr is the address to which the probe is sent. If empty,
+	// DiscoveryAddr is used. A unicast address may be given to
+	// probe a single host.
+	Addr string
+}
+
+// A ProbeMatch describes a device that responded to a probe, or
+// announced itself with a Hello message.
+type ProbeMatch struct {
+	// Address is the endpoint reference address of the device,
+	// which identifies it across changes of its network address.
+	Address string
+
+	// Types holds the types of the device, as QNames using the
+	// prefixes of the device's message.
+	Types []string
+
+	// Scopes holds the scopes of the device.
+	Scopes []string
+
+	// XAddrs holds the transport addresses of the device, at
+	// which it may be reached with SOAP requests.
+	XAddrs []string
+
+	// MetadataVersion is incremented by the device whenever
+	// its metadata changes.
+	MetadataVersion int
+}
+
+type wsdMatch struct {
+	Address         string `xml:"EndpointReference>Address"`
+	Types           string `xml:"Types"`
+	Scopes          string `xml:"Scopes"`
+	XAddrs          string `xml:"XAddrs"`
+	MetadataVersion int    `xml:"MetadataVersion"`
+}
+
+func (m *wsdMatch) probeMatch() ProbeMatch {
+	return ProbeMatch{
+		Address:         m.Address,
+		Types:           strings.Fields(m.Types),
+		Scopes:          strings.Fields(m.Scopes),
+		XAddrs:          strings.Fields(m.XAddrs),
+		MetadataVersion: m.MetadataVersion,
+	}
+}
+
+// wsdMessage decodes the WS-Discovery messages received by clients.
+type wsdMessage struct {
+	Header struct {
+		Action    string `xml:"Action"`
+		RelatesTo string `xml:"RelatesTo"`
+	} `xml:"Header"`
+	Body struct {
+		ProbeMatches []wsdMatch `xml:"ProbeMatches>ProbeMatch"`
+		Hello        *wsdMatch  `xml:"Hello"`
+		Bye          *wsdMatch  `xml:"Bye"`
+	} `xml:"Body"`
+}
+
+type wsdProbe struct {
+	XMLName xml.Name   `xml:"http://schemas.xmlsoap.org/ws/2005/04/discovery Probe"`
+	Attr    []xml.Attr `xml:",any,attr"`
+	Types   string     `xml:"http://schemas.xmlsoap.org/ws/2005/04/discovery Types,omitempty"`
+	Scopes  string     `xml:"http://schemas.xmlsoap.org/ws/2005/04/discovery Scopes,omitempty"`
+}
+
+// probeMessage returns a Probe message with the given message ID.
+func (o *ProbeOptions) probeMessage(id string) ([]byte, error) {
+	var probe wsdProbe
+	var types []string
+	for i, t := range o.Types {
+		prefix := fmt.Sprintf("t%d", i)
+		probe.Attr = append(probe.Attr, xml.Attr{Name: xml.Name{Local: "xmlns:" + prefix}, Value: t.Space})
+		types = append(types, prefix+":"+t.Local)
+	}
+	probe.Types = strings.Join(types, " ")
+	probe.Scopes = strings.Join(o.Scopes, " ")
+
+	wsa := Addressing{
+		Namespace: NsWSA200408,
+		To:        discoveryTo,
+		Action:    NsWSD + "/Probe",
+		MessageID: id,
+	}
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.Version = V12
+	enc.Header = wsa.Header()
+	if err := enc.Encode(probe); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Probe sends a WS-Discovery Probe message and returns the devices
+// that respond before the timeout elapses. Each device is returned
+// once, even if it responds more than once.
+func Probe(ctx context.Context, opts *ProbeOptions) ([]ProbeMatch, error) {
+	if opts == nil {
+		opts = new(ProbeOptions)
+	}
+	addr := opts.Addr
+	if addr == "" {
+		addr = DiscoveryAddr
+	}
+	raddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	id := NewMessageID()
+	msg, err := opts.probeMessage(id)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if _, err := conn.WriteTo(msg, raddr); err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(durationOr(opts.Timeout, 2*time.Second))
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetReadDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { conn.SetReadDeadline(time.Now()) })
+	defer stop()
+
+	var matches []ProbeMatch
+	seen := make(map[string]bool)
+	buf := make([]byte, 65536)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				return matches, nil
+			}
+			return matches, err
+		}
+		var resp wsdMessage
+		if xml.Unmarshal(buf[:n], &resp) != nil || resp.Header.RelatesTo != id {
+			continue
+		}
+		for _, m := range resp.Body.ProbeMatches {
+			if !seen[m.Address] {
+				seen[m.Address] = true
+				matches = append(matches, m.probeMatch())
+			}
+		}
+	}
+}
+
+// An Announcement is a Hello or Bye message sent by a device joining
+// or leaving the network.
+type Announcement struct {
+	// Bye is true for a Bye message, whose ProbeMatch may hold
+	// only the device's Address.
+	Bye bool
+
+	ProbeMatch
+}
+
+// ListenAnnouncements listens for Hello and Bye messages multicast on
+// the network interface ifi, or the system's default interface if ifi
+// is nil, calling fn for each one, until ctx is done.
+func ListenAnnouncements(ctx context.Context, ifi *net.Interface, fn func(*Announcement)) error {
+	group, err := net.ResolveUDPAddr("udp4", DiscoveryAddr)
+	if err != nil {
+		return err
+	}
+	conn, err := net.ListenMulticastUDP("udp4", ifi, group)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	buf := make([]byte, 65536)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if a := parseAnnouncement(buf[:n]); a != nil {
+			fn(a)
+		}
+	}
+}
+
+// parseAnnouncement returns the announcement in a message, or nil if
+// it is not a Hello or Bye message.
+func parseAnnouncement(data []byte) *Announcement {
+	var msg wsdMessage
+	if xml.Unmarshal(data, &msg) != nil {
+		return nil
+	}
+	switch {
+	case msg.Body.Hello != nil:
+		return &Announcement{ProbeMatch: msg.Body.Hello.probeMatch()}
+	case msg.Body.Bye != nil:
+		return &Announcement{Bye: true, ProbeMatch: msg.Body.Bye.probeMatch()}
+	}
+	return nil
+}
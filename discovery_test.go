@@ -0,0 +1,80 @@
+package soap
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestProbe(t *testing.T) {
+	dev, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dev.Close()
+	go func() {
+		buf := make([]byte, 65536)
+		n, from, err := dev.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		var probe struct {
+			Header struct {
+				MessageID string `xml:"MessageID"`
+			}
+			Body struct {
+				Probe struct {
+					Types string `xml:"Types"`
+				}
+			}
+		}
+		if err := xml.Unmarshal(buf[:n], &probe); err != nil {
+			t.Error(err)
+		}
+		if probe.Body.Probe.Types != "t0:NetworkVideoTransmitter" {
+			t.Errorf("probe has types %q", probe.Body.Probe.Types)
+		}
+		reply := func(relatesTo string) []byte {
+			return []byte(fmt.Sprintf(`<s:Envelope xmlns:s="%s" xmlns:a="%s" xmlns:d="%s">
+<s:Header><a:RelatesTo>%s</a:RelatesTo></s:Header>
+<s:Body><d:ProbeMatches><d:ProbeMatch>
+<a:EndpointReference><a:Address>urn:uuid:cam1</a:Address></a:EndpointReference>
+<d:Types>dn:NetworkVideoTransmitter</d:Types>
+<d:XAddrs>http://192.0.2.1/onvif/device_service http://[2001:db8::1]/onvif/device_service</d:XAddrs>
+<d:MetadataVersion>2</d:MetadataVersion>
+</d:ProbeMatch></d:ProbeMatches></s:Body></s:Envelope>`, NsSoap12Env, NsWSA200408, NsWSD, relatesTo))
+		}
+		dev.WriteTo(reply("urn:uuid:other"), from)
+		dev.WriteTo(reply(probe.Header.MessageID), from)
+		dev.WriteTo(reply(probe.Header.MessageID), from)
+	}()
+
+	matches, err := Probe(context.Background(), &ProbeOptions{
+		Types:   []xml.Name{{Space: "http://www.onvif.org/ver10/network/wsdl", Local: "NetworkVideoTransmitter"}},
+		Timeout: 500 * time.Millisecond,
+		Addr:    dev.LocalAddr().String(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 {
+		t.Fatalf("got %d matches, want 1: %+v", len(matches), matches)
+	}
+	m := matches[0]
+	if m.Address != "urn:uuid:cam1" || len(m.XAddrs) != 2 || m.MetadataVersion != 2 {
+		t.Errorf("got %+v", m)
+	}
+}
+
+func TestParseAnnouncement(t *testing.T) {
+	bye := fmt.Sprintf(`<s:Envelope xmlns:s="%s" xmlns:a="%s" xmlns:d="%s"><s:Body>
+<d:Bye><a:EndpointReference><a:Address>urn:uuid:cam1</a:Address></a:EndpointReference></d:Bye>
+</s:Body></s:Envelope>`, NsSoap12Env, NsWSA200408, NsWSD)
+	a := parseAnnouncement([]byte(bye))
+	if a == nil || !a.Bye || a.Address != "urn:uuid:cam1" {
+		t.Errorf("got %+v", a)
+	}
+}
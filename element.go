@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/xml"
 	"errors"
+	"fmt"
 	"io"
 	"text/template"
 )
@@ -11,6 +12,10 @@ import (
 type element struct {
 	xml.StartElement
 	Data []byte `xml:",innerxml"`
+
+	// shared is set if Data is a slice of the document, rather
+	// than a copy re-encoded from its tokens.
+	shared bool
 }
 
 func (el element) marshal(wr io.Writer) error {
@@ -38,7 +43,7 @@ func elements(data []byte) ([]element, error) {
 		buf  bytes.Buffer
 	)
 	
-	p := xml.NewDecoder(bytes.NewReader(data))
+	p := newTokenizer(data)
 	for tok, err = p.RawToken(); err == nil; tok, err = p.RawToken() {
 		if tok, ok := tok.(xml.StartElement); ok {
 			el.StartElement = tok.Copy()
@@ -57,9 +62,62 @@ func elements(data []byte) ([]element, error) {
 	return elem, nil
 }
 
+// sharedElements is like elements, but the Data of each element is a
+// slice of data, holding the element's content as written, rather
+// than a copy. Comments and processing instructions are kept.
+func sharedElements(data []byte) ([]element, error) {
+	var elem []element
+	p := newTokenizer(data)
+	for {
+		tok, err := p.RawToken()
+		if err == io.EOF {
+			return elem, nil
+		} else if err != nil {
+			return nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		begin := p.InputOffset()
+		end, err := endOffset(p, start)
+		if err != nil {
+			return nil, err
+		}
+		elem = append(elem, element{StartElement: start.Copy(), Data: data[begin:end], shared: true})
+	}
+}
+
+// endOffset reads the content of the element started by start, and
+// returns the offset of its end tag.
+func endOffset(p tokenizer, start xml.StartElement) (int64, error) {
+	depth := 0
+	for {
+		off := p.InputOffset()
+		tok, err := p.RawToken()
+		if err == io.EOF {
+			return 0, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return 0, err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			if depth > 0 {
+				depth--
+			} else if tok.Name == start.Name {
+				return off, nil
+			} else {
+				return 0, errors.New("Unexpected end element " + tok.Name.Local)
+			}
+		}
+	}
+}
+
 // NOTE(droyo) we're walking the whole XML tree. We should consider
 // collapsing buildMRef into this to do fewer passes on the document.
-func elementData(p *xml.Decoder, start xml.StartElement, buf *bytes.Buffer) error {
+func elementData(p tokenizer, start xml.StartElement, buf *bytes.Buffer) error {
 	var tok xml.Token
 	var err error
 
@@ -94,7 +152,11 @@ Loop:
 }
 
 func (el element) Children() []element {
-	if elem, err := elements(el.Data); err != nil {
+	parse := elements
+	if el.shared {
+		parse = sharedElements
+	}
+	if elem, err := parse(el.Data); err != nil {
 		return nil
 	} else {
 		return elem
@@ -126,33 +188,102 @@ func findId(list []xml.Attr) (string, bool) {
 	return "", false
 }
 
-func buildMRef(data []byte) (map[string] element, error) {
+// buildMRef returns the elements of a document with an id, by id,
+// and the set of ids referenced by href attributes. If share is set,
+// the elements are slices of data. Elements sharing an id are
+// resolved according to dup.
+func buildMRef(data []byte, share bool, dup DuplicateIDPolicy) (map[string] element, map[string]bool, error) {
 	mref := make(map[string] element)
+	hrefs := make(map[string]bool)
 	
-	elem, err := elements(data)
+	parse := elements
+	if share {
+		parse = sharedElements
+	}
+	elem, err := parse(data)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	
 	for _, el := range elem {
-		if err := walkMultiRef(el, mref); err != nil {
-			return nil, err
+		if err := walkMultiRef(el, mref, hrefs, dup); err != nil {
+			return nil, nil, err
 		}
 	}
-	return mref, nil
+	return mref, hrefs, nil
 }
 
-func walkMultiRef(root element, mref map[string] element) error {
-	children := root.Children()
-	if len(children) > 0 {
-		for _, el := range children {
-			if err := walkMultiRef(el, mref); err != nil {
-				return err
-			}
+// walkMultiRef records the ids and hrefs of an element and its
+// descendants, in document order.
+func walkMultiRef(root element, mref map[string] element, hrefs map[string]bool, dup DuplicateIDPolicy) error {
+	if id, ok := findId(root.Attr); ok {
+		if _, seen := mref[id]; !seen || dup == DuplicateLast {
+			mref[id] = root
+		} else if dup == DuplicateReject {
+			return fmt.Errorf("soap: duplicate id %q", id)
 		}
 	}
-	if id, ok := findId(root.Attr); ok {
-		mref[id] = root
+	if href, ok := findHref(root.Attr); ok {
+		hrefs[href] = true
+	}
+	for _, el := range root.Children() {
+		if err := walkMultiRef(el, mref, hrefs, dup); err != nil {
+			return err
+		}
 	}
 	return nil
 }
+
+// nsScope returns the namespace prefixes in scope within an element
+// with the given attributes, read with RawToken, given those in scope
+// in its parent. The default namespace is keyed by the empty string.
+func nsScope(parent map[string]string, attr []xml.Attr) map[string]string {
+	scope, copied := parent, false
+	for _, a := range attr {
+		var prefix string
+		switch {
+		case a.Name.Space == "xmlns":
+			prefix = a.Name.Local
+		case a.Name.Space == "" && a.Name.Local == "xmlns":
+		default:
+			continue
+		}
+		if !copied {
+			scope = make(map[string]string, len(parent)+1)
+			for k, v := range parent {
+				scope[k] = v
+			}
+			copied = true
+		}
+		scope[prefix] = a.Value
+	}
+	return scope
+}
+
+// resolveName returns a name read with RawToken with its prefix
+// replaced by its namespace. Unprefixed element names are in the
+// default namespace, while unprefixed attribute names are in none.
+// Undeclared prefixes are left in place, as xml.Decoder does.
+func resolveName(scope map[string]string, name xml.Name, isElement bool) xml.Name {
+	switch {
+	case name.Space == "" && !isElement:
+	case name.Space == "xmlns":
+	case name.Space == "xml":
+		name.Space = "http://www.w3.org/XML/1998/namespace"
+	default:
+		if ns, ok := scope[name.Space]; ok {
+			name.Space = ns
+		}
+	}
+	return name
+}
+
+// resolveAttr returns a copy of attributes read with RawToken with
+// the namespaces of their names resolved.
+func resolveAttr(scope map[string]string, attr []xml.Attr) []xml.Attr {
+	resolved := make([]xml.Attr, len(attr))
+	for i, a := range attr {
+		resolved[i] = xml.Attr{Name: resolveName(scope, a.Name, false), Value: a.Value}
+	}
+	return resolved
+}
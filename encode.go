@@ -0,0 +1,299 @@
+package soap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"io"
+	"sort"
+)
+
+// An Encoder writes SOAP messages to an output stream.
+type Encoder struct {
+	// Version is the version of SOAP used. The zero value
+	// is SOAP 1.1.
+	Version Version
+
+	// Header holds the entries of the SOAP Header written with
+	// each message, in order. If it is empty, no Header is
+	// written.
+	Header []interface{}
+
+	// HeaderOrder, if not empty, orders the entries of the
+	// Header, for receivers that require some entries to come
+	// before others, such as a WS-Security header before those
+	// of WS-Addressing. Entries are written in the order of the
+	// first name in HeaderOrder they match, and entries matching
+	// none are written last; entries of the same rank keep their
+	// order in Header. A name with an empty Local matches every
+	// entry in its namespace.
+	HeaderOrder []xml.Name
+
+	// EncodingStyle, if not empty, is set as the encodingStyle
+	// attribute of the Envelope, as some RPC services require.
+	EncodingStyle string
+
+	// Canonical writes messages in a canonical form, which
+	// depends only on the names, attributes and content of
+	// their elements, for messages that are signed or compared
+	// with golden files. Every namespace is declared on the
+	// Envelope, bound to the prefix it is declared with in the
+	// values encoded, or otherwise to a prefix of the form nsN
+	// numbered in order of first use. Attributes are sorted by
+	// namespace and local name. Canonical messages are built in
+	// memory before being written.
+	Canonical bool
+
+	// Prefixes maps namespaces to the prefixes they are written
+	// with, for receivers that match prefixes literally, such as
+	// NsSoapEnv to "SOAP-ENV". Setting it writes messages in the
+	// canonical form, as Canonical does, with these prefixes
+	// taking precedence over those declared in the values
+	// encoded. The QNames in xsi:type and SOAP-ENC:arrayType
+	// values are rewritten with the new prefixes; those in the
+	// text of elements are not, so such values should use the
+	// preferred prefixes themselves.
+	Prefixes map[string]string
+
+	w io.Writer
+}
+
+// canonical reports whether messages are put in canonical form.
+func (enc *Encoder) canonical() bool {
+	return enc.Canonical || len(enc.Prefixes) > 0
+}
+
+// NewEncoder returns a new Encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Entries holds several independent entries of a SOAP Body, for
+// services that accept a batch of operations in a single message.
+// When passed to Encode, or as the request of a Client call, each
+// element is encoded as a separate child of the Body.
+type Entries []interface{}
+
+// Encode writes a SOAP Envelope whose Body contains the XML encoding
+// of v, as produced by xml.Marshal, preceded by a Header containing
+// the encoding of each entry of enc.Header. If v is nil, the Body
+// is empty; if it is of type Entries, each of its elements is an
+// entry of the Body. The envelope namespace is bound to a prefix, so
+// that it does not become the default namespace of v. The message is
+// written as it is encoded, rather than being built in memory first.
+func (enc *Encoder) Encode(v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		return enc.EncodeEntries()
+	case Entries:
+		return enc.EncodeEntries(v...)
+	}
+	return enc.EncodeEntries(v)
+}
+
+// EncodeEntries writes a SOAP Envelope, as Encode does, whose Body
+// contains the XML encoding of each entry in order. Nil entries are
+// skipped.
+func (enc *Encoder) EncodeEntries(entries ...interface{}) error {
+	var buf bytes.Buffer
+	w := enc.w
+	if enc.canonical() {
+		w = &buf
+	}
+	e := xml.NewEncoder(w)
+	ends, err := enc.writeStart(e)
+	if err != nil {
+		return err
+	}
+	for _, v := range entries {
+		if v == nil {
+			continue
+		}
+		if err := e.Encode(v); err != nil {
+			return err
+		}
+	}
+	for _, end := range ends {
+		if err := e.EncodeToken(end); err != nil {
+			return err
+		}
+	}
+	if err := e.Flush(); err != nil {
+		return err
+	}
+	if enc.canonical() {
+		return canonicalize(enc.w, buf.Bytes(), enc.Prefixes)
+	}
+	return nil
+}
+
+// writeStart writes the start of an Envelope, its Header, and the
+// start of its Body, and returns the end elements closing the Body
+// and Envelope.
+func (enc *Encoder) writeStart(e *xml.Encoder) ([]xml.EndElement, error) {
+	prefix := enc.Version.prefix()
+	envelopeStart := xml.StartElement{
+		Name: xml.Name{Local: prefix + ":Envelope"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns:" + prefix}, Value: enc.Version.envelopeNS()}},
+	}
+	if enc.EncodingStyle != "" {
+		envelopeStart.Attr = append(envelopeStart.Attr, xml.Attr{
+			Name:  xml.Name{Local: prefix + ":encodingStyle"},
+			Value: enc.EncodingStyle,
+		})
+	}
+	headerStart := xml.StartElement{Name: xml.Name{Local: prefix + ":Header"}}
+	bodyStart := xml.StartElement{Name: xml.Name{Local: prefix + ":Body"}}
+
+	if err := e.EncodeToken(envelopeStart); err != nil {
+		return nil, err
+	}
+	if len(enc.Header) > 0 {
+		if err := e.EncodeToken(headerStart); err != nil {
+			return nil, err
+		}
+		header, err := enc.orderedHeader()
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range header {
+			if err := e.Encode(h); err != nil {
+				return nil, err
+			}
+		}
+		if err := e.EncodeToken(headerStart.End()); err != nil {
+			return nil, err
+		}
+	}
+	if err := e.EncodeToken(bodyStart); err != nil {
+		return nil, err
+	}
+	return []xml.EndElement{bodyStart.End(), envelopeStart.End()}, nil
+}
+
+// orderedHeader returns the entries of the Header in the order
+// selected by HeaderOrder. The name of each entry is found by
+// encoding it, as it may be set by the value of an XMLName field.
+func (enc *Encoder) orderedHeader() ([]interface{}, error) {
+	if len(enc.HeaderOrder) == 0 {
+		return enc.Header, nil
+	}
+	rank := make([]int, len(enc.Header))
+	for i, h := range enc.Header {
+		data, err := xml.Marshal(h)
+		if err != nil {
+			return nil, err
+		}
+		rank[i] = len(enc.HeaderOrder)
+		tok, err := xml.NewDecoder(bytes.NewReader(data)).Token()
+		start, ok := tok.(xml.StartElement)
+		if err != nil || !ok {
+			continue
+		}
+		for j, name := range enc.HeaderOrder {
+			if start.Name.Space == name.Space && (name.Local == "" || start.Name.Local == name.Local) {
+				rank[i] = j
+				break
+			}
+		}
+	}
+	order := make([]int, len(enc.Header))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return rank[order[i]] < rank[order[j]] })
+	header := make([]interface{}, len(order))
+	for i, j := range order {
+		header[i] = enc.Header[j]
+	}
+	return header, nil
+}
+
+// An Envelope is a SOAP message to be written. It implements
+// io.WriterTo, so that a message can be streamed to a network
+// connection or a hash without being built in memory first.
+type Envelope struct {
+	// Version, Header, HeaderOrder, EncodingStyle, Canonical
+	// and Prefixes are used as they are by an Encoder.
+	Version       Version
+	Header        []interface{}
+	HeaderOrder   []xml.Name
+	EncodingStyle string
+	Canonical     bool
+	Prefixes      map[string]string
+
+	// Body is encoded as the content of the Body, as v is by
+	// Encode.
+	Body interface{}
+}
+
+// WriteTo writes the message to w, returning the number of bytes
+// written.
+func (env *Envelope) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{w: w}
+	enc := Encoder{Version: env.Version, Header: env.Header, HeaderOrder: env.HeaderOrder, EncodingStyle: env.EncodingStyle, Canonical: env.Canonical, Prefixes: env.Prefixes, w: cw}
+	err := enc.Encode(env.Body)
+	return cw.n, err
+}
+
+// A countWriter counts the bytes written to w.
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// NewEnvelopeReader returns a reader producing the SOAP message for v,
+// as written by Encode. The message is encoded in a separate goroutine
+// as it is read, so it may be passed to NewRequest to send a large
+// request without holding it in memory; the request is then sent
+// using chunked transfer encoding. Encoding errors are returned from
+// Read. Closing the reader stops the encoder.
+func NewEnvelopeReader(v interface{}) io.ReadCloser {
+	return pipeEnvelope(v, Encoder{}, false)
+}
+
+// pipeEnvelope returns a reader producing the message for v, as
+// written by an Encoder with the settings of enc.
+func pipeEnvelope(v interface{}, enc Encoder, compress bool) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		if !compress {
+			enc.w = pw
+			pw.CloseWithError(enc.Encode(v))
+			return
+		}
+		zw := gzip.NewWriter(pw)
+		enc.w = zw
+		err := enc.Encode(v)
+		if cerr := zw.Close(); err == nil {
+			err = cerr
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+// marshalEnvelope returns the SOAP message for v, as written by
+// an Encoder with the settings of enc. Messages without a Header use
+// a shared EnvelopeTemplate.
+func marshalEnvelope(v interface{}, enc Encoder) ([]byte, error) {
+	if len(enc.Header) == 0 && !enc.canonical() {
+		t, err := envelopeTemplate(enc)
+		if err != nil {
+			return nil, err
+		}
+		return t.Append(nil, v)
+	}
+	var buf bytes.Buffer
+	enc.w = &buf
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,274 @@
+package soap
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"time"
+)
+
+// NsWSEN is the namespace of WS-Enumeration, as published in
+// September 2004 and used by WS-Management.
+const NsWSEN = "http://schemas.xmlsoap.org/ws/2004/09/enumeration"
+
+// EnumerateOptions holds the optional parameters of a WS-Enumeration
+// enumeration.
+type EnumerateOptions struct {
+	// Filter, if not empty, selects the items enumerated, in the
+	// filter language named by Dialect.
+	Filter, Dialect string
+
+	// MaxElements is the maximum number of items requested by
+	// each Pull. If zero, the service returns one item at a time.
+	MaxElements int
+
+	// MaxTime bounds the time the service may take to assemble
+	// the items of a Pull response.
+	MaxTime time.Duration
+
+	// Extensions holds additional children of the Enumerate
+	// element, such as the options defined by WS-Management.
+	Extensions []interface{}
+}
+
+// An Enumerator iterates over the items of a WS-Enumeration
+// enumeration. Successive calls to Next step through the items,
+// pulling them from the service as needed:
+//
+//	e, err := soap.Enumerate(ctx, c, nil)
+//	if err != nil {
+//		return err
+//	}
+//	defer e.Release(ctx)
+//	for e.Next(ctx) {
+//		var item Item
+//		if err := e.Decode(&item); err != nil {
+//			return err
+//		}
+//	}
+//	return e.Err()
+type Enumerator struct {
+	client  *Client
+	opts    []CallOption
+	options EnumerateOptions
+
+	context string
+	items   [][]byte
+	item    []byte
+	end     bool
+	err     error
+}
+
+type wsenEnumerate struct {
+	XMLName    xml.Name      `xml:"http://schemas.xmlsoap.org/ws/2004/09/enumeration Enumerate"`
+	Filter     *wsenFilter   `xml:",omitempty"`
+	Extensions []interface{} `xml:",omitempty"`
+}
+
+type wsenFilter struct {
+	XMLName xml.Name `xml:"http://schemas.xmlsoap.org/ws/2004/09/enumeration Filter"`
+	Dialect string   `xml:"Dialect,attr,omitempty"`
+	Value   string   `xml:",chardata"`
+}
+
+type wsenPull struct {
+	XMLName            xml.Name `xml:"http://schemas.xmlsoap.org/ws/2004/09/enumeration Pull"`
+	EnumerationContext string   `xml:"http://schemas.xmlsoap.org/ws/2004/09/enumeration EnumerationContext"`
+	MaxTime            string   `xml:"http://schemas.xmlsoap.org/ws/2004/09/enumeration MaxTime,omitempty"`
+	MaxElements        int      `xml:"http://schemas.xmlsoap.org/ws/2004/09/enumeration MaxElements,omitempty"`
+}
+
+type wsenRelease struct {
+	XMLName            xml.Name `xml:"http://schemas.xmlsoap.org/ws/2004/09/enumeration Release"`
+	EnumerationContext string   `xml:"http://schemas.xmlsoap.org/ws/2004/09/enumeration EnumerationContext"`
+}
+
+// wsenResponse decodes an EnumerateResponse or PullResponse. Items
+// may be returned in either, as WS-Management's optimized
+// enumerations do.
+type wsenResponse struct {
+	context string
+	items   [][]byte
+	end     bool
+}
+
+func (r *wsenResponse) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.EndElement:
+			return nil
+		case xml.StartElement:
+			switch tok.Name.Local {
+			case "EnumerationContext":
+				err = d.DecodeElement(&r.context, &tok)
+			case "Items":
+				err = r.decodeItems(d)
+			case "EndOfSequence":
+				r.end = true
+				err = d.Skip()
+			default:
+				err = d.Skip()
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (r *wsenResponse) decodeItems(d *xml.Decoder) error {
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.EndElement:
+			return nil
+		case xml.StartElement:
+			item, err := copyElement(d, tok)
+			if err != nil {
+				return err
+			}
+			r.items = append(r.items, item)
+		}
+	}
+}
+
+// copyElement returns the XML encoding of the element beginning
+// with start, read from d. Namespace declarations on the element
+// and its descendants are kept, but those of its ancestors are not,
+// so QName values using their prefixes cannot be resolved.
+func copyElement(d *xml.Decoder, start xml.StartElement) ([]byte, error) {
+	var buf bytes.Buffer
+	e := xml.NewEncoder(&buf)
+	var tok xml.Token = start
+	for depth := 0; ; {
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			tok = fixNamespaceDecls(t)
+		case xml.EndElement:
+			depth--
+		}
+		if err := e.EncodeToken(tok); err != nil {
+			return nil, err
+		}
+		if depth == 0 {
+			break
+		}
+		var err error
+		if tok, err = d.Token(); err != nil {
+			return nil, err
+		}
+	}
+	if err := e.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// fixNamespaceDecls rewrites the namespace declarations of a start
+// element, as returned by an xml.Decoder, so that an xml.Encoder
+// writes them unchanged. Default namespace declarations are dropped,
+// as the Encoder adds its own.
+func fixNamespaceDecls(start xml.StartElement) xml.StartElement {
+	attr := make([]xml.Attr, 0, len(start.Attr))
+	for _, a := range start.Attr {
+		switch {
+		case a.Name.Space == "xmlns":
+			a.Name = xml.Name{Local: "xmlns:" + a.Name.Local}
+		case a.Name.Space == "" && a.Name.Local == "xmlns":
+			continue
+		}
+		attr = append(attr, a)
+	}
+	start.Attr = attr
+	return start
+}
+
+// Enumerate starts an enumeration of the items of the data source
+// reached by c. The options may be nil. opts are applied to every
+// request of the enumeration.
+func Enumerate(ctx context.Context, c *Client, options *EnumerateOptions, opts ...CallOption) (*Enumerator, error) {
+	e := &Enumerator{client: c, opts: opts}
+	if options != nil {
+		e.options = *options
+	}
+	req := wsenEnumerate{Extensions: e.options.Extensions}
+	if e.options.Filter != "" {
+		req.Filter = &wsenFilter{Dialect: e.options.Dialect, Value: e.options.Filter}
+	}
+	var resp wsenResponse
+	if err := addressedCall(ctx, c, NsWSEN+"/Enumerate", req, &resp, opts...); err != nil {
+		return nil, err
+	}
+	e.update(&resp)
+	return e, nil
+}
+
+func (e *Enumerator) update(resp *wsenResponse) {
+	if resp.context != "" {
+		e.context = resp.context
+	}
+	e.items = append(e.items, resp.items...)
+	e.end = resp.end
+	if !e.end && e.context == "" {
+		e.err = errors.New("soap: enumeration response has no EnumerationContext")
+	}
+}
+
+// Next advances the Enumerator to the next item, pulling more items
+// from the service if needed. It returns false at the end of the
+// enumeration or if an error occurs; Err then returns the error.
+func (e *Enumerator) Next(ctx context.Context) bool {
+	for len(e.items) == 0 {
+		if e.end || e.err != nil {
+			e.item = nil
+			return false
+		}
+		req := wsenPull{
+			EnumerationContext: e.context,
+			MaxElements:        e.options.MaxElements,
+			MaxTime:            xsDuration(e.options.MaxTime),
+		}
+		var resp wsenResponse
+		if e.err = addressedCall(ctx, e.client, NsWSEN+"/Pull", req, &resp, e.opts...); e.err == nil {
+			e.update(&resp)
+		}
+	}
+	e.item, e.items = e.items[0], e.items[1:]
+	return true
+}
+
+// Item returns the XML encoding of the current item.
+func (e *Enumerator) Item() []byte {
+	return e.item
+}
+
+// Decode decodes the current item into v, as xml.Unmarshal does.
+func (e *Enumerator) Decode(v interface{}) error {
+	return xml.Unmarshal(e.item, v)
+}
+
+// Err returns the first error encountered by Next.
+func (e *Enumerator) Err() error {
+	return e.err
+}
+
+// Release ends the enumeration before its end has been reached,
+// allowing the service to free its resources. It does nothing if
+// the end has been reached.
+func (e *Enumerator) Release(ctx context.Context) error {
+	if e.end || e.context == "" {
+		return nil
+	}
+	e.end, e.items = true, nil
+	req := wsenRelease{EnumerationContext: e.context}
+	return addressedCall(ctx, e.client, NsWSEN+"/Release", req, nil, e.opts...)
+}
@@ -0,0 +1,89 @@
+package soap
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEnumerate(t *testing.T) {
+	var released bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var msg struct {
+			Header struct {
+				Action string `xml:"Action"`
+			}
+			Body struct {
+				Pull struct {
+					Context string `xml:"EnumerationContext"`
+				}
+			}
+		}
+		if err := xml.Unmarshal(data, &msg); err != nil {
+			t.Error(err)
+		}
+		item := func(n int) string {
+			return fmt.Sprintf(`<t:Echo><value>%d</value></t:Echo>`, n)
+		}
+		var body string
+		switch strings.TrimPrefix(msg.Header.Action, NsWSEN+"/") {
+		case "Enumerate":
+			body = `<wsen:EnumerateResponse><wsen:EnumerationContext>ctx1</wsen:EnumerationContext>
+<wsman:Items xmlns:wsman="urn:wsman">` + item(1) + `</wsman:Items></wsen:EnumerateResponse>`
+		case "Pull":
+			switch msg.Body.Pull.Context {
+			case "ctx1":
+				body = `<wsen:PullResponse><wsen:EnumerationContext>ctx2</wsen:EnumerationContext>
+<wsen:Items>` + item(2) + item(3) + `</wsen:Items></wsen:PullResponse>`
+			case "ctx2":
+				body = `<wsen:PullResponse><wsen:Items>` + item(4) + `</wsen:Items><wsen:EndOfSequence/></wsen:PullResponse>`
+			default:
+				t.Errorf("Pull with context %q", msg.Body.Pull.Context)
+			}
+		case "Release":
+			released = true
+		}
+		w.Header().Set("Content-Type", "text/xml")
+		fmt.Fprintf(w, `<s:Envelope xmlns:s="%s" xmlns:wsen="%s" xmlns:t="urn:test"><s:Body>%s</s:Body></s:Envelope>`,
+			NsSoapEnv, NsWSEN, body)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	c := &Client{URL: srv.URL}
+	e, err := Enumerate(ctx, c, &EnumerateOptions{MaxElements: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for e.Next(ctx) {
+		var v echoRequest
+		if err := e.Decode(&v); err != nil {
+			t.Fatalf("%v: %s", err, e.Item())
+		}
+		got = append(got, v.Value)
+	}
+	if err := e.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if s := strings.Join(got, ","); s != "1,2,3,4" {
+		t.Errorf("got items %s", s)
+	}
+	if err := e.Release(ctx); err != nil || released {
+		t.Errorf("Release after end of sequence: %v, sent %v", err, released)
+	}
+
+	e, err = Enumerate(ctx, c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Release(ctx); err != nil || !released {
+		t.Errorf("Release: %v, sent %v", err, released)
+	}
+}
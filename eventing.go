@@ -0,0 +1,247 @@
+package soap
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// NsWSE is the namespace of WS-Eventing, as published in August 2004
+// and implemented by most devices and services.
+const NsWSE = "http://schemas.xmlsoap.org/ws/2004/08/eventing"
+
+// SubscribeOptions holds the optional parameters of a WS-Eventing
+// subscription.
+type SubscribeOptions struct {
+	// Expires is the requested duration of the subscription.
+	// If zero, the service chooses the duration.
+	Expires time.Duration
+
+	// EndTo, if not empty, is the address to which the service
+	// sends a SubscriptionEnd message if the subscription ends
+	// unexpectedly.
+	EndTo string
+
+	// Filter, if not empty, selects the notifications sent, in
+	// the filter language named by Dialect.
+	Filter, Dialect string
+}
+
+// A Subscription is a WS-Eventing subscription to the notifications
+// of an event source.
+type Subscription struct {
+	// Manager is the address of the subscription manager, to
+	// which Renew and Unsubscribe requests are sent.
+	Manager string
+
+	// Identifier is the identifier of the subscription, if the
+	// service assigned one.
+	Identifier string
+
+	// Expires holds the expiration of the subscription, as
+	// returned by the service: an xs:duration or xs:dateTime.
+	Expires string
+
+	client *Client
+}
+
+type wseSubscribe struct {
+	XMLName  xml.Name `xml:"http://schemas.xmlsoap.org/ws/2004/08/eventing Subscribe"`
+	EndTo    *wsaEPR  `xml:",omitempty"`
+	Delivery struct {
+		Mode     string `xml:"Mode,attr,omitempty"`
+		NotifyTo wsaEPR
+	} `xml:"http://schemas.xmlsoap.org/ws/2004/08/eventing Delivery"`
+	Expires string     `xml:"http://schemas.xmlsoap.org/ws/2004/08/eventing Expires,omitempty"`
+	Filter  *wseFilter `xml:",omitempty"`
+}
+
+type wseFilter struct {
+	XMLName xml.Name `xml:"http://schemas.xmlsoap.org/ws/2004/08/eventing Filter"`
+	Dialect string   `xml:"Dialect,attr,omitempty"`
+	Value   string   `xml:",chardata"`
+}
+
+type wseSubscribeResponse struct {
+	XMLName xml.Name `xml:"http://schemas.xmlsoap.org/ws/2004/08/eventing SubscribeResponse"`
+	Manager struct {
+		Address    string `xml:"Address"`
+		Identifier string `xml:"ReferenceParameters>Identifier"`
+	} `xml:"http://schemas.xmlsoap.org/ws/2004/08/eventing SubscriptionManager"`
+	Expires string `xml:"http://schemas.xmlsoap.org/ws/2004/08/eventing Expires"`
+}
+
+type wseRenew struct {
+	XMLName xml.Name `xml:"http://schemas.xmlsoap.org/ws/2004/08/eventing Renew"`
+	Expires string   `xml:"http://schemas.xmlsoap.org/ws/2004/08/eventing Expires,omitempty"`
+}
+
+type wseRenewResponse struct {
+	XMLName xml.Name `xml:"http://schemas.xmlsoap.org/ws/2004/08/eventing RenewResponse"`
+	Expires string   `xml:"http://schemas.xmlsoap.org/ws/2004/08/eventing Expires"`
+}
+
+type wseUnsubscribe struct {
+	XMLName xml.Name `xml:"http://schemas.xmlsoap.org/ws/2004/08/eventing Unsubscribe"`
+}
+
+// xsDuration formats d as an xs:duration, in whole seconds.
+func xsDuration(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("PT%dS", int64((d+time.Second-1)/time.Second))
+}
+
+// Subscribe subscribes to the notifications of the event source
+// reached by c, asking for them to be delivered to notifyTo, the
+// address at which an EventSink is served. opts may be nil.
+func Subscribe(ctx context.Context, c *Client, notifyTo string, opts *SubscribeOptions) (*Subscription, error) {
+	if opts == nil {
+		opts = new(SubscribeOptions)
+	}
+	addr := func(local, url string) wsaEPR {
+		return wsaEPR{
+			XMLName: xml.Name{Space: NsWSE, Local: local},
+			Address: wsaText{xml.Name{Space: NsWSA200408, Local: "Address"}, url},
+		}
+	}
+	var req wseSubscribe
+	req.Delivery.NotifyTo = addr("NotifyTo", notifyTo)
+	req.Expires = xsDuration(opts.Expires)
+	if opts.EndTo != "" {
+		endTo := addr("EndTo", opts.EndTo)
+		req.EndTo = &endTo
+	}
+	if opts.Filter != "" {
+		req.Filter = &wseFilter{Dialect: opts.Dialect, Value: opts.Filter}
+	}
+	var resp wseSubscribeResponse
+	if err := wseCall(ctx, c, "", "", "Subscribe", req, &resp); err != nil {
+		return nil, err
+	}
+	s := &Subscription{
+		Manager:    resp.Manager.Address,
+		Identifier: resp.Manager.Identifier,
+		Expires:    resp.Expires,
+		client:     c,
+	}
+	if s.Manager == "" {
+		s.Manager = c.URL
+	}
+	return s, nil
+}
+
+// wseCall makes a WS-Eventing request to the given address, or c.URL
+// if it is empty, adding a WS-Eventing Identifier header if id is set.
+func wseCall(ctx context.Context, c *Client, to, id, op string, req, resp interface{}) error {
+	var opts []CallOption
+	if id != "" {
+		opts = append(opts, WithSOAPHeader(wsaText{xml.Name{Space: NsWSE, Local: "Identifier"}, id}))
+	}
+	if to != "" {
+		opts = append(opts, WithEndpoint(to))
+	}
+	return addressedCall(ctx, c, NsWSE+"/"+op, req, resp, opts...)
+}
+
+// Renew asks the subscription manager to extend the subscription by
+// d, or by a duration of its choosing if d is zero.
+func (s *Subscription) Renew(ctx context.Context, d time.Duration) error {
+	var resp wseRenewResponse
+	err := wseCall(ctx, s.client, s.Manager, s.Identifier, "Renew", wseRenew{Expires: xsDuration(d)}, &resp)
+	if err != nil {
+		return err
+	}
+	if resp.Expires != "" {
+		s.Expires = resp.Expires
+	}
+	return nil
+}
+
+// Unsubscribe ends the subscription.
+func (s *Subscription) Unsubscribe(ctx context.Context) error {
+	return wseCall(ctx, s.client, s.Manager, s.Identifier, "Unsubscribe", wseUnsubscribe{}, nil)
+}
+
+// A Notification is a message delivered to an EventSink.
+type Notification struct {
+	// Action is the WS-Addressing action of the message, which
+	// identifies the kind of event.
+	Action string
+
+	// Message holds the SOAP message.
+	Message []byte
+
+	flat *Flattener
+}
+
+// Unmarshal decodes the first entry of the notification's Body
+// into v.
+func (n *Notification) Unmarshal(v interface{}) error {
+	flat := n.flat
+	if flat == nil {
+		flat = DefaultFlattener
+	}
+	return unmarshalBody(flat, n.Message, v)
+}
+
+// An EventSink is an http.Handler receiving the notifications of
+// WS-Eventing subscriptions. It must be served at the notifyTo
+// address passed to Subscribe.
+type EventSink struct {
+	// Notify is called with each notification received. It is
+	// called concurrently if notifications arrive concurrently.
+	Notify func(*Notification)
+
+	// End, if non-nil, is called with SubscriptionEnd messages
+	// sent to the sink, with the status and reason given by the
+	// event source.
+	End func(status, reason string)
+
+	// Flattener dereferences the document links of
+	// notifications decoded with Notification.Unmarshal. If
+	// nil, DefaultFlattener is used.
+	Flattener *Flattener
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (s *EventSink) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	data, err := readMessage(req.Header, req.Body, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var msg struct {
+		Header struct {
+			Action string `xml:"Action"`
+		} `xml:"Header"`
+		Body struct {
+			End *struct {
+				Status string `xml:"Status"`
+				Reason string `xml:"Reason"`
+			} `xml:"http://schemas.xmlsoap.org/ws/2004/08/eventing SubscriptionEnd"`
+		} `xml:"Body"`
+	}
+	if err := xml.Unmarshal(data, &msg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	if end := msg.Body.End; end != nil {
+		if s.End != nil {
+			s.End(end.Status, end.Reason)
+		}
+		return
+	}
+	if s.Notify != nil {
+		s.Notify(&Notification{Action: msg.Header.Action, Message: data, flat: s.Flattener})
+	}
+}
@@ -0,0 +1,125 @@
+package soap
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventing(t *testing.T) {
+	events := make(chan string, 1)
+	sink := httptest.NewServer(&EventSink{Notify: func(n *Notification) {
+		var v echoRequest
+		if err := n.Unmarshal(&v); err != nil {
+			t.Error(err)
+		}
+		events <- n.Action + " " + v.Value
+	}})
+	defer sink.Close()
+
+	var ops []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var msg struct {
+			Header struct {
+				Action     string `xml:"Action"`
+				Identifier string `xml:"http://schemas.xmlsoap.org/ws/2004/08/eventing Identifier"`
+			}
+			Body struct {
+				Subscribe *struct {
+					NotifyTo string `xml:"Delivery>NotifyTo>Address"`
+					Expires  string
+				} `xml:"http://schemas.xmlsoap.org/ws/2004/08/eventing Subscribe"`
+			}
+		}
+		if err := xml.Unmarshal(data, &msg); err != nil {
+			t.Error(err)
+		}
+		op := strings.TrimPrefix(msg.Header.Action, NsWSE+"/")
+		ops = append(ops, op)
+		w.Header().Set("Content-Type", "text/xml")
+		body := ""
+		switch op {
+		case "Subscribe":
+			if msg.Body.Subscribe.Expires != "PT60S" {
+				t.Errorf("Expires = %q", msg.Body.Subscribe.Expires)
+			}
+			body = fmt.Sprintf(`<wse:SubscribeResponse><wse:SubscriptionManager><wsa:Address>%s/manager</wsa:Address>
+<wsa:ReferenceParameters><wse:Identifier>uuid:sub1</wse:Identifier></wsa:ReferenceParameters>
+</wse:SubscriptionManager><wse:Expires>PT60S</wse:Expires></wse:SubscribeResponse>`, "http://"+r.Host)
+			go func() {
+				note := `<soapenv:Envelope xmlns:soapenv="` + NsSoapEnv + `" xmlns:wsa="` + NsWSA200408 + `">
+<soapenv:Header><wsa:Action>urn:test/Changed</wsa:Action></soapenv:Header>
+<soapenv:Body><t:Echo xmlns:t="urn:test"><value>on</value></t:Echo></soapenv:Body></soapenv:Envelope>`
+				if rsp, err := http.Post(msg.Body.Subscribe.NotifyTo, "text/xml", strings.NewReader(note)); err == nil {
+					rsp.Body.Close()
+				}
+			}()
+		case "Renew":
+			body = `<wse:RenewResponse><wse:Expires>PT120S</wse:Expires></wse:RenewResponse>`
+		}
+		if op != "Subscribe" && (r.URL.Path != "/manager" || msg.Header.Identifier != "uuid:sub1") {
+			t.Errorf("%s sent to %s with identifier %q", op, r.URL.Path, msg.Header.Identifier)
+		}
+		fmt.Fprintf(w, `<soapenv:Envelope xmlns:soapenv="%s" xmlns:wsa="%s" xmlns:wse="%s"><soapenv:Body>%s</soapenv:Body></soapenv:Envelope>`,
+			NsSoapEnv, NsWSA200408, NsWSE, body)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	sub, err := Subscribe(ctx, &Client{URL: srv.URL}, sink.URL, &SubscribeOptions{Expires: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ev := <-events:
+		if ev != "urn:test/Changed on" {
+			t.Errorf("got event %q", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification received")
+	}
+	if err := sub.Renew(ctx, 2*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if sub.Expires != "PT120S" {
+		t.Errorf("Expires = %q after renewal", sub.Expires)
+	}
+	if err := sub.Unsubscribe(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(ops, " "); got != "Subscribe Renew Unsubscribe" {
+		t.Errorf("got operations %s", got)
+	}
+}
+
+func TestEventSinkFlattener(t *testing.T) {
+	var got string
+	sink := &EventSink{
+		Flattener: &Flattener{DuplicateIDs: DuplicateFirst},
+		Notify: func(n *Notification) {
+			var v echoRequest
+			if err := n.Unmarshal(&v); err != nil {
+				t.Error(err)
+			}
+			got = v.Value
+		},
+	}
+	note := `<soapenv:Envelope xmlns:soapenv="` + NsSoapEnv + `"><soapenv:Body>
+<t:Echo xmlns:t="urn:test"><value href="#id0"/></t:Echo>
+<multiRef id="id0">first</multiRef><multiRef id="id0">second</multiRef>
+</soapenv:Body></soapenv:Envelope>`
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/", strings.NewReader(note))
+	req.Header.Set("Content-Type", "text/xml")
+	sink.ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted || got != "first" {
+		t.Errorf("status %d, got %q, want %q", rec.Code, got, "first")
+	}
+}
@@ -0,0 +1,106 @@
+// Package ews provides helpers for Exchange Web Services: the SOAP
+// headers selecting the schema version and impersonated user, the
+// ResponseMessages convention of EWS responses, and iteration over
+// paged FindItem and SyncFolderItems results.
+package ews
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+
+	"aqwari.net/exp/soap"
+)
+
+// Namespaces of EWS messages and types.
+const (
+	NsMessages = "http://schemas.microsoft.com/exchange/services/2006/messages"
+	NsTypes    = "http://schemas.microsoft.com/exchange/services/2006/types"
+)
+
+// A Client makes EWS requests.
+type Client struct {
+	// SOAP sends requests. Its URL is the EWS endpoint, such as
+	// "https://outlook.office365.com/EWS/Exchange.asmx".
+	SOAP *soap.Client
+
+	// Version is sent in the RequestServerVersion header, such
+	// as "Exchange2013_SP1". If empty, no header is sent and the
+	// server uses its oldest schema version.
+	Version string
+
+	// Impersonate, if not empty, is the SMTP address of the
+	// mailbox accessed through Exchange impersonation.
+	Impersonate string
+}
+
+// NewClient returns a Client for the EWS endpoint at url, requesting
+// the given schema version.
+func NewClient(url, version string) *Client {
+	return &Client{SOAP: &soap.Client{URL: url}, Version: version}
+}
+
+type requestServerVersion struct {
+	XMLName xml.Name `xml:"http://schemas.microsoft.com/exchange/services/2006/types RequestServerVersion"`
+	Version string   `xml:"Version,attr"`
+}
+
+type exchangeImpersonation struct {
+	XMLName xml.Name `xml:"http://schemas.microsoft.com/exchange/services/2006/types ExchangeImpersonation"`
+	Address string   `xml:"http://schemas.microsoft.com/exchange/services/2006/types ConnectingSID>PrimarySmtpAddress"`
+}
+
+// Call invokes an EWS operation, such as "GetItem". req is the
+// operation's request element, in the NsMessages namespace, and the
+// response element is decoded into resp. Errors reported in the
+// ResponseMessages of the response are not detected by Call; see
+// ResponseMessage.
+func (c *Client) Call(ctx context.Context, operation string, req, resp interface{}) error {
+	var header []interface{}
+	if c.Version != "" {
+		header = append(header, requestServerVersion{Version: c.Version})
+	}
+	if c.Impersonate != "" {
+		header = append(header, exchangeImpersonation{Address: c.Impersonate})
+	}
+	return c.SOAP.Call(ctx, NsMessages+"/"+operation, req, resp, soap.WithSOAPHeader(header...))
+}
+
+// A ResponseMessage holds the status common to the response messages
+// of all EWS operations. It is meant to be embedded in the types
+// decoding them:
+//
+//	var resp struct {
+//		Messages []struct {
+//			ews.ResponseMessage
+//			Items []ews.Item `xml:"Items>Item"`
+//		} `xml:"ResponseMessages>GetItemResponseMessage"`
+//	}
+type ResponseMessage struct {
+	Class string `xml:"ResponseClass,attr"`
+	Code  string `xml:"ResponseCode"`
+	Text  string `xml:"MessageText"`
+}
+
+// Err returns the error reported by the message, or nil if its
+// ResponseClass is Success or Warning.
+func (m *ResponseMessage) Err() error {
+	if m.Class == "Error" {
+		return &ResponseError{Code: m.Code, Text: m.Text}
+	}
+	return nil
+}
+
+// A ResponseError is an error reported in an EWS response message.
+type ResponseError struct {
+	// Code is the ResponseCode, such as
+	// "ErrorItemNotFound".
+	Code string
+
+	// Text is the description of the error.
+	Text string
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("ews: %s: %s", e.Code, e.Text)
+}
@@ -0,0 +1,133 @@
+package ews
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var msg struct {
+			Header struct {
+				Version struct {
+					Version string `xml:"Version,attr"`
+				} `xml:"RequestServerVersion"`
+				Impersonate string `xml:"ExchangeImpersonation>ConnectingSID>PrimarySmtpAddress"`
+			}
+			Body struct {
+				FindItem *struct {
+					View struct {
+						Offset int `xml:"Offset,attr"`
+					} `xml:"IndexedPageItemView"`
+					Folder struct {
+						ID string `xml:"Id,attr"`
+					} `xml:"ParentFolderIds>DistinguishedFolderId"`
+				}
+				SyncFolderItems *struct {
+					SyncState string `xml:"SyncState"`
+				}
+				GetItem *struct{}
+			}
+		}
+		if err := xml.Unmarshal(data, &msg); err != nil {
+			t.Error(err)
+		}
+		if msg.Header.Version.Version != "Exchange2013_SP1" || msg.Header.Impersonate != "user@example.com" {
+			t.Errorf("unexpected headers: %s", data)
+		}
+		var body string
+		switch b := msg.Body; {
+		case b.FindItem != nil:
+			if b.FindItem.Folder.ID != "inbox" {
+				t.Errorf("FindItem in folder %q", b.FindItem.Folder.ID)
+			}
+			last := b.FindItem.View.Offset >= 2
+			body = fmt.Sprintf(`<m:FindItemResponse><m:ResponseMessages>
+<m:FindItemResponseMessage ResponseClass="Success"><m:ResponseCode>NoError</m:ResponseCode>
+<m:RootFolder IndexedPagingOffset="%d" TotalItemsInView="3" IncludesLastItemInRange="%t"><t:Items>
+<t:Message><t:ItemId Id="m%d"/><t:Subject>Hello</t:Subject></t:Message>`, b.FindItem.View.Offset+2, last, b.FindItem.View.Offset)
+			if !last {
+				body += fmt.Sprintf(`<t:CalendarItem><t:ItemId Id="m%d"/></t:CalendarItem>`, b.FindItem.View.Offset+1)
+			}
+			body += `</t:Items></m:RootFolder></m:FindItemResponseMessage></m:ResponseMessages></m:FindItemResponse>`
+		case b.SyncFolderItems != nil:
+			next, last := "s1", false
+			changes := `<t:Create><t:Message><t:ItemId Id="m0"/></t:Message></t:Create>`
+			if b.SyncFolderItems.SyncState == "s1" {
+				next, last = "s2", true
+				changes = `<t:Delete><t:ItemId Id="m1"/></t:Delete><t:ReadFlagChange><t:ItemId Id="m2"/><t:IsRead>true</t:IsRead></t:ReadFlagChange>`
+			}
+			body = fmt.Sprintf(`<m:SyncFolderItemsResponse><m:ResponseMessages>
+<m:SyncFolderItemsResponseMessage ResponseClass="Success"><m:ResponseCode>NoError</m:ResponseCode>
+<m:SyncState>%s</m:SyncState><m:IncludesLastItemInRange>%t</m:IncludesLastItemInRange>
+<m:Changes>%s</m:Changes></m:SyncFolderItemsResponseMessage></m:ResponseMessages></m:SyncFolderItemsResponse>`,
+				next, last, changes)
+		case b.GetItem != nil:
+			body = `<m:GetItemResponse><m:ResponseMessages>
+<m:GetItemResponseMessage ResponseClass="Error"><m:MessageText>The specified object was not found in the store.</m:MessageText>
+<m:ResponseCode>ErrorItemNotFound</m:ResponseCode></m:GetItemResponseMessage></m:ResponseMessages></m:GetItemResponse>`
+		default:
+			t.Errorf("unexpected request %s", data)
+		}
+		w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+		fmt.Fprintf(w, `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" xmlns:m="%s" xmlns:t="%s"><s:Body>%s</s:Body></s:Envelope>`,
+			NsMessages, NsTypes, body)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	c := NewClient(srv.URL, "Exchange2013_SP1")
+	c.Impersonate = "user@example.com"
+
+	var found []string
+	p := c.FindItem(FolderID{Distinguished: "inbox"}, &FindItemOptions{PageSize: 2})
+	for p.Next(ctx) {
+		for _, it := range p.Items() {
+			found = append(found, it.Kind+":"+it.ItemID.ID)
+		}
+	}
+	if err := p.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(found, ","); got != "Message:m0,CalendarItem:m1,Message:m2" || p.Total() != 3 {
+		t.Errorf("found %s, total %d", got, p.Total())
+	}
+
+	var changes []string
+	s := c.SyncFolderItems(FolderID{Distinguished: "inbox"}, "", nil)
+	for s.Next(ctx) {
+		for _, ch := range s.Changes() {
+			changes = append(changes, fmt.Sprintf("%s:%s:%t", ch.Kind, ch.Item.ItemID.ID, ch.IsRead))
+		}
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(changes, ","); got != "Create:m0:false,Delete:m1:false,ReadFlagChange:m2:true" || s.State() != "s2" {
+		t.Errorf("changes %s, state %s", got, s.State())
+	}
+
+	var resp struct {
+		Messages []struct {
+			ResponseMessage
+		} `xml:"ResponseMessages>GetItemResponseMessage"`
+	}
+	req := struct {
+		XMLName xml.Name `xml:"http://schemas.microsoft.com/exchange/services/2006/messages GetItem"`
+	}{}
+	if err := c.Call(ctx, "GetItem", req, &resp); err != nil {
+		t.Fatal(err)
+	}
+	var rerr *ResponseError
+	if len(resp.Messages) != 1 || !errors.As(resp.Messages[0].Err(), &rerr) || rerr.Code != "ErrorItemNotFound" {
+		t.Errorf("got %+v", resp.Messages)
+	}
+}
@@ -0,0 +1,350 @@
+package ews
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"time"
+)
+
+// A FolderID identifies a folder, either by its ID, or by the name of
+// a distinguished folder such as "inbox" or "calendar".
+type FolderID struct {
+	ID, ChangeKey string
+	Distinguished string
+}
+
+// MarshalXML encodes f as a t:FolderId or t:DistinguishedFolderId
+// element.
+func (f FolderID) MarshalXML(e *xml.Encoder, _ xml.StartElement) error {
+	start := xml.StartElement{Name: xml.Name{Space: NsTypes, Local: "FolderId"}}
+	if f.Distinguished != "" {
+		start.Name.Local = "DistinguishedFolderId"
+		start.Attr = []xml.Attr{{Name: xml.Name{Local: "Id"}, Value: f.Distinguished}}
+	} else {
+		start.Attr = []xml.Attr{{Name: xml.Name{Local: "Id"}, Value: f.ID}}
+		if f.ChangeKey != "" {
+			start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "ChangeKey"}, Value: f.ChangeKey})
+		}
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// An ItemID identifies an item.
+type ItemID struct {
+	ID        string `xml:"Id,attr"`
+	ChangeKey string `xml:"ChangeKey,attr,omitempty"`
+}
+
+// An Item holds the properties common to all kinds of items that are
+// returned by FindItem and SyncFolderItems. Which properties are set
+// depends on the requested shape.
+type Item struct {
+	// Kind is the name of the item's element, such as "Message"
+	// or "CalendarItem".
+	Kind string `xml:"-"`
+
+	ItemID           ItemID    `xml:"ItemId"`
+	ItemClass        string    `xml:"ItemClass"`
+	Subject          string    `xml:"Subject"`
+	Size             int       `xml:"Size"`
+	DateTimeReceived time.Time `xml:"DateTimeReceived"`
+	IsRead           bool      `xml:"IsRead"`
+}
+
+// UnmarshalXML decodes an item of any kind.
+func (it *Item) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type item Item
+	if err := d.DecodeElement((*item)(it), &start); err != nil {
+		return err
+	}
+	it.Kind = start.Name.Local
+	return nil
+}
+
+// items decodes the heterogeneous children of a t:Items element.
+type items []Item
+
+func (s *items) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			var it Item
+			if err := d.DecodeElement(&it, &tok); err != nil {
+				return err
+			}
+			*s = append(*s, it)
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+type itemShape struct {
+	BaseShape string `xml:"http://schemas.microsoft.com/exchange/services/2006/types BaseShape"`
+}
+
+// FindItemOptions holds the optional parameters of FindItem.
+type FindItemOptions struct {
+	// Shape is the set of properties returned for each item:
+	// "IdOnly", "Default" or "AllProperties". If empty, "Default"
+	// is used.
+	Shape string
+
+	// Traversal is "Shallow", the default, or "SoftDeleted".
+	Traversal string
+
+	// PageSize is the maximum number of items in each page. If
+	// zero, 100 is used.
+	PageSize int
+}
+
+type findItem struct {
+	XMLName   xml.Name  `xml:"http://schemas.microsoft.com/exchange/services/2006/messages FindItem"`
+	Traversal string    `xml:"Traversal,attr"`
+	ItemShape itemShape `xml:"http://schemas.microsoft.com/exchange/services/2006/messages ItemShape"`
+	View      struct {
+		MaxEntriesReturned int    `xml:"MaxEntriesReturned,attr"`
+		Offset             int    `xml:"Offset,attr"`
+		BasePoint          string `xml:"BasePoint,attr"`
+	} `xml:"http://schemas.microsoft.com/exchange/services/2006/messages IndexedPageItemView"`
+	Folder FolderID `xml:"http://schemas.microsoft.com/exchange/services/2006/messages ParentFolderIds>FolderId"`
+}
+
+type findItemResponse struct {
+	Messages []struct {
+		ResponseMessage
+		RootFolder struct {
+			Offset       int   `xml:"IndexedPagingOffset,attr"`
+			Total        int   `xml:"TotalItemsInView,attr"`
+			IncludesLast bool  `xml:"IncludesLastItemInRange,attr"`
+			Items        items `xml:"Items"`
+		} `xml:"RootFolder"`
+	} `xml:"ResponseMessages>FindItemResponseMessage"`
+}
+
+var errNoResponseMessage = errors.New("ews: response has no response message")
+
+// A FindItemPager iterates over the pages of the items in a folder,
+// requesting each with FindItem.
+type FindItemPager struct {
+	c     *Client
+	req   findItem
+	items []Item
+	total int
+	done  bool
+	err   error
+}
+
+// FindItem returns a FindItemPager over the items in folder. No
+// request is made until its Next method is called.
+func (c *Client) FindItem(folder FolderID, opts *FindItemOptions) *FindItemPager {
+	if opts == nil {
+		opts = new(FindItemOptions)
+	}
+	p := &FindItemPager{c: c}
+	p.req.Folder = folder
+	p.req.Traversal = opts.Traversal
+	if p.req.Traversal == "" {
+		p.req.Traversal = "Shallow"
+	}
+	p.req.ItemShape.BaseShape = opts.Shape
+	if p.req.ItemShape.BaseShape == "" {
+		p.req.ItemShape.BaseShape = "Default"
+	}
+	p.req.View.MaxEntriesReturned = opts.PageSize
+	if p.req.View.MaxEntriesReturned <= 0 {
+		p.req.View.MaxEntriesReturned = 100
+	}
+	p.req.View.BasePoint = "Beginning"
+	return p
+}
+
+// Next requests the next page of items, reporting whether there is
+// one. It returns false when the last page has been returned or an
+// error occurs.
+func (p *FindItemPager) Next(ctx context.Context) bool {
+	if p.done || p.err != nil {
+		return false
+	}
+	var resp findItemResponse
+	if err := p.c.Call(ctx, "FindItem", &p.req, &resp); err != nil {
+		p.err = err
+		return false
+	}
+	if len(resp.Messages) == 0 {
+		p.err = errNoResponseMessage
+		return false
+	}
+	m := resp.Messages[0]
+	if err := m.Err(); err != nil {
+		p.err = err
+		return false
+	}
+	root := m.RootFolder
+	p.items = root.Items
+	p.total = root.Total
+	p.done = root.IncludesLast || len(root.Items) == 0
+	p.req.View.Offset = root.Offset
+	return true
+}
+
+// Items returns the items of the current page.
+func (p *FindItemPager) Items() []Item { return p.items }
+
+// Total returns the number of items in the folder, as reported with
+// the current page.
+func (p *FindItemPager) Total() int { return p.total }
+
+// Err returns the error that ended the iteration, if any.
+func (p *FindItemPager) Err() error { return p.err }
+
+// A Change is a change to an item reported by SyncFolderItems.
+type Change struct {
+	// Kind is "Create", "Update", "Delete" or "ReadFlagChange".
+	Kind string
+
+	// Item is the created or updated item. For other kinds of
+	// change, only its ItemID is set.
+	Item Item
+
+	// IsRead is the new read flag of a ReadFlagChange.
+	IsRead bool
+}
+
+// changes decodes the children of a m:Changes element.
+type changes []Change
+
+func (s *changes) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			var c struct {
+				Item   *Item  `xml:",any"`
+				ItemID ItemID `xml:"ItemId"`
+				IsRead bool   `xml:"IsRead"`
+			}
+			if err := d.DecodeElement(&c, &tok); err != nil {
+				return err
+			}
+			ch := Change{Kind: tok.Name.Local, IsRead: c.IsRead}
+			if c.Item != nil {
+				ch.Item = *c.Item
+			} else {
+				ch.Item.ItemID = c.ItemID
+			}
+			*s = append(*s, ch)
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// SyncOptions holds the optional parameters of SyncFolderItems.
+type SyncOptions struct {
+	// Shape is the set of properties returned for created and
+	// updated items. If empty, "IdOnly" is used.
+	Shape string
+
+	// PageSize is the maximum number of changes in each page,
+	// between 1 and 512. If zero, 100 is used.
+	PageSize int
+}
+
+type syncFolderItems struct {
+	XMLName            xml.Name  `xml:"http://schemas.microsoft.com/exchange/services/2006/messages SyncFolderItems"`
+	ItemShape          itemShape `xml:"http://schemas.microsoft.com/exchange/services/2006/messages ItemShape"`
+	Folder             FolderID  `xml:"http://schemas.microsoft.com/exchange/services/2006/messages SyncFolderId>FolderId"`
+	SyncState          string    `xml:"http://schemas.microsoft.com/exchange/services/2006/messages SyncState,omitempty"`
+	MaxChangesReturned int       `xml:"http://schemas.microsoft.com/exchange/services/2006/messages MaxChangesReturned"`
+}
+
+type syncFolderItemsResponse struct {
+	Messages []struct {
+		ResponseMessage
+		SyncState    string  `xml:"SyncState"`
+		IncludesLast bool    `xml:"IncludesLastItemInRange"`
+		Changes      changes `xml:"Changes"`
+	} `xml:"ResponseMessages>SyncFolderItemsResponseMessage"`
+}
+
+// A SyncPager iterates over the pages of changes to the items in a
+// folder, requesting each with SyncFolderItems.
+type SyncPager struct {
+	c       *Client
+	req     syncFolderItems
+	changes []Change
+	done    bool
+	err     error
+}
+
+// SyncFolderItems returns a SyncPager over the changes made to the
+// items in folder since the synchronization state state was returned.
+// If state is empty, all items in the folder are reported as created.
+// No request is made until its Next method is called.
+func (c *Client) SyncFolderItems(folder FolderID, state string, opts *SyncOptions) *SyncPager {
+	if opts == nil {
+		opts = new(SyncOptions)
+	}
+	p := &SyncPager{c: c}
+	p.req.Folder = folder
+	p.req.SyncState = state
+	p.req.ItemShape.BaseShape = opts.Shape
+	if p.req.ItemShape.BaseShape == "" {
+		p.req.ItemShape.BaseShape = "IdOnly"
+	}
+	p.req.MaxChangesReturned = opts.PageSize
+	if p.req.MaxChangesReturned <= 0 {
+		p.req.MaxChangesReturned = 100
+	}
+	return p
+}
+
+// Next requests the next page of changes, reporting whether there is
+// one. It returns false when the folder is synchronized or an error
+// occurs.
+func (p *SyncPager) Next(ctx context.Context) bool {
+	if p.done || p.err != nil {
+		return false
+	}
+	var resp syncFolderItemsResponse
+	if err := p.c.Call(ctx, "SyncFolderItems", &p.req, &resp); err != nil {
+		p.err = err
+		return false
+	}
+	if len(resp.Messages) == 0 {
+		p.err = errNoResponseMessage
+		return false
+	}
+	m := resp.Messages[0]
+	if err := m.Err(); err != nil {
+		p.err = err
+		return false
+	}
+	p.changes = m.Changes
+	p.done = m.IncludesLast
+	p.req.SyncState = m.SyncState
+	return true
+}
+
+// Changes returns the changes of the current page.
+func (p *SyncPager) Changes() []Change { return p.changes }
+
+// State returns the synchronization state after the current page,
+// to be saved and passed to a later SyncFolderItems to receive only
+// the changes made since.
+func (p *SyncPager) State() string { return p.req.SyncState }
+
+// Err returns the error that ended the iteration, if any.
+func (p *SyncPager) Err() error { return p.err }
@@ -4,6 +4,7 @@ import (
 	"encoding/xml"
 	"fmt"
 	"log"
+	"os"
 )
 
 var xmlData = []byte(`<Envelope>
@@ -30,3 +31,28 @@ func ExampleUnmarshal() {
 	// Output:
 	// 123456
 }
+
+func ExampleEncoder() {
+	type getQuote struct {
+		XMLName xml.Name `xml:"urn:quotes GetQuote"`
+		Symbol  string   `xml:"symbol"`
+	}
+	enc := NewEncoder(os.Stdout)
+	if err := enc.Encode(getQuote{Symbol: "GOOG"}); err != nil {
+		log.Fatal(err)
+	}
+	// Output:
+	// <soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/"><soapenv:Body><GetQuote xmlns="urn:quotes"><symbol>GOOG</symbol></GetQuote></soapenv:Body></soapenv:Envelope>
+}
+
+func ExampleFlattener() {
+	// The zero Flattener resolves links without removing the
+	// multiRef elements they point to.
+	out, err := new(Flattener).Flatten([]byte(`<Body><total href="#id0"/><multiRef id="id0">42</multiRef></Body>`))
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(out))
+	// Output:
+	// <Body><total href="#id0">42</total><multiRef id="id0">42</multiRef></Body>
+}
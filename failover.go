@@ -0,0 +1,71 @@
+package soap
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// A Failover is an ordered list of endpoints for a service, such as
+// the regional addresses listed for a port in a WSDL. A Client using
+// a Failover sends each request to the first endpoint that is not
+// marked down, moving on to the next if the request fails in transit
+// or the endpoint reports it is unavailable. Failed endpoints are
+// marked down for Cooldown; if every endpoint is down, they are all
+// tried in order. A Failover is safe for concurrent use.
+type Failover struct {
+	URLs []string
+
+	// Cooldown is how long a failed endpoint is passed over.
+	// If zero, 30 seconds is used.
+	Cooldown time.Duration
+
+	mu   sync.Mutex
+	down map[string]time.Time
+}
+
+// NewFailover returns a Failover for the given endpoints, in
+// order of preference.
+func NewFailover(urls ...string) *Failover {
+	return &Failover{URLs: urls}
+}
+
+// endpoints returns the endpoints to try, healthy ones first.
+func (f *Failover) endpoints() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	healthy := make([]string, 0, len(f.URLs))
+	var down []string
+	for _, url := range f.URLs {
+		if t, ok := f.down[url]; ok && now.Before(t) {
+			down = append(down, url)
+		} else {
+			healthy = append(healthy, url)
+		}
+	}
+	return append(healthy, down...)
+}
+
+// failed records the outcome of a request to url, and reports
+// whether another endpoint should be tried.
+func (f *Failover) failed(url string, err error) bool {
+	failed := !isResponse(err)
+	if err, ok := err.(*StatusError); ok {
+		switch err.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			failed = true
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !failed {
+		delete(f.down, url)
+		return false
+	}
+	if f.down == nil {
+		f.down = make(map[string]time.Time)
+	}
+	f.down[url] = time.Now().Add(durationOr(f.Cooldown, 30*time.Second))
+	return true
+}
@@ -0,0 +1,247 @@
+package soap
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// A Gateway is an http.Handler offering SOAP operations of a service
+// as JSON endpoints, a REST facade for services that only speak SOAP.
+// A request is a JSON object POSTed to the path of an operation. Its
+// members become the children of the operation's request element, in
+// order: objects become elements with children, arrays repeated
+// elements, null an element marked with xsi:nil, and other values
+// elements with text. The first entry of the response Body is
+// returned in its JSON form, as described for JSONOptions.
+//
+// Faults are returned with the status 502 Bad Gateway, as an object
+// with a "fault" member holding their code, string, actor and detail.
+// Other errors are returned as an object with an "error" member, as
+// described for ErrorMessage.
+type Gateway struct {
+	// Client calls the service.
+	Client *Client
+
+	// Operations maps the paths of requests, such as "/quote",
+	// to the operations they call.
+	Operations map[string]GatewayOperation
+
+	// JSON, if non-nil, selects the conventions used to write
+	// responses as JSON.
+	JSON *JSONOptions
+
+	// MaxRequestSize, if positive, is the size in bytes above
+	// which a JSON request is rejected with the status 413
+	// Request Entity Too Large. If zero, 1MiB is used. If
+	// negative, requests of any size are read.
+	MaxRequestSize int64
+
+	// ErrorMessage, if non-nil, returns the message sent to the
+	// caller for an error answered with the given status. If
+	// nil, errors in the request itself are described, and
+	// others, such as those calling the service, are reported
+	// by the text of their status alone, so that the addresses
+	// of the service and the details of its transport are not
+	// disclosed.
+	ErrorMessage func(status int, err error) string
+}
+
+// A GatewayOperation describes the SOAP operation called for a JSON
+// request.
+type GatewayOperation struct {
+	// Action is the SOAPAction of the operation.
+	Action string
+
+	// Request is the name of the element carrying the request
+	// in the Body.
+	Request xml.Name
+
+	// Qualified puts the elements made from the JSON request in
+	// the namespace of Request, as for schemas whose
+	// elementFormDefault is qualified. Otherwise they are in no
+	// namespace.
+	Qualified bool
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	op, ok := g.Operations[req.URL.Path]
+	if !ok {
+		g.writeError(w, http.StatusNotFound, errors.New("soap: no operation at "+req.URL.Path))
+		return
+	}
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		g.writeError(w, http.StatusMethodNotAllowed, errors.New("soap: gateway requests must be POSTed"))
+		return
+	}
+	body := req.Body
+	if limit := g.maxRequestSize(); limit >= 0 {
+		body = http.MaxBytesReader(w, body, limit)
+	}
+	data, err := io.ReadAll(body)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		g.writeError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("soap: gateway request larger than %d bytes", tooLarge.Limit))
+		return
+	} else if err != nil {
+		g.writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if !json.Valid(data) || len(bytes.TrimSpace(data)) == 0 || bytes.TrimSpace(data)[0] != '{' {
+		g.writeError(w, http.StatusBadRequest, errors.New("soap: gateway request is not a JSON object"))
+		return
+	}
+	out := jsonResult{opts: g.JSON}
+	err = g.Client.Call(req.Context(), op.Action, jsonEntry{op, data}, &out)
+	var fault *Fault
+	var key *jsonKeyError
+	switch {
+	case errors.As(err, &key):
+		g.writeError(w, http.StatusBadRequest, err)
+	case errors.As(err, &fault):
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(map[string]interface{}{"fault": map[string]string{
+			"code":   fault.Code,
+			"string": fault.String,
+			"actor":  fault.Actor,
+			"detail": string(fault.Detail),
+		}})
+	case err != nil:
+		g.writeError(w, http.StatusBadGateway, err)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(append(out.data, '\n'))
+	}
+}
+
+func (g *Gateway) maxRequestSize() int64 {
+	if g.MaxRequestSize == 0 {
+		return 1 << 20
+	}
+	return g.MaxRequestSize
+}
+
+// writeError answers a request with an error, as described for
+// Gateway.ErrorMessage.
+func (g *Gateway) writeError(w http.ResponseWriter, status int, err error) {
+	var msg string
+	switch {
+	case g.ErrorMessage != nil:
+		msg = g.ErrorMessage(status, err)
+	case status/100 == 4:
+		msg = err.Error()
+	default:
+		msg = http.StatusText(status)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+// A jsonEntry is the Body entry of a request made from a JSON object.
+type jsonEntry struct {
+	op   GatewayOperation
+	data []byte
+}
+
+// MarshalXML writes the request element. Its namespace is bound to a
+// prefix, so that unqualified children are in no namespace.
+func (j jsonEntry) MarshalXML(e *xml.Encoder, _ xml.StartElement) error {
+	d := json.NewDecoder(bytes.NewReader(j.data))
+	d.UseNumber()
+	name := j.op.Request.Local
+	var attr []xml.Attr
+	if j.op.Request.Space != "" {
+		name = "tns:" + name
+		attr = []xml.Attr{{Name: xml.Name{Local: "xmlns:tns"}, Value: j.op.Request.Space}}
+	}
+	return j.encodeValue(e, d, xml.StartElement{Name: xml.Name{Local: name}, Attr: attr})
+}
+
+// encodeValue writes the next JSON value read from d as the element
+// started by start, or as repeated elements if it is an array.
+func (j jsonEntry) encodeValue(e *xml.Encoder, d *json.Decoder, start xml.StartElement) error {
+	tok, err := d.Token()
+	if err != nil {
+		return err
+	}
+	if tok == json.Delim('[') {
+		for d.More() {
+			if err := j.encodeValue(e, d, start); err != nil {
+				return err
+			}
+		}
+		_, err := d.Token()
+		return err
+	}
+	if tok == nil {
+		start.Attr = append(start.Attr,
+			xml.Attr{Name: xml.Name{Local: "xmlns:xsi"}, Value: NsXSI},
+			xml.Attr{Name: xml.Name{Local: "xsi:nil"}, Value: "true"})
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	switch tok := tok.(type) {
+	case json.Delim: // '{'
+		for d.More() {
+			key, err := d.Token()
+			if err != nil {
+				return err
+			}
+			local := key.(string)
+			if !validName(local) || strings.ContainsAny(local, ":<>&'\" \t\r\n") {
+				return &jsonKeyError{local}
+			}
+			if j.op.Qualified && j.op.Request.Space != "" {
+				local = "tns:" + local
+			}
+			if err := j.encodeValue(e, d, xml.StartElement{Name: xml.Name{Local: local}}); err != nil {
+				return err
+			}
+		}
+		if _, err := d.Token(); err != nil {
+			return err
+		}
+	case string:
+		err = e.EncodeToken(xml.CharData(tok))
+	case json.Number:
+		err = e.EncodeToken(xml.CharData(tok))
+	case bool:
+		err = e.EncodeToken(xml.CharData(fmt.Sprint(tok)))
+	}
+	if err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// A jsonKeyError is returned for a member of a JSON request whose key
+// is not an XML name.
+type jsonKeyError struct {
+	key string
+}
+
+func (e *jsonKeyError) Error() string {
+	return fmt.Sprintf("soap: JSON key %q is not an element name", e.key)
+}
+
+// A jsonResult holds the JSON form of a response entry.
+type jsonResult struct {
+	opts *JSONOptions
+	data []byte
+}
+
+func (r *jsonResult) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	data, err := ElementToJSON(d, start, r.opts)
+	r.data = data
+	return err
+}
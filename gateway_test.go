@@ -0,0 +1,112 @@
+package soap
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGateway(t *testing.T) {
+	var request string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		request = string(data)
+		w.Header().Set("Content-Type", "text/xml")
+		if strings.Contains(request, "<symbol>FAIL</symbol>") {
+			w.WriteHeader(http.StatusInternalServerError)
+			io.WriteString(w, `<s:Envelope xmlns:s="`+NsSoapEnv+`"><s:Body><s:Fault>`+
+				`<faultcode>s:Client</faultcode><faultstring>unknown symbol</faultstring></s:Fault></s:Body></s:Envelope>`)
+			return
+		}
+		io.WriteString(w, `<s:Envelope xmlns:s="`+NsSoapEnv+`"><s:Body>
+<q:QuoteResponse xmlns:q="urn:quotes" currency="USD">
+  <q:price href="#p"/>
+  <q:tag>a</q:tag><q:tag>b</q:tag>
+  <q:note xsi:nil="true" xmlns:xsi="`+NsXSI+`"/>
+</q:QuoteResponse>
+<multiRef id="p">1.5</multiRef>
+</s:Body></s:Envelope>`)
+	}))
+	defer backend.Close()
+
+	gw := httptest.NewServer(&Gateway{
+		Client: &Client{URL: backend.URL},
+		Operations: map[string]GatewayOperation{
+			"/quote": {Action: "urn:quotes#Quote", Request: xml.Name{Space: "urn:quotes", Local: "Quote"}},
+		},
+	})
+	defer gw.Close()
+
+	post := func(path, body string) (int, string) {
+		resp, err := http.Post(gw.URL+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, strings.TrimSpace(string(data))
+	}
+
+	status, body := post("/quote", `{"symbol": "ACME", "count": 2, "venues": ["x", "y"], "opts": {"live": true}, "as_of": null}`)
+	if status != http.StatusOK {
+		t.Fatalf("status %d: %s", status, body)
+	}
+	for _, want := range []string{
+		`<tns:Quote xmlns:tns="urn:quotes"><symbol>ACME</symbol><count>2</count><venues>x</venues><venues>y</venues><opts><live>true</live></opts>`,
+		`<as_of xmlns:xsi="` + NsXSI + `" xsi:nil="true"></as_of></tns:Quote>`,
+	} {
+		if !strings.Contains(request, want) {
+			t.Errorf("request %s\ndoes not contain %s", request, want)
+		}
+	}
+	if want := `{"@currency":"USD","price":"1.5","tag":["a","b"],"note":null}`; body != want {
+		t.Errorf("got %s, want %s", body, want)
+	}
+
+	if status, body = post("/quote", `{"symbol": "FAIL"}`); status != http.StatusBadGateway || !strings.Contains(body, `"string":"unknown symbol"`) {
+		t.Errorf("fault: status %d: %s", status, body)
+	}
+	if status, _ = post("/quote", `[1, 2]`); status != http.StatusBadRequest {
+		t.Errorf("array request: status %d, want %d", status, http.StatusBadRequest)
+	}
+	if status, _ = post("/quote", `{"a b": 1}`); status != http.StatusBadRequest {
+		t.Errorf("invalid key: status %d, want %d", status, http.StatusBadRequest)
+	}
+	if status, _ = post("/other", `{}`); status != http.StatusNotFound {
+		t.Errorf("unknown path: status %d, want %d", status, http.StatusNotFound)
+	}
+}
+
+func TestGatewayErrors(t *testing.T) {
+	backend := httptest.NewServer(http.NotFoundHandler())
+	backendURL := backend.URL
+	backend.Close()
+
+	g := &Gateway{
+		Client:         &Client{URL: backendURL},
+		Operations:     map[string]GatewayOperation{"/quote": {Action: "Quote", Request: xml.Name{Local: "Quote"}}},
+		MaxRequestSize: 32,
+	}
+	post := func(body string) (int, string) {
+		rec := httptest.NewRecorder()
+		g.ServeHTTP(rec, httptest.NewRequest("POST", "/quote", strings.NewReader(body)))
+		return rec.Code, strings.TrimSpace(rec.Body.String())
+	}
+	if status, _ := post(`{"symbol": "` + strings.Repeat("x", 64) + `"}`); status != http.StatusRequestEntityTooLarge {
+		t.Errorf("large request: status %d, want %d", status, http.StatusRequestEntityTooLarge)
+	}
+	status, body := post(`{"symbol": "ACME"}`)
+	if status != http.StatusBadGateway || body != `{"error":"Bad Gateway"}` {
+		t.Errorf("unreachable service: status %d: %s", status, body)
+	}
+	if strings.Contains(body, backendURL) {
+		t.Errorf("error discloses the service address: %s", body)
+	}
+	g.ErrorMessage = func(status int, err error) string { return "call failed" }
+	if _, body = post(`{"symbol": "ACME"}`); body != `{"error":"call failed"}` {
+		t.Errorf("ErrorMessage not used: %s", body)
+	}
+}
@@ -0,0 +1,61 @@
+package soap
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// NewGetRequest creates an http Request invoking an operation using
+// the HTTP GET binding of SOAP 1.2, in which there is no request
+// envelope and the operation's parameters are carried in the URL.
+// query, if non-nil, is added to the query string of endpoint. As
+// with NewRequest, credentials in endpoint are sent using HTTP Basic
+// authentication.
+func NewGetRequest(endpoint string, query url.Values) (*http.Request, error) {
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if user := req.URL.User; user != nil {
+		password, _ := user.Password()
+		req.SetBasicAuth(user.Username(), password)
+		req.URL.User = nil
+	}
+	if len(query) > 0 {
+		q := req.URL.Query()
+		for k, v := range query {
+			q[k] = append(q[k], v...)
+		}
+		req.URL.RawQuery = q.Encode()
+	}
+	req.Header.Set("Accept", "application/soap+xml")
+	return req, nil
+}
+
+// Get invokes a read-only operation of a SOAP 1.2 service that
+// supports the HTTP GET binding, and decodes the first entry of the
+// response Body into resp. Because the request is an HTTP GET, its
+// response may be cached by HTTP caches between the Client and the
+// service. The action is not sent; it selects the settings in
+// c.Operations and is reported to c.Trace. Otherwise, Get behaves
+// like Call.
+func (c *Client) Get(ctx context.Context, action string, query url.Values, resp interface{}, opts ...CallOption) error {
+	return c.reauthCall(ctx, func() error {
+		ctx := ctx
+		if t := c.timeouts(action); t.Call > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, t.Call)
+			defer cancel()
+		}
+		x := &exchange{action: action, version: V12, get: true, query: query}
+		for _, opt := range opts {
+			opt(x)
+		}
+		data, err := c.roundTrip(ctx, x)
+		if err != nil || resp == nil {
+			return err
+		}
+		return unmarshalBody(c.Profile.flattener(), data, resp)
+	})
+}
@@ -0,0 +1,104 @@
+package soap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+)
+
+// A HeaderBlock is an entry of the SOAP Header of a received message.
+// It is kept as XML, so that applications can pull values out of
+// headers they have not declared types for.
+type HeaderBlock struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+
+	// Content holds the XML within the block, as it appears in
+	// the message.
+	Content []byte `xml:",innerxml"`
+}
+
+// Attr returns the value of the block's attribute with the given
+// namespace and local name. If space is empty, an attribute in any
+// namespace matches.
+func (b *HeaderBlock) Attr(space, local string) (string, bool) {
+	return FindAttr(b.Attrs, space, local)
+}
+
+// Text returns the character data directly within the block, such
+// as the value of a wsa:MessageID header, without the surrounding
+// white space.
+func (b *HeaderBlock) Text() string {
+	var text strings.Builder
+	d := xml.NewDecoder(bytes.NewReader(b.Content))
+	depth := 0
+	for {
+		tok, err := d.RawToken()
+		if err != nil {
+			break
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			if depth == 0 {
+				text.Write(tok)
+			}
+		}
+	}
+	return strings.TrimSpace(text.String())
+}
+
+// MustUnderstand reports whether the block carries a mustUnderstand
+// attribute of SOAP 1.1 or 1.2 that is set.
+func (b *HeaderBlock) MustUnderstand() bool {
+	for _, ns := range []string{NsSoapEnv, NsSoap12Env} {
+		if v, ok := b.Attr(ns, "mustUnderstand"); ok {
+			return v == "1" || v == "true"
+		}
+	}
+	return false
+}
+
+// A Header holds the entries of the SOAP Header of a message, in
+// order.
+type Header []HeaderBlock
+
+// ReadHeader returns the entries of the SOAP Header of a message of
+// either version. A message without a Header has no entries.
+func ReadHeader(data []byte) (Header, error) {
+	var msg struct {
+		Header struct {
+			Blocks []HeaderBlock `xml:",any"`
+		} `xml:"Header"`
+	}
+	if err := xml.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+	return msg.Header.Blocks, nil
+}
+
+// Get returns the first entry with the given namespace and local
+// name, or nil if there is none. If space is empty, an entry in any
+// namespace matches.
+func (h Header) Get(space, local string) *HeaderBlock {
+	for i := range h {
+		if h[i].XMLName.Local == local && (space == "" || h[i].XMLName.Space == space) {
+			return &h[i]
+		}
+	}
+	return nil
+}
+
+// FindAttr returns the value of the attribute in list with the given
+// namespace and local name. If space is empty, an attribute in any
+// namespace matches. It is meant for the attributes of elements
+// decoded with an xml.StartElement, or a field tagged ",any,attr".
+func FindAttr(list []xml.Attr, space, local string) (string, bool) {
+	if a := findAttr(list, space, local); a != nil {
+		return a.Value, true
+	}
+	return "", false
+}
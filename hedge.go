@@ -0,0 +1,75 @@
+package soap
+
+import (
+	"context"
+	"time"
+)
+
+// A HedgePolicy controls the hedging of requests for idempotent
+// operations. If no response has been received Delay after a request
+// is sent, a duplicate request is sent, and the first response to
+// arrive is used. This trades extra load on the service for lower
+// tail latency when individual servers are slow.
+type HedgePolicy struct {
+	// Delay is the time to wait for a response before
+	// sending each duplicate request.
+	Delay time.Duration
+
+	// Max is the maximum number of duplicate requests sent
+	// for each attempt. If zero, 1 is used.
+	Max int
+}
+
+type hedgeResult struct {
+	data []byte
+	err  error
+}
+
+// isResponse reports whether an attempt ending in err received a
+// response from the service, as opposed to failing in transit.
+func isResponse(err error) bool {
+	switch err.(type) {
+	case nil, *Fault, *StatusError:
+		return true
+	}
+	return false
+}
+
+// hedge makes an attempt at a call with duplicate requests, as
+// described by c.Hedge. Requests still in flight once a response
+// has been received are canceled.
+func (c *Client) hedge(ctx context.Context, x *exchange) ([]byte, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan hedgeResult, 1+intOr(c.Hedge.Max, 1))
+	launch := func() {
+		go func() {
+			data, err := c.send(ctx, x)
+			results <- hedgeResult{data, err}
+		}()
+	}
+	launch()
+	sent, pending := 1, 1
+
+	timer := time.NewTimer(c.Hedge.Delay)
+	defer timer.Stop()
+	for {
+		select {
+		case r := <-results:
+			pending--
+			if isResponse(r.err) || pending == 0 {
+				return r.data, r.err
+			}
+		case <-timer.C:
+			if sent < cap(results) {
+				launch()
+				sent++
+				pending++
+				timer.Reset(c.Hedge.Delay)
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package soap
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+// JSONOptions selects the conventions used to write XML as JSON, for
+// feeding SOAP messages to tools that read JSON. A nil *JSONOptions
+// selects the defaults.
+//
+// An element marked nil with xsi:nil is written as null, and one with
+// neither attributes nor children as the string of its text. Others
+// are written as objects, with a member for each attribute, one for
+// each name of their children, in order of first appearance, and one
+// for any text other than white space. Children sharing a name are
+// gathered in an array. Namespace declarations, other attributes of
+// XML Schema instances, and the hrefs left by flattening are dropped.
+type JSONOptions struct {
+	// AttrPrefix precedes the names of attributes in the keys of
+	// their members. If empty, "@" is used.
+	AttrPrefix string
+
+	// TextKey is the key of the text of elements written as
+	// objects. If empty, "#text" is used.
+	TextKey string
+
+	// NoAttributes drops attributes, so that elements without
+	// children are always written as strings.
+	NoAttributes bool
+
+	// Prefixes maps namespaces to the prefixes written before
+	// the local names of elements and attributes in them, as in
+	// "wsa:Action". Names are otherwise written without their
+	// namespace, unless ExpandNames is set.
+	Prefixes map[string]string
+
+	// ExpandNames writes names in namespaces without a prefix in
+	// Prefixes as the namespace in braces followed by the local
+	// name, as in "{urn:example}Item".
+	ExpandNames bool
+
+	// Arrays lists the local names of elements that are always
+	// written in arrays, even when they appear once, so that
+	// readers see the same structure whatever their number.
+	Arrays []string
+
+	// AllArrays writes every child element in an array.
+	AllArrays bool
+}
+
+// ToJSON returns the JSON form of the root element of an XML
+// document, such as a SOAP message, as an object with a single
+// member keyed by its name. The document is flattened with
+// DefaultFlattener first, so that values referenced by SOAP encoding
+// are written where they are referenced.
+func ToJSON(data []byte, opts *JSONOptions) ([]byte, error) {
+	flat, err := Flatten(data)
+	if err != nil {
+		return nil, err
+	}
+	d := xml.NewDecoder(bytes.NewReader(flat))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nil, errors.New("soap: no element to convert to JSON")
+		} else if err != nil {
+			return nil, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			n, err := readJSONNode(d, start)
+			if err != nil {
+				return nil, err
+			}
+			buf := append([]byte{'{'}, appendJSONString(nil, opts.key(start.Name))...)
+			buf = append(buf, ':')
+			buf = n.appendJSON(buf, opts)
+			return append(buf, '}'), nil
+		}
+	}
+}
+
+// ElementToJSON reads the element started by start from d, with its
+// descendants, and returns its JSON form, as for a subtree of a
+// document being decoded, such as an entry of a SOAP Body. It may be
+// called from the UnmarshalXML method of a type.
+func ElementToJSON(d *xml.Decoder, start xml.StartElement, opts *JSONOptions) ([]byte, error) {
+	n, err := readJSONNode(d, start)
+	if err != nil {
+		return nil, err
+	}
+	return n.appendJSON(nil, opts), nil
+}
+
+func (o *JSONOptions) attrPrefix() string {
+	if o == nil || o.AttrPrefix == "" {
+		return "@"
+	}
+	return o.AttrPrefix
+}
+
+func (o *JSONOptions) textKey() string {
+	if o == nil || o.TextKey == "" {
+		return "#text"
+	}
+	return o.TextKey
+}
+
+// key returns the key of the member for an element or attribute.
+func (o *JSONOptions) key(name xml.Name) string {
+	if o == nil || name.Space == "" {
+		return name.Local
+	}
+	if p, ok := o.Prefixes[name.Space]; ok {
+		if p == "" {
+			return name.Local
+		}
+		return p + ":" + name.Local
+	}
+	if o.ExpandNames {
+		return "{" + name.Space + "}" + name.Local
+	}
+	return name.Local
+}
+
+// array reports whether the elements named local are written in an
+// array even when there is only one.
+func (o *JSONOptions) array(local string) bool {
+	if o == nil {
+		return false
+	}
+	if o.AllArrays {
+		return true
+	}
+	for _, name := range o.Arrays {
+		if name == local {
+			return true
+		}
+	}
+	return false
+}
+
+// A jsonNode is an element read to be written as JSON.
+type jsonNode struct {
+	name     xml.Name
+	attr     []xml.Attr
+	text     strings.Builder
+	children []*jsonNode
+	isNil    bool // marked with xsi:nil
+}
+
+// readJSONNode reads the element started by start from d, with its
+// descendants, dropping the attributes that only serve the XML form.
+func readJSONNode(d *xml.Decoder, start xml.StartElement) (*jsonNode, error) {
+	n := &jsonNode{name: start.Name}
+	for _, a := range start.Attr {
+		switch {
+		case isNamespaceDecl(a):
+		case a.Name.Space == NsXSI:
+			n.isNil = n.isNil || a.Name.Local == "nil" && (a.Value == "true" || a.Value == "1")
+		case a.Name.Space == "" && a.Name.Local == "href" && strings.HasPrefix(a.Value, "#"):
+		default:
+			n.attr = append(n.attr, a)
+		}
+	}
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			child, err := readJSONNode(d, tok)
+			if err != nil {
+				return nil, err
+			}
+			n.children = append(n.children, child)
+		case xml.CharData:
+			n.text.Write(tok)
+		case xml.EndElement:
+			return n, nil
+		}
+	}
+}
+
+// appendJSON appends the JSON form of the element to buf, as
+// described for JSONOptions.
+func (n *jsonNode) appendJSON(buf []byte, o *JSONOptions) []byte {
+	if n.isNil {
+		return append(buf, "null"...)
+	}
+	attrs := n.attr
+	if o != nil && o.NoAttributes {
+		attrs = nil
+	}
+	if len(attrs) == 0 && len(n.children) == 0 {
+		return appendJSONString(buf, n.text.String())
+	}
+	buf = append(buf, '{')
+	first := true
+	key := func(k string) {
+		if !first {
+			buf = append(buf, ',')
+		}
+		first = false
+		buf = appendJSONString(buf, k)
+		buf = append(buf, ':')
+	}
+	for _, a := range attrs {
+		key(o.attrPrefix() + o.key(a.Name))
+		buf = appendJSONString(buf, a.Value)
+	}
+	done := make(map[string]bool)
+	for _, c := range n.children {
+		name := o.key(c.name)
+		if done[name] {
+			continue
+		}
+		done[name] = true
+		var group []*jsonNode
+		for _, s := range n.children {
+			if o.key(s.name) == name {
+				group = append(group, s)
+			}
+		}
+		key(name)
+		if len(group) == 1 && !o.array(c.name.Local) {
+			buf = c.appendJSON(buf, o)
+			continue
+		}
+		buf = append(buf, '[')
+		for i, s := range group {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = s.appendJSON(buf, o)
+		}
+		buf = append(buf, ']')
+	}
+	if text := strings.TrimSpace(n.text.String()); text != "" {
+		key(o.textKey())
+		buf = appendJSONString(buf, text)
+	}
+	return append(buf, '}')
+}
+
+func appendJSONString(buf []byte, s string) []byte {
+	data, _ := json.Marshal(s)
+	return append(buf, data...)
+}
@@ -0,0 +1,58 @@
+package soap
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+)
+
+func TestToJSON(t *testing.T) {
+	msg := []byte(`<s:Envelope xmlns:s="` + NsSoapEnv + `" xmlns:wsa="` + NsWSA + `">
+<s:Header><wsa:Action>urn:list</wsa:Action></s:Header>
+<s:Body><r:ListResponse xmlns:r="urn:reports" count="1">
+  <r:item href="#id0"/>
+</r:ListResponse>
+<multiRef id="id0"><name>a &amp; b</name><size unit="kB">4</size></multiRef>
+</s:Body></s:Envelope>`)
+	tests := []struct {
+		opts *JSONOptions
+		want string
+	}{
+		{nil, `{"Envelope":{"Header":{"Action":"urn:list"},"Body":{"ListResponse":{"@count":"1",` +
+			`"item":{"name":"a \u0026 b","size":{"@unit":"kB","#text":"4"}}}}}}`},
+		{&JSONOptions{AttrPrefix: "-", TextKey: "value", Arrays: []string{"item"}},
+			`{"Envelope":{"Header":{"Action":"urn:list"},"Body":{"ListResponse":{"-count":"1",` +
+				`"item":[{"name":"a \u0026 b","size":{"-unit":"kB","value":"4"}}]}}}}`},
+		{&JSONOptions{NoAttributes: true, Prefixes: map[string]string{NsSoapEnv: "soap", NsWSA: "wsa"}, ExpandNames: true},
+			`{"soap:Envelope":{"soap:Header":{"wsa:Action":"urn:list"},"soap:Body":{"{urn:reports}ListResponse":{` +
+				`"{urn:reports}item":{"name":"a \u0026 b","size":"4"}}}}}`},
+	}
+	for _, tt := range tests {
+		got, err := ToJSON(msg, tt.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("options %+v:\ngot  %s\nwant %s", tt.opts, got, tt.want)
+		}
+		if !json.Valid(got) {
+			t.Errorf("invalid JSON %s", got)
+		}
+	}
+}
+
+func TestElementToJSON(t *testing.T) {
+	d := xml.NewDecoder(bytes.NewReader([]byte(`<list><v>1</v><v>2</v><v xsi:nil="true" xmlns:xsi="` + NsXSI + `"/></list>`)))
+	tok, err := d.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ElementToJSON(d, tok.(xml.StartElement), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"v":["1","2",null]}`; string(got) != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,65 @@
+package soap
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// A Limiter delays calls to respect a rate limit. The Limiter type
+// from golang.org/x/time/rate satisfies this interface.
+type Limiter interface {
+	// Wait blocks until a call may be made, or ctx is done.
+	Wait(ctx context.Context) error
+}
+
+// A RateLimiter is a Limiter that allows calls at a fixed rate, with
+// bursts of up to a fixed size. It is safe for concurrent use.
+type RateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	burst    int
+	tat      time.Time // theoretical arrival time of the next call
+}
+
+// NewRateLimiter returns a RateLimiter allowing perSecond calls
+// per second on average, and up to burst calls at once. A burst
+// below 1 is taken as 1. NewRateLimiter panics if perSecond is not
+// positive, as there is no time by which a call could be made.
+func NewRateLimiter(perSecond float64, burst int) *RateLimiter {
+	if !(perSecond > 0) {
+		panic("soap: non-positive rate for NewRateLimiter")
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		interval: time.Duration(float64(time.Second) / perSecond),
+		burst:    burst,
+	}
+}
+
+// Wait blocks until a call may be made under the rate limit. If ctx
+// is done first, Wait returns its error.
+func (r *RateLimiter) Wait(ctx context.Context) error {
+	r.mu.Lock()
+	now := time.Now()
+	if r.tat.Before(now) {
+		r.tat = now
+	}
+	delay := r.tat.Sub(now) - time.Duration(r.burst-1)*r.interval
+	r.tat = r.tat.Add(r.interval)
+	r.mu.Unlock()
+
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
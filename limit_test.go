@@ -0,0 +1,41 @@
+package soap
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	r := NewRateLimiter(100, 5)
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		if err := r.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 5 calls are allowed at once, the other 5 are spaced 10ms apart.
+	if d := time.Since(start); d < 40*time.Millisecond {
+		t.Errorf("10 calls took %v, want at least 40ms", d)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := r.Wait(ctx); err != context.Canceled {
+		t.Errorf("got %v from canceled Wait", err)
+	}
+}
+
+func TestRateLimiterInvalidRate(t *testing.T) {
+	for _, rate := range []float64{0, -1, math.NaN()} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("NewRateLimiter(%v, 1) did not panic", rate)
+				}
+			}()
+			NewRateLimiter(rate, 1)
+		}()
+	}
+}
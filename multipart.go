@@ -0,0 +1,55 @@
+package soap
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+// rootPart returns a reader for the SOAP message of a body with the
+// given headers. If the body is multipart/related, as some gateways
+// send even without attachments, the message is its root part: the
+// part whose Content-ID is the start parameter, or the first part if
+// there is none. Other parts are ignored. If strict is set, the root
+// part must have a SOAP media type.
+func rootPart(h http.Header, body io.Reader, strict bool) (io.Reader, error) {
+	mt, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil || mt != "multipart/related" {
+		return body, nil
+	}
+	if params["boundary"] == "" {
+		return nil, fmt.Errorf("soap: multipart response has no boundary")
+	}
+	start := strings.Trim(params["start"], "<>")
+	mr := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, fmt.Errorf("soap: no root part %q in multipart response", start)
+		} else if err != nil {
+			return nil, err
+		}
+		if start != "" && strings.Trim(part.Header.Get("Content-Id"), "<>") != start {
+			continue
+		}
+		if strict {
+			if err := checkPartMediaType(part.Header.Get("Content-Type")); err != nil {
+				return nil, err
+			}
+		}
+		return part, nil
+	}
+}
+
+// checkPartMediaType checks the media type of the root part of a
+// multipart message, which may also be application/xop+xml for MTOM
+// messages.
+func checkPartMediaType(ct string) error {
+	if mt, _, err := mime.ParseMediaType(ct); err == nil && mt == "application/xop+xml" {
+		return nil
+	}
+	return checkMediaType(http.Header{"Content-Type": {ct}})
+}
@@ -0,0 +1,126 @@
+package soap
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// An SPNEGOProvider produces SPNEGO tokens for the HTTP Negotiate
+// scheme. This package does not implement Kerberos itself; providers
+// typically wrap a GSSAPI library, SSPI on Windows, or a pure-Go
+// Kerberos client that can obtain tickets from a credential cache
+// or keytab, so that no password prompt is needed.
+type SPNEGOProvider interface {
+	// Token returns the next token of a security context with
+	// the service principal spn, such as "HTTP/host.example.com".
+	// input is nil for the first token of a context, and is
+	// otherwise the token sent by the service in its challenge.
+	Token(ctx context.Context, spn string, input []byte) ([]byte, error)
+}
+
+// NegotiateTransport is an http.RoundTripper that authenticates
+// requests using the Negotiate scheme of RFC 4559, as used by
+// services protected by Active Directory.
+type NegotiateTransport struct {
+	Provider SPNEGOProvider
+
+	// SPN is the service principal name of the service. If
+	// empty, "HTTP/" followed by the host name of each request's
+	// URL is used.
+	SPN string
+
+	// Preemptive sends a token with the first request, rather
+	// than waiting for the service to challenge it. This avoids
+	// sending each request body twice.
+	Preemptive bool
+
+	// Transport is used to send requests. If nil,
+	// http.DefaultTransport is used.
+	Transport http.RoundTripper
+}
+
+// The maximum number of legs in a Negotiate exchange.
+const maxNegotiateLegs = 4
+
+func (t *NegotiateTransport) transport() http.RoundTripper {
+	if t.Transport != nil {
+		return t.Transport
+	}
+	return http.DefaultTransport
+}
+
+func (t *NegotiateTransport) spn(req *http.Request) string {
+	if t.SPN != "" {
+		return t.SPN
+	}
+	host := req.URL.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return "HTTP/" + host
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *NegotiateTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var (
+		rsp *http.Response
+		err error
+		in  []byte
+	)
+	spn := t.spn(req)
+	if t.Preemptive {
+		if rsp, err = t.leg(req, spn, nil); err != nil {
+			return nil, err
+		}
+	} else if rsp, err = t.transport().RoundTrip(req); err != nil {
+		return nil, err
+	}
+
+	for legs := 0; rsp.StatusCode == http.StatusUnauthorized && legs < maxNegotiateLegs; legs++ {
+		hdr := rsp.Header.Values("WWW-Authenticate")
+		if !offersScheme(hdr, "Negotiate") || (req.Body != nil && req.GetBody == nil) {
+			return rsp, nil
+		}
+		if in = challengeToken(hdr, "Negotiate"); in == nil && legs > 0 {
+			// the service rejected our credentials
+			return rsp, nil
+		}
+		drain(rsp)
+		if rsp, err = t.leg(req, spn, in); err != nil {
+			return nil, err
+		}
+	}
+	return rsp, nil
+}
+
+// leg sends a copy of req with the next token of the context.
+func (t *NegotiateTransport) leg(req *http.Request, spn string, input []byte) (*http.Response, error) {
+	token, err := t.Provider.Token(req.Context(), spn, input)
+	if err != nil {
+		return nil, err
+	}
+	r := req.Clone(req.Context())
+	if req.GetBody != nil {
+		if r.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	r.Header.Set("Authorization", "Negotiate "+base64.StdEncoding.EncodeToString(token))
+	return t.transport().RoundTrip(r)
+}
+
+// offersScheme reports whether WWW-Authenticate headers contain
+// a challenge for scheme.
+func offersScheme(headers []string, scheme string) bool {
+	for _, h := range headers {
+		for _, ch := range parseChallenges(h) {
+			if strings.EqualFold(ch.scheme, scheme) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package soap
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type fakeSPNEGO struct{ spns []string }
+
+func (p *fakeSPNEGO) Token(ctx context.Context, spn string, input []byte) ([]byte, error) {
+	p.spns = append(p.spns, spn)
+	if input == nil {
+		return []byte("init"), nil
+	}
+	return append([]byte("reply:"), input...), nil
+}
+
+func TestNegotiateTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := strings.TrimPrefix(r.Header.Get("Authorization"), "Negotiate ")
+		token, _ := base64.StdEncoding.DecodeString(auth)
+		switch {
+		case bytes.Equal(token, []byte("init")):
+			w.Header().Set("WWW-Authenticate", "Negotiate "+base64.StdEncoding.EncodeToString([]byte("more")))
+			w.WriteHeader(http.StatusUnauthorized)
+		case bytes.Equal(token, []byte("reply:more")):
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.Header().Set("WWW-Authenticate", "Negotiate")
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	p := new(fakeSPNEGO)
+	c := &http.Client{Transport: &NegotiateTransport{Provider: p}}
+	rsp, err := c.Post(srv.URL, "text/xml", strings.NewReader("<x/>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		t.Errorf("got %s", rsp.Status)
+	}
+	if len(p.spns) != 2 || p.spns[0] != "HTTP/127.0.0.1" {
+		t.Errorf("got SPNs %v", p.spns)
+	}
+}
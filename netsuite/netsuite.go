@@ -0,0 +1,252 @@
+// Package netsuite provides helpers for NetSuite SuiteTalk: the
+// tokenPassport header of token-based authentication, the
+// searchPreferences header, and iteration over the pages of search
+// results.
+package netsuite
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+
+	"aqwari.net/exp/soap"
+)
+
+// A Client makes SuiteTalk requests.
+type Client struct {
+	// SOAP sends requests. Its URL is the account's SuiteTalk
+	// endpoint for the version, such as
+	// "https://1234567.suitetalk.api.netsuite.com/services/NetSuitePort_2023_2".
+	SOAP *soap.Client
+
+	// Version is the version of the WSDL, such as "2023_2",
+	// which determines the namespaces of messages.
+	Version string
+
+	// Passport authenticates every request.
+	Passport Passport
+
+	// SearchPreferences, if non-nil, is sent with every request.
+	SearchPreferences *SearchPreferences
+}
+
+// NewClient returns a Client for the endpoint at url, speaking the
+// given version of the WSDL.
+func NewClient(url, version string, p Passport) *Client {
+	return &Client{SOAP: &soap.Client{URL: url}, Version: version, Passport: p}
+}
+
+// ns returns the namespace of a platform schema, such as "messages"
+// or "core".
+func (c *Client) ns(schema string) string {
+	return "urn:" + schema + "_" + c.Version + ".platform.webservices.netsuite.com"
+}
+
+// SearchPreferences control the results of searches.
+type SearchPreferences struct {
+	// BodyFieldsOnly omits sublists from the records returned.
+	BodyFieldsOnly bool
+
+	// ReturnSearchColumns returns the columns of advanced
+	// searches rather than records.
+	ReturnSearchColumns bool
+
+	// PageSize is the number of records in each page of results,
+	// between 5 and 1000. If zero, the server's default is used.
+	PageSize int
+}
+
+type searchPreferences struct {
+	XMLName             xml.Name
+	BodyFieldsOnly      bool `xml:"bodyFieldsOnly"`
+	ReturnSearchColumns bool `xml:"returnSearchColumns"`
+	PageSize            int  `xml:"pageSize,omitempty"`
+}
+
+// Call invokes an operation, such as "get" or "upsert". req is the
+// operation's request element, in the messages namespace of the
+// version. The tokenPassport header is signed anew for every call.
+func (c *Client) Call(ctx context.Context, operation string, req, resp interface{}) error {
+	passport, err := c.Passport.header(c.ns("messages"), c.ns("core"), time.Now())
+	if err != nil {
+		return err
+	}
+	header := []interface{}{passport}
+	if p := c.SearchPreferences; p != nil {
+		header = append(header, searchPreferences{
+			XMLName:             xml.Name{Space: c.ns("messages"), Local: "searchPreferences"},
+			BodyFieldsOnly:      p.BodyFieldsOnly,
+			ReturnSearchColumns: p.ReturnSearchColumns,
+			PageSize:            p.PageSize,
+		})
+	}
+	return c.SOAP.Call(ctx, operation, req, resp, soap.WithSOAPHeader(header...))
+}
+
+// A Status is the status of an operation, reported in its response.
+// It is meant to be embedded in the types decoding responses of
+// operations other than search.
+type Status struct {
+	IsSuccess bool `xml:"isSuccess,attr"`
+	Details   []struct {
+		Type    string `xml:"type,attr"`
+		Code    string `xml:"code"`
+		Message string `xml:"message"`
+	} `xml:"statusDetail"`
+}
+
+// Err returns the first error reported by the status, or nil if the
+// operation succeeded.
+func (s *Status) Err() error {
+	if s.IsSuccess {
+		return nil
+	}
+	for _, d := range s.Details {
+		if d.Type == "ERROR" || d.Type == "" {
+			return &StatusError{Code: d.Code, Message: d.Message}
+		}
+	}
+	return &StatusError{Code: "UNKNOWN_ERROR"}
+}
+
+// A StatusError is an error reported in the status of an operation.
+type StatusError struct {
+	// Code identifies the error, such as
+	// "INSUFFICIENT_PERMISSION".
+	Code string
+
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("netsuite: %s: %s", e.Code, e.Message)
+}
+
+// A Record is a record returned by a search.
+type Record struct {
+	// Type is the xsi:type of the record, without its prefix,
+	// such as "Customer".
+	Type string
+
+	InternalID, ExternalID string
+
+	inner []byte
+}
+
+// UnmarshalXML decodes a record of any type.
+func (r *Record) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var v struct {
+		Inner []byte `xml:",innerxml"`
+	}
+	if err := d.DecodeElement(&v, &start); err != nil {
+		return err
+	}
+	*r = Record{inner: v.Inner}
+	for _, a := range start.Attr {
+		switch {
+		case a.Name.Local == "internalId":
+			r.InternalID = a.Value
+		case a.Name.Local == "externalId":
+			r.ExternalID = a.Value
+		case a.Name.Local == "type" && (a.Name.Space == soap.NsXSI || a.Name.Space == "xsi"):
+			r.Type = a.Value[strings.Index(a.Value, ":")+1:]
+		}
+	}
+	return nil
+}
+
+// Decode decodes the fields of the record into v, which should be a
+// pointer to a struct whose field tags have no namespace.
+func (r *Record) Decode(v interface{}) error {
+	data := append(append([]byte("<record>"), r.inner...), "</record>"...)
+	return xml.Unmarshal(data, v)
+}
+
+type searchRequest struct {
+	XMLName xml.Name
+	Record  interface{}
+}
+
+type searchMoreWithID struct {
+	XMLName   xml.Name
+	SearchID  string `xml:"searchId"`
+	PageIndex int    `xml:"pageIndex"`
+}
+
+type searchResponse struct {
+	Result struct {
+		Status       Status   `xml:"status"`
+		TotalRecords int      `xml:"totalRecords"`
+		TotalPages   int      `xml:"totalPages"`
+		PageIndex    int      `xml:"pageIndex"`
+		SearchID     string   `xml:"searchId"`
+		Records      []Record `xml:"recordList>record"`
+	} `xml:"searchResult"`
+}
+
+// A SearchPager iterates over the pages of the results of a search.
+// The first page is requested with search, and the following pages
+// with searchMoreWithId.
+type SearchPager struct {
+	c       *Client
+	record  interface{}
+	started bool
+	id      string
+	page    int
+	pages   int
+	total   int
+	records []Record
+	err     error
+}
+
+// Search returns a SearchPager over the results of a search. record
+// is the searchRecord element of the search request, with an xsi:type
+// attribute naming the kind of search, such as CustomerSearchBasic.
+// No request is made until its Next method is called.
+func (c *Client) Search(record interface{}) *SearchPager {
+	return &SearchPager{c: c, record: record}
+}
+
+// Next requests the next page of results, reporting whether there is
+// one. It returns false after the last page, or if an error occurs.
+func (p *SearchPager) Next(ctx context.Context) bool {
+	if p.err != nil || (p.started && p.page >= p.pages) {
+		return false
+	}
+	var resp searchResponse
+	var err error
+	if !p.started {
+		req := searchRequest{XMLName: xml.Name{Space: p.c.ns("messages"), Local: "search"}, Record: p.record}
+		err = p.c.Call(ctx, "search", req, &resp)
+	} else {
+		req := searchMoreWithID{
+			XMLName:   xml.Name{Space: p.c.ns("messages"), Local: "searchMoreWithId"},
+			SearchID:  p.id,
+			PageIndex: p.page + 1,
+		}
+		err = p.c.Call(ctx, "searchMoreWithId", req, &resp)
+	}
+	if err == nil {
+		err = resp.Result.Status.Err()
+	}
+	if err != nil {
+		p.err = err
+		return false
+	}
+	r := resp.Result
+	p.id, p.page, p.pages, p.total = r.SearchID, r.PageIndex, r.TotalPages, r.TotalRecords
+	p.records = r.Records
+	p.started = true
+	return true
+}
+
+// Records returns the records of the current page.
+func (p *SearchPager) Records() []Record { return p.records }
+
+// TotalRecords returns the number of records found by the search.
+func (p *SearchPager) TotalRecords() int { return p.total }
+
+// Err returns the error that ended the iteration, if any.
+func (p *SearchPager) Err() error { return p.err }
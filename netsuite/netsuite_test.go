@@ -0,0 +1,143 @@
+package netsuite
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const (
+	nsMessages = "urn:messages_2023_2.platform.webservices.netsuite.com"
+	nsCore     = "urn:core_2023_2.platform.webservices.netsuite.com"
+)
+
+func TestSearch(t *testing.T) {
+	p := Passport{
+		Account:        "1234567",
+		ConsumerKey:    "ck",
+		ConsumerSecret: "cs",
+		TokenID:        "tk",
+		TokenSecret:    "ts",
+	}
+	nonces := make(map[string]bool)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var msg struct {
+			Header struct {
+				Passport struct {
+					Account   string `xml:"urn:core_2023_2.platform.webservices.netsuite.com account"`
+					Nonce     string `xml:"urn:core_2023_2.platform.webservices.netsuite.com nonce"`
+					Timestamp string `xml:"urn:core_2023_2.platform.webservices.netsuite.com timestamp"`
+					Signature struct {
+						Algorithm string `xml:"algorithm,attr"`
+						Value     string `xml:",chardata"`
+					} `xml:"urn:core_2023_2.platform.webservices.netsuite.com signature"`
+				} `xml:"urn:messages_2023_2.platform.webservices.netsuite.com tokenPassport"`
+				PageSize int `xml:"urn:messages_2023_2.platform.webservices.netsuite.com searchPreferences>pageSize"`
+			}
+			Body struct {
+				Search *struct{} `xml:"urn:messages_2023_2.platform.webservices.netsuite.com search"`
+				More   *struct {
+					SearchID  string `xml:"searchId"`
+					PageIndex int    `xml:"pageIndex"`
+				} `xml:"urn:messages_2023_2.platform.webservices.netsuite.com searchMoreWithId"`
+			}
+		}
+		if err := xml.Unmarshal(data, &msg); err != nil {
+			t.Error(err)
+		}
+		tp := msg.Header.Passport
+		if tp.Account != "1234567" || tp.Signature.Algorithm != "HMAC-SHA256" ||
+			tp.Signature.Value != p.signature(tp.Nonce, tp.Timestamp) {
+			t.Errorf("bad tokenPassport: %s", data)
+		}
+		if ts, _ := strconv.ParseInt(tp.Timestamp, 10, 64); time.Since(time.Unix(ts, 0)).Abs() > time.Minute {
+			t.Errorf("timestamp %s", tp.Timestamp)
+		}
+		if nonces[tp.Nonce] {
+			t.Errorf("nonce %s reused", tp.Nonce)
+		}
+		nonces[tp.Nonce] = true
+		if msg.Header.PageSize != 5 {
+			t.Errorf("searchPreferences not sent: %s", data)
+		}
+
+		page := 1
+		switch {
+		case msg.Body.More != nil:
+			if msg.Body.More.SearchID != "WEBSERVICES_1" {
+				t.Errorf("search ID %q", msg.Body.More.SearchID)
+			}
+			page = msg.Body.More.PageIndex
+		case msg.Body.Search == nil:
+			t.Errorf("unexpected request %s", data)
+		}
+		w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+		fmt.Fprintf(w, `<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/"
+  xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"><soapenv:Body>
+<searchResponse xmlns="%s"><platformCore:searchResult xmlns:platformCore="%s">
+<platformCore:status isSuccess="true"/>
+<platformCore:totalRecords>7</platformCore:totalRecords><platformCore:pageSize>5</platformCore:pageSize>
+<platformCore:totalPages>2</platformCore:totalPages><platformCore:pageIndex>%d</platformCore:pageIndex>
+<platformCore:searchId>WEBSERVICES_1</platformCore:searchId>
+<platformCore:recordList><platformCore:record internalId="%d" xsi:type="listRel:Customer" xmlns:listRel="urn:relationships_2023_2.lists.webservices.netsuite.com">
+<listRel:companyName>Company %d</listRel:companyName></platformCore:record></platformCore:recordList>
+</platformCore:searchResult></searchResponse></soapenv:Body></soapenv:Envelope>`,
+			nsMessages, nsCore, page, page, page)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "2023_2", p)
+	c.SearchPreferences = &SearchPreferences{BodyFieldsOnly: true, PageSize: 5}
+
+	search := struct {
+		XMLName xml.Name   `xml:"urn:messages_2023_2.platform.webservices.netsuite.com searchRecord"`
+		Attr    []xml.Attr `xml:",any,attr"`
+	}{Attr: []xml.Attr{
+		{Name: xml.Name{Local: "xmlns:xsi"}, Value: "http://www.w3.org/2001/XMLSchema-instance"},
+		{Name: xml.Name{Local: "xmlns:listRel"}, Value: "urn:relationships_2023_2.lists.webservices.netsuite.com"},
+		{Name: xml.Name{Local: "xsi:type"}, Value: "listRel:CustomerSearchBasic"},
+	}}
+	ctx := context.Background()
+	s := c.Search(search)
+	var names []string
+	for s.Next(ctx) {
+		for _, rec := range s.Records() {
+			var cust struct {
+				CompanyName string `xml:"companyName"`
+			}
+			if err := rec.Decode(&cust); err != nil {
+				t.Fatal(err)
+			}
+			if rec.Type != "Customer" {
+				t.Errorf("record type %q", rec.Type)
+			}
+			names = append(names, rec.InternalID+":"+cust.CompanyName)
+		}
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(names) != "[1:Company 1 2:Company 2]" || s.TotalRecords() != 7 {
+		t.Errorf("got %v, %d records", names, s.TotalRecords())
+	}
+}
+
+func TestStatus(t *testing.T) {
+	var s Status
+	data := `<status isSuccess="false"><statusDetail type="WARN"><code>W</code></statusDetail>
+<statusDetail type="ERROR"><code>INSUFFICIENT_PERMISSION</code><message>Permission Violation</message></statusDetail></status>`
+	if err := xml.Unmarshal([]byte(data), &s); err != nil {
+		t.Fatal(err)
+	}
+	err, ok := s.Err().(*StatusError)
+	if !ok || err.Code != "INSUFFICIENT_PERMISSION" {
+		t.Errorf("got %v", s.Err())
+	}
+}
@@ -0,0 +1,72 @@
+package netsuite
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"strconv"
+	"time"
+)
+
+// A Passport holds the credentials of token-based authentication
+// (TBA): those of an integration record and of an access token
+// issued to a user for it.
+type Passport struct {
+	// Account is the NetSuite account ID, such as "1234567" or
+	// "1234567_SB1" for a sandbox.
+	Account string
+
+	ConsumerKey, ConsumerSecret string
+	TokenID, TokenSecret        string
+}
+
+// signature returns the signature of a tokenPassport,
+// Base64(HMAC-SHA256(consumerSecret&tokenSecret,
+// account&consumerKey&tokenID&nonce&timestamp)).
+func (p *Passport) signature(nonce, timestamp string) string {
+	mac := hmac.New(sha256.New, []byte(p.ConsumerSecret+"&"+p.TokenSecret))
+	mac.Write([]byte(p.Account + "&" + p.ConsumerKey + "&" + p.TokenID + "&" + nonce + "&" + timestamp))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+type text struct {
+	XMLName xml.Name
+	Attr    []xml.Attr `xml:",any,attr"`
+	Value   string     `xml:",chardata"`
+}
+
+type tokenPassport struct {
+	XMLName xml.Name
+	Fields  []text
+}
+
+// header returns a tokenPassport header, in the messages namespace
+// with its fields in the core namespace, signed with a new nonce and
+// the time now. The server rejects a nonce that it has seen before.
+func (p *Passport) header(messagesNS, coreNS string, now time.Time) (tokenPassport, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return tokenPassport{}, err
+	}
+	nonce := hex.EncodeToString(b)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	field := func(name, value string) text {
+		return text{XMLName: xml.Name{Space: coreNS, Local: name}, Value: value}
+	}
+	sig := field("signature", p.signature(nonce, timestamp))
+	sig.Attr = []xml.Attr{{Name: xml.Name{Local: "algorithm"}, Value: "HMAC-SHA256"}}
+	return tokenPassport{
+		XMLName: xml.Name{Space: messagesNS, Local: "tokenPassport"},
+		Fields: []text{
+			field("account", p.Account),
+			field("consumerKey", p.ConsumerKey),
+			field("token", p.TokenID),
+			field("nonce", nonce),
+			field("timestamp", timestamp),
+			sig,
+		},
+	}, nil
+}
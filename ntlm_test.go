@@ -0,0 +1,50 @@
+package soap
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestMD4(t *testing.T) {
+	// RFC 1320, appendix A.5
+	tests := map[string]string{
+		"":    "31d6cfe0d16ae931b73c59d7e0c089c0",
+		"abc": "a448017aaf21d8525fc10ae87aa6729d",
+		"12345678901234567890123456789012345678901234567890123456789012345678901234567890": "e33b4ddc9c38f2199c3e7b164fcc0536",
+	}
+	for in, want := range tests {
+		if sum := md4Sum([]byte(in)); hex.EncodeToString(sum[:]) != want {
+			t.Errorf("md4(%q) = %x, want %s", in, sum, want)
+		}
+	}
+}
+
+// Test vectors from MS-NLMP, section 4.2.4.
+func TestNTLMv2(t *testing.T) {
+	key := ntowfv2("User", "Domain", "Password")
+	if want := "0c868a403bfd7a93a3001ef22ef02e3f"; hex.EncodeToString(key) != want {
+		t.Errorf("NTOWFv2 = %x, want %s", key, want)
+	}
+	chal := &ntlmChallenge{flags: ntlmFlags}
+	copy(chal.challenge[:], []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef})
+	chal.targetInfo = append(chal.targetInfo, 2, 0, 12, 0)
+	chal.targetInfo = append(chal.targetInfo, utf16le("Domain")...)
+	chal.targetInfo = append(chal.targetInfo, 1, 0, 12, 0)
+	chal.targetInfo = append(chal.targetInfo, utf16le("Server")...)
+	chal.targetInfo = append(chal.targetInfo, 0, 0, 0, 0)
+
+	clientChallenge := [8]byte{0xaa, 0xaa, 0xaa, 0xaa, 0xaa, 0xaa, 0xaa, 0xaa}
+	lm, nt := chal.responses(key, clientChallenge, 0)
+	if want := "86c35097ac9cec102554764a57cccc19aaaaaaaaaaaaaaaa"; hex.EncodeToString(lm) != want {
+		t.Errorf("LMv2 = %x, want %s", lm, want)
+	}
+	if want := "68cd0ab851e51c96aabc927bebef6a1c"; hex.EncodeToString(nt[:16]) != want {
+		t.Errorf("NTProofStr = %x, want %s", nt[:16], want)
+	}
+
+	msg := chal.authenticate("User", "Domain", "Password", clientChallenge, 0)
+	if !bytes.Contains(msg, utf16le("User")) || !bytes.Contains(msg, nt) {
+		t.Errorf("AUTHENTICATE_MESSAGE lacks user name or NT response")
+	}
+}
@@ -0,0 +1,209 @@
+// Package onvif provides helpers for ONVIF network video devices:
+// discovering them, authenticating requests with WS-Security
+// UsernameTokens, and locating the services they implement.
+package onvif
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"sync"
+	"time"
+
+	"aqwari.net/exp/soap"
+)
+
+// Namespaces of the ONVIF services.
+const (
+	NsDevice    = "http://www.onvif.org/ver10/device/wsdl"
+	NsMedia     = "http://www.onvif.org/ver10/media/wsdl"
+	NsEvents    = "http://www.onvif.org/ver10/events/wsdl"
+	NsImaging   = "http://www.onvif.org/ver20/imaging/wsdl"
+	NsPTZ       = "http://www.onvif.org/ver20/ptz/wsdl"
+	NsAnalytics = "http://www.onvif.org/ver20/analytics/wsdl"
+	NsSchema    = "http://www.onvif.org/ver10/schema"
+	NsNetwork   = "http://www.onvif.org/ver10/network/wsdl"
+)
+
+// Discover probes the local network for ONVIF devices, using
+// WS-Discovery. The XAddrs of each match are the addresses of the
+// device service. If timeout is zero, the default of soap.Probe
+// is used.
+func Discover(ctx context.Context, timeout time.Duration) ([]soap.ProbeMatch, error) {
+	return soap.Probe(ctx, &soap.ProbeOptions{
+		Types:   []xml.Name{{Space: NsNetwork, Local: "NetworkVideoTransmitter"}},
+		Timeout: timeout,
+	})
+}
+
+// A Device is an ONVIF device. Requests are authenticated with a
+// WS-Security UsernameToken using a password digest. As devices
+// reject tokens whose creation time differs too much from their own
+// clock, the offset between the local and device clocks is measured
+// before the first request and applied to the creation time of each
+// token.
+type Device struct {
+	// Client sends requests to the device. Its URL is the address
+	// of the device service.
+	Client *soap.Client
+
+	// Username and Password authenticate requests. If Username
+	// is empty, requests are not authenticated.
+	Username, Password string
+
+	mu       sync.Mutex
+	synced   bool
+	offset   time.Duration
+	services map[string]string
+}
+
+// NewDevice returns a Device whose device service is at xaddr,
+// as returned by Discover.
+func NewDevice(xaddr, username, password string) *Device {
+	return &Device{
+		Client:   &soap.Client{URL: xaddr, Version: soap.V12},
+		Username: username,
+		Password: password,
+	}
+}
+
+type getSystemDateAndTime struct {
+	XMLName xml.Name `xml:"http://www.onvif.org/ver10/device/wsdl GetSystemDateAndTime"`
+}
+
+type getSystemDateAndTimeResponse struct {
+	UTC struct {
+		Date struct {
+			Year  int `xml:"Year"`
+			Month int `xml:"Month"`
+			Day   int `xml:"Day"`
+		} `xml:"Date"`
+		Time struct {
+			Hour   int `xml:"Hour"`
+			Minute int `xml:"Minute"`
+			Second int `xml:"Second"`
+		} `xml:"Time"`
+	} `xml:"SystemDateAndTime>UTCDateTime"`
+}
+
+// SyncClock measures the offset between the local clock and the
+// device's clock, which is applied to the creation time of the
+// tokens sent to the device. It is called before the first
+// authenticated request, and may be called again to correct for
+// drift.
+func (d *Device) SyncClock(ctx context.Context) error {
+	var resp getSystemDateAndTimeResponse
+	start := time.Now()
+	err := d.Client.Call(ctx, NsDevice+"/GetSystemDateAndTime", getSystemDateAndTime{}, &resp)
+	if err != nil {
+		return err
+	}
+	u := resp.UTC
+	if u.Date.Year == 0 {
+		return errors.New("onvif: device did not report its UTC time")
+	}
+	device := time.Date(u.Date.Year, time.Month(u.Date.Month), u.Date.Day,
+		u.Time.Hour, u.Time.Minute, u.Time.Second, 0, time.UTC)
+	// the device's time was read about halfway through the call
+	local := start.Add(time.Since(start) / 2)
+
+	d.mu.Lock()
+	d.offset = device.Sub(local)
+	d.synced = true
+	d.mu.Unlock()
+	return nil
+}
+
+// ClockOffset returns the difference between the device's clock and
+// the local clock, as last measured by SyncClock.
+func (d *Device) ClockOffset() time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.offset
+}
+
+// Call invokes an operation of one of the device's services,
+// identified by its namespace, such as NsMedia. The request is sent
+// to the address of the service found by ResolveServices, or to the
+// device service if it is not known.
+func (d *Device) Call(ctx context.Context, service, operation string, req, resp interface{}) error {
+	var opts []soap.CallOption
+	if d.Username != "" {
+		d.mu.Lock()
+		synced := d.synced
+		d.mu.Unlock()
+		if !synced {
+			if err := d.SyncClock(ctx); err != nil {
+				return err
+			}
+		}
+		envNS := soap.NsSoapEnv
+		if d.Client.Version == soap.V12 {
+			envNS = soap.NsSoap12Env
+		}
+		token, err := usernameToken(envNS, d.Username, d.Password, time.Now().Add(d.ClockOffset()))
+		if err != nil {
+			return err
+		}
+		opts = append(opts, soap.WithSOAPHeader(token))
+	}
+	if url := d.ServiceURL(service); url != "" {
+		opts = append(opts, soap.WithEndpoint(url))
+	}
+	return d.Client.Call(ctx, service+"/"+operation, req, resp, opts...)
+}
+
+type getCapabilities struct {
+	XMLName  xml.Name `xml:"http://www.onvif.org/ver10/device/wsdl GetCapabilities"`
+	Category string   `xml:"http://www.onvif.org/ver10/device/wsdl Category"`
+}
+
+type getCapabilitiesResponse struct {
+	Capabilities struct {
+		Analytics xaddr `xml:"Analytics"`
+		Device    xaddr `xml:"Device"`
+		Events    xaddr `xml:"Events"`
+		Imaging   xaddr `xml:"Imaging"`
+		Media     xaddr `xml:"Media"`
+		PTZ       xaddr `xml:"PTZ"`
+	} `xml:"Capabilities"`
+}
+
+type xaddr struct {
+	XAddr string `xml:"XAddr"`
+}
+
+// ResolveServices asks the device for the addresses of its services
+// with GetCapabilities, so that later calls are sent to them.
+func (d *Device) ResolveServices(ctx context.Context) error {
+	var resp getCapabilitiesResponse
+	if err := d.Call(ctx, NsDevice, "GetCapabilities", getCapabilities{Category: "All"}, &resp); err != nil {
+		return err
+	}
+	c := resp.Capabilities
+	services := make(map[string]string)
+	for ns, x := range map[string]xaddr{
+		NsAnalytics: c.Analytics,
+		NsDevice:    c.Device,
+		NsEvents:    c.Events,
+		NsImaging:   c.Imaging,
+		NsMedia:     c.Media,
+		NsPTZ:       c.PTZ,
+	} {
+		if x.XAddr != "" {
+			services[ns] = x.XAddr
+		}
+	}
+	d.mu.Lock()
+	d.services = services
+	d.mu.Unlock()
+	return nil
+}
+
+// ServiceURL returns the address of the service with the given
+// namespace, or the empty string if it is not known.
+func (d *Device) ServiceURL(service string) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.services[service]
+}
@@ -0,0 +1,94 @@
+package onvif
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"aqwari.net/exp/soap"
+)
+
+func TestDevice(t *testing.T) {
+	const skew = time.Hour
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now().Add(skew).UTC()
+		data, _ := io.ReadAll(r.Body)
+		var msg struct {
+			Header struct {
+				Security *security
+			}
+		}
+		if err := xml.Unmarshal(data, &msg); err != nil {
+			t.Error(err)
+		}
+		_, params, _ := strings.Cut(r.Header.Get("Content-Type"), "action=")
+		action := strings.Trim(strings.Split(params, ";")[0], `"`)
+
+		if action != NsDevice+"/GetSystemDateAndTime" {
+			s := msg.Header.Security
+			if s == nil {
+				t.Errorf("%s: not authenticated", action)
+				return
+			}
+			nonce, _ := base64.StdEncoding.DecodeString(s.Token.Nonce.Value)
+			if passwordDigestOf(nonce, s.Token.Created, "secret") != s.Token.Password.Value {
+				t.Errorf("%s: wrong password digest", action)
+			}
+			created, err := time.Parse(time.RFC3339, s.Token.Created)
+			if err != nil || created.Sub(now).Abs() > 5*time.Second {
+				t.Errorf("%s: token created %s, device time is %s", action, s.Token.Created, now)
+			}
+		}
+
+		var body string
+		switch action {
+		case NsDevice + "/GetSystemDateAndTime":
+			body = fmt.Sprintf(`<tds:GetSystemDateAndTimeResponse><tds:SystemDateAndTime><tt:UTCDateTime>
+<tt:Time><tt:Hour>%d</tt:Hour><tt:Minute>%d</tt:Minute><tt:Second>%d</tt:Second></tt:Time>
+<tt:Date><tt:Year>%d</tt:Year><tt:Month>%d</tt:Month><tt:Day>%d</tt:Day></tt:Date>
+</tt:UTCDateTime></tds:SystemDateAndTime></tds:GetSystemDateAndTimeResponse>`,
+				now.Hour(), now.Minute(), now.Second(), now.Year(), now.Month(), now.Day())
+		case NsDevice + "/GetCapabilities":
+			body = `<tds:GetCapabilitiesResponse><tds:Capabilities>
+<tt:Device><tt:XAddr>` + srv.URL + `/onvif/device_service</tt:XAddr></tt:Device>
+<tt:Media><tt:XAddr>` + srv.URL + `/onvif/media</tt:XAddr></tt:Media>
+</tds:Capabilities></tds:GetCapabilitiesResponse>`
+		case NsMedia + "/GetProfiles":
+			if r.URL.Path != "/onvif/media" {
+				t.Errorf("GetProfiles sent to %s", r.URL.Path)
+			}
+			body = `<trt:GetProfilesResponse xmlns:trt="` + NsMedia + `"/>`
+		default:
+			t.Errorf("unexpected action %q", action)
+		}
+		w.Header().Set("Content-Type", "application/soap+xml")
+		fmt.Fprintf(w, `<s:Envelope xmlns:s="%s" xmlns:tds="%s" xmlns:tt="%s"><s:Body>%s</s:Body></s:Envelope>`,
+			soap.NsSoap12Env, NsDevice, NsSchema, body)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	d := NewDevice(srv.URL+"/onvif/device_service", "admin", "secret")
+	if err := d.ResolveServices(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if off := d.ClockOffset(); (off - skew).Abs() > 2*time.Second {
+		t.Errorf("clock offset %s, want about %s", off, skew)
+	}
+	if u := d.ServiceURL(NsMedia); u != srv.URL+"/onvif/media" {
+		t.Errorf("media service at %q", u)
+	}
+	if err := d.Call(ctx, NsMedia, "GetProfiles", struct {
+		XMLName xml.Name `xml:"http://www.onvif.org/ver10/media/wsdl GetProfiles"`
+	}{}, nil); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,65 @@
+package onvif
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/xml"
+	"time"
+)
+
+const (
+	nsWSSE = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd"
+	nsWSU  = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd"
+
+	passwordDigest = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-username-token-profile-1.0#PasswordDigest"
+	base64Binary   = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-soap-message-security-1.0#Base64Binary"
+)
+
+type security struct {
+	XMLName        xml.Name `xml:"http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd Security"`
+	MustUnderstand xml.Attr `xml:",any,attr"`
+	Token          struct {
+		Username string `xml:"http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd Username"`
+		Password struct {
+			Type  string `xml:"Type,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd Password"`
+		Nonce struct {
+			EncodingType string `xml:"EncodingType,attr"`
+			Value        string `xml:",chardata"`
+		} `xml:"http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd Nonce"`
+		Created string `xml:"http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd Created"`
+	} `xml:"http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd UsernameToken"`
+}
+
+// passwordDigestOf returns the digest of a UsernameToken password,
+// Base64(SHA-1(nonce + created + password)).
+func passwordDigestOf(nonce []byte, created, password string) string {
+	h := sha1.New()
+	h.Write(nonce)
+	h.Write([]byte(created))
+	h.Write([]byte(password))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// usernameToken returns a WS-Security header holding a UsernameToken
+// with a digest of password, created at the given time, which should
+// be the device's time rather than the local time.
+func usernameToken(envNS, username, password string, created time.Time) (*security, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	ts := created.UTC().Format("2006-01-02T15:04:05.000Z")
+	s := &security{
+		MustUnderstand: xml.Attr{Name: xml.Name{Space: envNS, Local: "mustUnderstand"}, Value: "1"},
+	}
+	s.Token.Username = username
+	s.Token.Password.Type = passwordDigest
+	s.Token.Password.Value = passwordDigestOf(nonce, ts, password)
+	s.Token.Nonce.EncodingType = base64Binary
+	s.Token.Nonce.Value = base64.StdEncoding.EncodeToString(nonce)
+	s.Token.Created = ts
+	return s, nil
+}
@@ -0,0 +1,239 @@
+package soap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// A Profile bundles the settings needed to interoperate with a family
+// of SOAP implementations, so that a known-good combination can be
+// selected for an endpoint rather than discovered one setting at a
+// time. A nil *Profile selects the default behavior of the package.
+type Profile struct {
+	// Name identifies the profile, such as "axis1".
+	Name string
+
+	// Flattener dereferences the document links of responses.
+	// If nil, DefaultFlattener is used.
+	Flattener *Flattener
+
+	// StrictMediaType rejects responses whose Content-Type is
+	// not one used for SOAP messages of either version, such as
+	// the text/html error pages of proxies. Otherwise responses
+	// of any Content-Type are parsed, as some services label
+	// SOAP messages with, for example, text/plain.
+	StrictMediaType bool
+
+	// NestedFaults looks for a Fault anywhere within the Body of
+	// a response, for services that return faults wrapped in
+	// their response element rather than as the Body's only
+	// entry.
+	NestedFaults bool
+
+	// LenientNamespaces recognizes a Fault whatever its
+	// namespace, for services that write it unqualified or in a
+	// namespace of their own, and reads it as a Fault of the
+	// version of the Envelope. Faults are otherwise recognized
+	// only in the namespaces of SOAP 1.1 and 1.2. The Envelope
+	// and Body are always recognized by their local names.
+	LenientNamespaces bool
+
+	// TimeLayouts lists the time.Parse layouts of the dates
+	// and times exchanged with the service, tried in order by
+	// ParseTime. The first is used by FormatTime. If empty,
+	// the layouts of DefaultTimeLayouts are used.
+	TimeLayouts []string
+
+	// MaxMessageSize, if positive, is the size in bytes above
+	// which a message is rejected with a *SizeError once its
+	// Content-Encoding is removed, so that a misbehaving service
+	// cannot exhaust memory. If zero, DefaultMaxMessageSize is
+	// used. If negative, messages of any size are read.
+	MaxMessageSize int64
+}
+
+// DefaultMaxMessageSize is the size in bytes above which messages are
+// rejected when the Profile does not set MaxMessageSize.
+const DefaultMaxMessageSize = 64 << 20
+
+// A SizeError is returned when a message exceeds the size allowed by
+// a Profile. It matches ErrMessageTooLarge with errors.Is.
+type SizeError struct {
+	Limit int64
+}
+
+func (e *SizeError) Error() string {
+	return fmt.Sprintf("soap: message larger than %d bytes", e.Limit)
+}
+
+// Is reports whether target is ErrMessageTooLarge.
+func (e *SizeError) Is(target error) bool {
+	return target == ErrMessageTooLarge
+}
+
+// DefaultTimeLayouts are the layouts of xsd:dateTime values, with and
+// without a time zone.
+var DefaultTimeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999"}
+
+// Profiles of common SOAP implementations.
+var (
+	// AxisV1 is the profile of Apache Axis 1.x services, which
+	// use SOAP encoding and multiRef elements.
+	AxisV1 = &Profile{
+		Name:        "axis1",
+		Flattener:   &Flattener{Drop: dropIndependent},
+		TimeLayouts: []string{"2006-01-02T15:04:05.000Z07:00", time.RFC3339Nano},
+	}
+
+	// DotNetWSE is the profile of ASP.NET web services and
+	// WSE, which write document/literal messages and times with
+	// seven fractional digits and often no time zone.
+	DotNetWSE = &Profile{
+		Name:        "dotnet-wse",
+		Flattener:   &Flattener{},
+		TimeLayouts: []string{"2006-01-02T15:04:05.9999999Z07:00", "2006-01-02T15:04:05.9999999"},
+	}
+
+	// SAPPI is the profile of SAP Process Integration, which
+	// may return faults below the top level of the Body, and
+	// exchanges times without zone or fraction.
+	SAPPI = &Profile{
+		Name:         "sap-pi",
+		Flattener:    &Flattener{},
+		NestedFaults: true,
+		TimeLayouts:  []string{"2006-01-02T15:04:05", "2006-01-02T15:04:05Z07:00", "2006-01-02"},
+	}
+)
+
+// dropIndependent drops the referenced elements that SOAP encoding
+// marks as not being roots of the serialization graph, whatever
+// their name, as well as multiRef elements.
+func dropIndependent(name xml.Name, attr []xml.Attr, referenced bool) bool {
+	if DropMultiRef(name, attr, referenced) {
+		return true
+	}
+	if !referenced {
+		return false
+	}
+	for _, a := range attr {
+		if a.Name.Space == Encoding && a.Name.Local == "root" && a.Value == "0" {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Profile) flattener() *Flattener {
+	if p == nil || p.Flattener == nil {
+		return DefaultFlattener
+	}
+	return p.Flattener
+}
+
+// Parse decodes an http response into a Go value, as the package's
+// Parse function does, with the settings of the profile: the media
+// types it accepts, where it looks for a Fault, and the Flattener
+// dereferencing document links.
+func (p *Profile) Parse(resp *http.Response, v interface{}) error {
+	data, err := readMessage(resp.Header, resp.Body, p)
+	if err != nil {
+		return err
+	}
+	if _, ok := v.(Entries); ok {
+		return unmarshalBody(p.flattener(), data, v)
+	}
+	return p.flattener().Unmarshal(data, v)
+}
+
+// maxMessageSize returns the size above which messages are rejected,
+// or a negative number if there is no limit.
+func (p *Profile) maxMessageSize() int64 {
+	if p == nil || p.MaxMessageSize == 0 {
+		return DefaultMaxMessageSize
+	}
+	return p.MaxMessageSize
+}
+
+func (p *Profile) timeLayouts() []string {
+	if p == nil || len(p.TimeLayouts) == 0 {
+		return DefaultTimeLayouts
+	}
+	return p.TimeLayouts
+}
+
+// ParseTime parses a date or time in one of the profile's layouts.
+func (p *Profile) ParseTime(s string) (time.Time, error) {
+	for _, layout := range p.timeLayouts() {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("soap: cannot parse time %q", s)
+}
+
+// FormatTime formats t in the first of the profile's layouts.
+func (p *Profile) FormatTime(t time.Time) string {
+	return t.Format(p.timeLayouts()[0])
+}
+
+// findFault returns the first Fault in the Body of a SOAP message, or
+// an error if the message cannot be parsed. If nested is set, a Fault
+// is looked for at any depth, otherwise as an entry of the Body. If
+// lenient is set, a Fault in a namespace other than those of SOAP is
+// read as a Fault of the version of the Envelope.
+func findFault(data []byte, nested, lenient bool) error {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	depth, inBody := 0, false
+	var version string // namespace of the Envelope
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if depth == 1 {
+				version = tok.Name.Space
+			}
+			if inBody && tok.Name.Local == "Fault" && lenient && tok.Name.Space != NsSoapEnv && tok.Name.Space != NsSoap12Env {
+				tok.Name.Space = NsSoapEnv
+				if version == NsSoap12Env {
+					tok.Name.Space = NsSoap12Env
+				}
+			}
+			switch {
+			case depth == 2 && tok.Name.Local == "Body":
+				inBody = true
+			case inBody && !nested && depth > 3:
+				if err := d.Skip(); err != nil {
+					return err
+				}
+				depth--
+			case inBody && tok.Name.Local == "Fault" && tok.Name.Space == NsSoap12Env:
+				var f fault12
+				if err := d.DecodeElement(&f, &tok); err != nil {
+					return err
+				}
+				return f.fault()
+			case inBody && tok.Name.Local == "Fault" && tok.Name.Space == NsSoapEnv:
+				var f Fault
+				if err := d.DecodeElement(&f, &tok); err != nil {
+					return err
+				}
+				return &f
+			}
+		case xml.EndElement:
+			depth--
+			if depth == 1 {
+				inBody = false
+			}
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package soap
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ProxyOptions configures the proxies used to reach services,
+// independently of the HTTP_PROXY family of environment variables.
+// Its Proxy method is meant to be assigned to http.Transport.Proxy.
+// Proxy URLs may use the http, https or socks5 schemes; credentials
+// for the proxy may be given in the URL or in Username and Password.
+type ProxyOptions struct {
+	// URL is the proxy used for all requests, unless
+	// overridden by the fields below. If empty, requests are
+	// sent directly.
+	URL string
+
+	// HTTPS, if set, is the proxy used for https requests.
+	HTTPS string
+
+	// Hosts maps service hosts, given as "host" or "host:port",
+	// to the proxy used for them. An empty value means requests
+	// to the host are sent directly.
+	Hosts map[string]string
+
+	// NoProxy lists hosts that are reached directly. Entries
+	// may be host names, which also match their subdomains,
+	// IP addresses, CIDR ranges, or "*" to match all hosts.
+	NoProxy []string
+
+	// Username and Password authenticate with proxies whose
+	// URL does not contain credentials.
+	Username, Password string
+}
+
+// Proxy returns the proxy to use for req, or nil if req should be
+// sent directly.
+func (p *ProxyOptions) Proxy(req *http.Request) (*url.URL, error) {
+	host := req.URL.Hostname()
+	proxy, ok := p.Hosts[req.URL.Host]
+	if !ok {
+		proxy, ok = p.Hosts[host]
+	}
+	if !ok {
+		if p.bypass(host) {
+			return nil, nil
+		}
+		proxy = p.URL
+		if req.URL.Scheme == "https" && p.HTTPS != "" {
+			proxy = p.HTTPS
+		}
+	}
+	if proxy == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, err
+	}
+	if u.User == nil && p.Username != "" {
+		u.User = url.UserPassword(p.Username, p.Password)
+	}
+	return u, nil
+}
+
+func (p *ProxyOptions) bypass(host string) bool {
+	ip := net.ParseIP(host)
+	for _, entry := range p.NoProxy {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "*":
+			return true
+		case strings.Contains(entry, "/"):
+			if _, cidr, err := net.ParseCIDR(entry); err == nil && ip != nil && cidr.Contains(ip) {
+				return true
+			}
+		case ip != nil:
+			if e := net.ParseIP(entry); e != nil && e.Equal(ip) {
+				return true
+			}
+		default:
+			entry = strings.TrimPrefix(entry, ".")
+			h := strings.ToLower(host)
+			if h == entry || strings.HasSuffix(h, "."+entry) {
+				return true
+			}
+		}
+	}
+	return false
+}
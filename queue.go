@@ -0,0 +1,158 @@
+package soap
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// A QueueMessage is a SOAP message carried by message-oriented
+// middleware.
+type QueueMessage struct {
+	// Queue is the name of the queue the message is sent to.
+	Queue string
+
+	// ReplyTo, if not empty, is the queue to which the response
+	// to a request is sent.
+	ReplyTo string
+
+	// CorrelationID identifies the request to which a response
+	// replies. A response carries the CorrelationID of its
+	// request.
+	CorrelationID string
+
+	// Action is the SOAP action of the message.
+	Action string
+
+	// Body is the SOAP envelope.
+	Body []byte
+}
+
+// A Queue publishes and receives messages through a broker. It adapts
+// the client library of an AMQP, JMS or other messaging system for
+// use by a QueueBinding, mapping the fields of a QueueMessage to the
+// properties of the system's messages.
+type Queue interface {
+	// Publish sends a message to msg.Queue.
+	Publish(ctx context.Context, msg *QueueMessage) error
+
+	// Receive returns the next message from the named queue,
+	// waiting for one to arrive until ctx is done.
+	Receive(ctx context.Context, queue string) (*QueueMessage, error)
+}
+
+// A QueueBinding exchanges SOAP messages through message queues. It
+// publishes each request to the queue named by the endpoint, and
+// correlates the responses it receives on the ReplyTo queue with the
+// requests they reply to by their correlation ID. The queue of an
+// endpoint is named by the path of its URL, as in amqp://broker/orders,
+// or by its opaque part, as in jms:queue:orders.
+//
+// Responses are received only while requests are waiting for them.
+// Responses that correlate with no waiting request, such as those
+// arriving after their request was canceled, are discarded.
+type QueueBinding struct {
+	// Queue publishes and receives the messages.
+	Queue Queue
+
+	// ReplyTo is the queue on which responses are received.
+	// It should not be shared with other consumers.
+	ReplyTo string
+
+	mu      sync.Mutex
+	pending map[string]chan queueReply
+	stop    context.CancelFunc
+}
+
+type queueReply struct {
+	data []byte
+	err  error
+}
+
+// Exchange implements the Binding interface. Requests sent by
+// Client.Send are published without waiting for a response.
+func (b *QueueBinding) Exchange(ctx context.Context, u *url.URL, action string, msg []byte) ([]byte, error) {
+	queue := strings.TrimPrefix(u.Path, "/")
+	if u.Opaque != "" {
+		queue = strings.TrimPrefix(u.Opaque, "queue:")
+	}
+	if queue == "" {
+		return nil, errors.New("soap: no queue in endpoint " + u.String())
+	}
+	m := &QueueMessage{Queue: queue, Action: action, Body: msg}
+	if OneWay(ctx) {
+		return nil, b.Queue.Publish(ctx, m)
+	}
+	m.ReplyTo, m.CorrelationID = b.ReplyTo, NewMessageID()
+
+	ch := b.wait(m.CorrelationID)
+	defer b.done(m.CorrelationID)
+	if err := b.Queue.Publish(ctx, m); err != nil {
+		return nil, err
+	}
+	select {
+	case r := <-ch:
+		return r.data, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// wait registers a request waiting for the response correlated with
+// id, receiving responses if no other request is.
+func (b *QueueBinding) wait(id string) chan queueReply {
+	ch := make(chan queueReply, 1)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.pending == nil {
+		b.pending = make(map[string]chan queueReply)
+	}
+	b.pending[id] = ch
+	if b.stop == nil {
+		var ctx context.Context
+		ctx, b.stop = context.WithCancel(context.Background())
+		go b.receive(ctx)
+	}
+	return ch
+}
+
+// done unregisters a request, and stops receiving responses if no
+// other request is waiting for one.
+func (b *QueueBinding) done(id string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.pending, id)
+	if len(b.pending) == 0 && b.stop != nil {
+		b.stop()
+		b.stop = nil
+	}
+}
+
+// receive delivers the responses on the ReplyTo queue to the requests
+// waiting for them until ctx is canceled. If the queue fails, the
+// error is delivered to every waiting request.
+func (b *QueueBinding) receive(ctx context.Context) {
+	for {
+		m, err := b.Queue.Receive(ctx, b.ReplyTo)
+		b.mu.Lock()
+		if err != nil {
+			if ctx.Err() == nil {
+				for id, ch := range b.pending {
+					ch <- queueReply{err: err}
+					delete(b.pending, id)
+				}
+				b.stop()
+				b.stop = nil
+			}
+			b.mu.Unlock()
+			return
+		}
+		if ch, ok := b.pending[m.CorrelationID]; ok {
+			ch <- queueReply{data: m.Body}
+			delete(b.pending, m.CorrelationID)
+		}
+		b.mu.Unlock()
+	}
+}
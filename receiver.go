@@ -0,0 +1,80 @@
+package soap
+
+import (
+	"context"
+	"net/http"
+)
+
+// A Receiver accepts responses that services deliver asynchronously,
+// by sending them to the WS-Addressing ReplyTo address of a request
+// instead of returning them in the HTTP response. A Receiver is an
+// http.Handler, and must be served at Address. Its Correlator tracks
+// the requests awaiting a response.
+type Receiver struct {
+	// Address is the URL at which services can reach the
+	// Receiver. It is sent as the ReplyTo and FaultTo address
+	// of requests.
+	Address string
+
+	Correlator
+}
+
+// Call sends req to the service using c, as Client.Send does, asking
+// the service to deliver its response to r. The returned Future
+// completes when the response arrives and has been decoded into resp,
+// or when the request fails, ctx is done, or r.Timeout elapses. The
+// response is decoded with r.Flattener, if set, or the Flattener of
+// c's Profile.
+func (r *Receiver) Call(ctx context.Context, c *Client, action string, req, resp interface{}, opts ...CallOption) *Future {
+	flat := r.Flattener
+	if flat == nil {
+		flat = c.Profile.flattener()
+	}
+	id, f := r.track(resp, flat)
+	wsa := Addressing{
+		Action:    action,
+		MessageID: id,
+		ReplyTo:   &EndpointReference{Address: r.Address},
+		FaultTo:   &EndpointReference{Address: r.Address},
+	}
+	opts = append(opts[:len(opts):len(opts)], withAddressTo(NsWSA), WithSOAPHeader(wsa.Header()...))
+	go func() {
+		err := c.Send(ctx, action, req, opts...)
+		if err == nil {
+			select {
+			case <-f.done:
+				return
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+		}
+		r.Cancel(id, err)
+	}()
+	return f
+}
+
+// ServeHTTP accepts a response message, and completes the Future of
+// the request named by its RelatesTo header. Messages that do not
+// relate to an outstanding request are rejected with 404 Not Found.
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	data, err := readMessage(req.Header, req.Body, nil)
+	if _, ok := err.(*Fault); err != nil && !ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ok, err := r.Deliver(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !ok {
+		http.Error(w, "soap: no outstanding request for message", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
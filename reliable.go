@@ -0,0 +1,200 @@
+package soap
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"sync"
+	"time"
+)
+
+// NsWSRM is the namespace of WS-ReliableMessaging 1.1.
+const NsWSRM = "http://docs.oasis-open.org/ws-rx/wsrm/200702"
+
+// ErrNotAcknowledged is returned by Sequence.Call when a message is
+// not acknowledged by the service after all retransmissions.
+var ErrNotAcknowledged = errors.New("soap: message not acknowledged")
+
+// A Sequence is a WS-ReliableMessaging 1.1 sequence, through which a
+// Client sends messages to a service that requires them to be
+// delivered reliably. Each message carries a number within the
+// sequence, and is sent again until the service acknowledges it.
+// Acknowledgements are expected in the responses to messages, as
+// is the case when the sequence's AcksTo address is anonymous.
+type Sequence struct {
+	// Retransmits is the maximum number of times an
+	// unacknowledged message is sent again. If zero, 3 is used.
+	Retransmits int
+
+	// Interval is the delay before an unacknowledged message
+	// is sent again. If zero, one second is used.
+	Interval time.Duration
+
+	client *Client
+	id     string
+
+	mu    sync.Mutex
+	last  int64      // last message number assigned
+	acked [][2]int64 // acknowledgement ranges from the service
+}
+
+type rmCreateSequence struct {
+	XMLName xml.Name          `xml:"http://docs.oasis-open.org/ws-rx/wsrm/200702 CreateSequence"`
+	AcksTo  EndpointReference `xml:"http://docs.oasis-open.org/ws-rx/wsrm/200702 AcksTo"`
+}
+
+type rmCreateSequenceResponse struct {
+	XMLName    xml.Name `xml:"http://docs.oasis-open.org/ws-rx/wsrm/200702 CreateSequenceResponse"`
+	Identifier string   `xml:"http://docs.oasis-open.org/ws-rx/wsrm/200702 Identifier"`
+}
+
+type rmTerminateSequence struct {
+	XMLName       xml.Name `xml:"http://docs.oasis-open.org/ws-rx/wsrm/200702 TerminateSequence"`
+	Identifier    string   `xml:"http://docs.oasis-open.org/ws-rx/wsrm/200702 Identifier"`
+	LastMsgNumber int64    `xml:"http://docs.oasis-open.org/ws-rx/wsrm/200702 LastMsgNumber,omitempty"`
+}
+
+type rmSequence struct {
+	XMLName        xml.Name `xml:"http://docs.oasis-open.org/ws-rx/wsrm/200702 Sequence"`
+	MustUnderstand xml.Attr `xml:",any,attr"`
+	Identifier     string   `xml:"http://docs.oasis-open.org/ws-rx/wsrm/200702 Identifier"`
+	MessageNumber  int64    `xml:"http://docs.oasis-open.org/ws-rx/wsrm/200702 MessageNumber"`
+}
+
+type rmAcknowledgement struct {
+	Identifier string `xml:"http://docs.oasis-open.org/ws-rx/wsrm/200702 Identifier"`
+	Ranges     []struct {
+		Lower int64 `xml:"Lower,attr"`
+		Upper int64 `xml:"Upper,attr"`
+	} `xml:"http://docs.oasis-open.org/ws-rx/wsrm/200702 AcknowledgementRange"`
+}
+
+// CreateSequence creates a new sequence with the service reached by c.
+func CreateSequence(ctx context.Context, c *Client) (*Sequence, error) {
+	var resp rmCreateSequenceResponse
+	req := rmCreateSequence{AcksTo: EndpointReference{Address: AnonymousAddress}}
+	if err := rmCall(ctx, c, NsWSRM+"/CreateSequence", req, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Identifier == "" {
+		return nil, errors.New("soap: CreateSequenceResponse has no Identifier")
+	}
+	return &Sequence{client: c, id: resp.Identifier}, nil
+}
+
+// rmCall makes a call addressed with WS-Addressing headers, as
+// required for all messages of WS-ReliableMessaging.
+func rmCall(ctx context.Context, c *Client, action string, req, resp interface{}, opts ...CallOption) error {
+	wsa := Addressing{
+		Action:    action,
+		MessageID: NewMessageID(),
+		ReplyTo:   &EndpointReference{Address: AnonymousAddress},
+	}
+	opts = append(opts[:len(opts):len(opts)], withAddressTo(NsWSA), WithSOAPHeader(wsa.Header()...))
+	return c.Call(ctx, action, req, resp, opts...)
+}
+
+// ID returns the identifier of the sequence.
+func (s *Sequence) ID() string {
+	return s.id
+}
+
+// Call sends req as the next message of the sequence, and decodes
+// the response into resp, as Client.Call does. If the message is not
+// acknowledged, because the request failed in transit or the response
+// does not acknowledge it, it is sent again as described by
+// s.Retransmits and s.Interval, even if a response was decoded. If it
+// is never acknowledged, ErrNotAcknowledged is returned. Faults are
+// returned without retransmitting the message.
+func (s *Sequence) Call(ctx context.Context, action string, req, resp interface{}, opts ...CallOption) error {
+	s.mu.Lock()
+	s.last++
+	n := s.last
+	s.mu.Unlock()
+
+	wsa := Addressing{
+		Action:    action,
+		MessageID: NewMessageID(),
+		ReplyTo:   &EndpointReference{Address: AnonymousAddress},
+	}
+	header := append(wsa.Header(), rmSequence{
+		MustUnderstand: xml.Attr{Name: xml.Name{Space: s.client.Version.envelopeNS(), Local: "mustUnderstand"}, Value: "1"},
+		Identifier:     s.id,
+		MessageNumber:  n,
+	})
+	opts = append(opts[:len(opts):len(opts)],
+		withAddressTo(NsWSA),
+		WithSOAPHeader(header...),
+		func(x *exchange) { x.observe(s.acknowledge) },
+	)
+	retransmits := intOr(s.Retransmits, 3)
+	for i := 0; ; i++ {
+		err := s.client.Call(ctx, action, req, resp, opts...)
+		if s.acknowledged(n) {
+			return err
+		}
+		if err != nil && isResponse(err) {
+			return err
+		}
+		if i >= retransmits {
+			if err == nil {
+				err = ErrNotAcknowledged
+			}
+			return err
+		}
+		t := time.NewTimer(durationOr(s.Interval, time.Second))
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// Terminate ends the sequence. Terminate does not wait for messages
+// still being sent.
+func (s *Sequence) Terminate(ctx context.Context) error {
+	s.mu.Lock()
+	last := s.last
+	s.mu.Unlock()
+	req := rmTerminateSequence{Identifier: s.id, LastMsgNumber: last}
+	return rmCall(ctx, s.client, NsWSRM+"/TerminateSequence", req, nil)
+}
+
+// acknowledge records the acknowledgements for the sequence in the
+// Header of a response message.
+func (s *Sequence) acknowledge(data []byte) {
+	var msg struct {
+		Header struct {
+			Acks []rmAcknowledgement `xml:"http://docs.oasis-open.org/ws-rx/wsrm/200702 SequenceAcknowledgement"`
+		} `xml:"Header"`
+	}
+	if xml.Unmarshal(data, &msg) != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ack := range msg.Header.Acks {
+		if ack.Identifier != s.id {
+			continue
+		}
+		s.acked = s.acked[:0]
+		for _, r := range ack.Ranges {
+			s.acked = append(s.acked, [2]int64{r.Lower, r.Upper})
+		}
+	}
+}
+
+// acknowledged reports whether the service has acknowledged
+// message n.
+func (s *Sequence) acknowledged(n int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.acked {
+		if r[0] <= n && n <= r[1] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package soap
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSequence(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		received  = map[int64]int{}
+		dropped   bool
+		terminate bool
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var msg struct {
+			Header struct {
+				Sequence *rmSequence
+			}
+			Body struct {
+				Create    *rmCreateSequence
+				Terminate *rmTerminateSequence
+			}
+		}
+		if err := xml.Unmarshal(data, &msg); err != nil {
+			t.Error(err)
+		}
+		if wsa, err := readAddressing(data); err != nil || wsa.To != "http://"+r.Host {
+			t.Errorf("message addressed to %v, want http://%s: %v", wsa, r.Host, err)
+		}
+		reply := func(header, body string) {
+			w.Header().Set("Content-Type", "text/xml")
+			fmt.Fprintf(w, `<soapenv:Envelope xmlns:soapenv="%s" xmlns:rm="%s"><soapenv:Header>%s</soapenv:Header><soapenv:Body>%s</soapenv:Body></soapenv:Envelope>`,
+				NsSoapEnv, NsWSRM, header, body)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case msg.Body.Create != nil:
+			reply("", `<rm:CreateSequenceResponse><rm:Identifier>urn:seq:1</rm:Identifier></rm:CreateSequenceResponse>`)
+		case msg.Body.Terminate != nil:
+			terminate = msg.Body.Terminate.LastMsgNumber == 2
+			reply("", `<rm:TerminateSequenceResponse><rm:Identifier>urn:seq:1</rm:Identifier></rm:TerminateSequenceResponse>`)
+		case msg.Header.Sequence != nil:
+			n := msg.Header.Sequence.MessageNumber
+			if n == 2 && !dropped {
+				dropped = true
+				conn, _, _ := w.(http.Hijacker).Hijack()
+				conn.Close()
+				return
+			}
+			received[n]++
+			reply(fmt.Sprintf(`<rm:SequenceAcknowledgement><rm:Identifier>urn:seq:1</rm:Identifier><rm:AcknowledgementRange Lower="1" Upper="%d"/></rm:SequenceAcknowledgement>`, n), "")
+		default:
+			t.Errorf("unexpected message %s", data)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	seq, err := CreateSequence(ctx, &Client{Failover: NewFailover(srv.URL)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seq.ID() != "urn:seq:1" {
+		t.Errorf("got sequence %q", seq.ID())
+	}
+	seq.Interval = time.Millisecond
+	for i := 0; i < 2; i++ {
+		if err := seq.Call(ctx, "Notify", echoRequest{Value: "x"}, nil); err != nil {
+			t.Fatalf("message %d: %v", i+1, err)
+		}
+	}
+	if err := seq.Terminate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !dropped || received[1] != 1 || received[2] != 1 {
+		t.Errorf("dropped %v, received %v", dropped, received)
+	}
+	if !terminate {
+		t.Error("TerminateSequence not sent with LastMsgNumber 2")
+	}
+}
+
+func TestSequenceNotAcknowledged(t *testing.T) {
+	var sent int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/xml")
+		if strings.Contains(string(data), "CreateSequence") {
+			fmt.Fprintf(w, `<soapenv:Envelope xmlns:soapenv="%s" xmlns:rm="%s"><soapenv:Body><rm:CreateSequenceResponse><rm:Identifier>urn:seq:1</rm:Identifier></rm:CreateSequenceResponse></soapenv:Body></soapenv:Envelope>`,
+				NsSoapEnv, NsWSRM)
+			return
+		}
+		sent++
+		fmt.Fprintf(w, `<soapenv:Envelope xmlns:soapenv="%s"><soapenv:Body><t:EchoResponse xmlns:t="urn:test"><value>v</value></t:EchoResponse></soapenv:Body></soapenv:Envelope>`,
+			NsSoapEnv)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	seq, err := CreateSequence(ctx, &Client{URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq.Retransmits, seq.Interval = 1, time.Millisecond
+	var out echoResponse
+	if err := seq.Call(ctx, "Echo", echoRequest{}, &out); err != ErrNotAcknowledged {
+		t.Errorf("got %v, want ErrNotAcknowledged", err)
+	}
+	if sent != 2 {
+		t.Errorf("message sent %d times, want 2", sent)
+	}
+}
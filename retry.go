@@ -0,0 +1,116 @@
+package soap
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// A RetryPolicy controls how a Client retries failed calls. Delays
+// between attempts grow exponentially from BaseDelay up to MaxDelay,
+// and each delay is randomized to between half and all of its nominal
+// value, so that many clients failing at once do not retry in step.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts made for
+	// a single call, including the first.
+	MaxAttempts int
+
+	// BaseDelay is the delay before the first retry. If zero,
+	// 100 milliseconds is used.
+	BaseDelay time.Duration
+
+	// MaxDelay bounds the delay between attempts. If zero,
+	// delays are unbounded.
+	MaxDelay time.Duration
+
+	// Retryable reports whether a call that failed with err
+	// should be attempted again. err is a *Fault if the service
+	// responded with a SOAP Fault, a *StatusError if it responded
+	// with an unexpected HTTP status, or an error from the HTTP
+	// transport. If nil, DefaultRetryable is used.
+	Retryable func(err error) bool
+}
+
+// DefaultRetryable retries transport errors, Server faults (Receiver
+// faults in SOAP 1.2), and HTTP statuses that indicate a temporary
+// condition. Client faults are never retried, as the same request
+// would fail again.
+func DefaultRetryable(err error) bool {
+	switch err := err.(type) {
+	case *Fault:
+		class := faultClass(err.Code)
+		return class == "Server" || class == "Receiver"
+	case *StatusError:
+		switch err.StatusCode {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests,
+			http.StatusBadGateway, http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	case *url.Error:
+		return true
+	case net.Error:
+		return true
+	}
+	return false
+}
+
+// faultClass returns the local part of a fault code, without any
+// subcodes; for "soapenv:Server.userException", it is "Server".
+func faultClass(code string) string {
+	if i := strings.Index(code, "."); i >= 0 {
+		code = code[:i]
+	}
+	if i := strings.LastIndex(code, ":"); i >= 0 {
+		code = code[i+1:]
+	}
+	return code
+}
+
+func (p *RetryPolicy) delay(attempt int) time.Duration {
+	d := p.BaseDelay
+	if d <= 0 {
+		d = 100 * time.Millisecond
+	}
+	// stop doubling before d overflows, for unbounded delays
+	for i := 1; i < attempt && d <= math.MaxInt64/2; i++ {
+		d *= 2
+		if p.MaxDelay > 0 && d >= p.MaxDelay {
+			break
+		}
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// wait reports whether another attempt should follow the given
+// failed attempt, sleeping for the backoff delay if so. A nil
+// policy never retries.
+func (p *RetryPolicy) wait(ctx context.Context, attempt int, err error) bool {
+	if p == nil || attempt >= p.MaxAttempts || ctx.Err() != nil {
+		return false
+	}
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = DefaultRetryable
+	}
+	if !retryable(err) {
+		return false
+	}
+	t := time.NewTimer(p.delay(attempt))
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,158 @@
+// Package salesforce provides sessions for the Salesforce SOAP APIs.
+// A Client logs in with the login() call, sends later calls to the
+// server URL it returns with the session ID in a SessionHeader, and
+// logs in again when the session expires.
+package salesforce
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"sync"
+
+	"aqwari.net/exp/soap"
+)
+
+// Namespaces of the Partner and Enterprise APIs.
+const (
+	NsPartner    = "urn:partner.soap.sforce.com"
+	NsEnterprise = "urn:enterprise.soap.sforce.com"
+)
+
+// A Client calls the Partner or Enterprise API.
+type Client struct {
+	// SOAP sends requests. Its URL is the login endpoint, such as
+	// "https://login.salesforce.com/services/Soap/u/59.0"; other
+	// calls are sent to the server URL returned by login.
+	SOAP *soap.Client
+
+	// Namespace is the namespace of the API, NsPartner or
+	// NsEnterprise. If empty, NsPartner is used.
+	Namespace string
+
+	// Username and Password are the credentials sent to login.
+	// Unless the client's IP address is trusted by the
+	// organization, Password must have the user's security token
+	// appended.
+	Username, Password string
+
+	login     sync.Mutex
+	mu        sync.Mutex
+	sessionID string
+	serverURL string
+}
+
+// NewClient returns a Client that logs in at loginURL.
+func NewClient(loginURL, username, password string) *Client {
+	return &Client{
+		SOAP:     &soap.Client{URL: loginURL},
+		Username: username,
+		Password: password,
+	}
+}
+
+func (c *Client) namespace() string {
+	if c.Namespace == "" {
+		return NsPartner
+	}
+	return c.Namespace
+}
+
+type loginRequest struct {
+	XMLName  xml.Name
+	Username string `xml:"username"`
+	Password string `xml:"password"`
+}
+
+type loginResponse struct {
+	ServerURL string `xml:"result>serverUrl"`
+	SessionID string `xml:"result>sessionId"`
+}
+
+type sessionHeader struct {
+	XMLName   xml.Name
+	SessionID string `xml:"sessionId"`
+}
+
+// Login calls login, starting a new session. It is called before
+// the first call made with c, and when a call fails because the
+// session has expired.
+func (c *Client) Login(ctx context.Context) error {
+	ns := c.namespace()
+	req := loginRequest{
+		XMLName:  xml.Name{Space: ns, Local: "login"},
+		Username: c.Username,
+		Password: c.Password,
+	}
+	var resp loginResponse
+	if err := c.SOAP.Call(ctx, "login", req, &resp); err != nil {
+		return err
+	}
+	if resp.SessionID == "" || resp.ServerURL == "" {
+		return errors.New("salesforce: login response has no session")
+	}
+	c.mu.Lock()
+	c.sessionID = resp.SessionID
+	c.serverURL = resp.ServerURL
+	c.mu.Unlock()
+	return nil
+}
+
+// Session returns the ID of the current session and the URL to
+// which calls are sent, or empty strings before the first login.
+func (c *Client) Session() (sessionID, serverURL string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sessionID, c.serverURL
+}
+
+// renew logs in, unless another call has already replaced the
+// session stale since it was read.
+func (c *Client) renew(ctx context.Context, stale string) error {
+	c.login.Lock()
+	defer c.login.Unlock()
+	if id, _ := c.Session(); id != stale {
+		return nil
+	}
+	return c.Login(ctx)
+}
+
+// Call invokes an API operation such as "query", whose request
+// element req must be in the API's namespace. If the session has
+// expired, the call fails with an INVALID_SESSION_ID fault; Call
+// then logs in again and makes the call once more.
+func (c *Client) Call(ctx context.Context, operation string, req, resp interface{}) error {
+	id, url := c.Session()
+	if id == "" {
+		if err := c.renew(ctx, ""); err != nil {
+			return err
+		}
+		id, url = c.Session()
+	}
+	err := c.call(ctx, id, url, operation, req, resp)
+	if f, ok := err.(*soap.Fault); ok && isInvalidSession(f) {
+		if err := c.renew(ctx, id); err != nil {
+			return err
+		}
+		id, url = c.Session()
+		return c.call(ctx, id, url, operation, req, resp)
+	}
+	return err
+}
+
+func (c *Client) call(ctx context.Context, id, url, operation string, req, resp interface{}) error {
+	header := sessionHeader{
+		XMLName:   xml.Name{Space: c.namespace(), Local: "SessionHeader"},
+		SessionID: id,
+	}
+	return c.SOAP.Call(ctx, operation, req, resp, soap.WithEndpoint(url), soap.WithSOAPHeader(header))
+}
+
+func isInvalidSession(f *soap.Fault) bool {
+	code := f.Code
+	if i := strings.LastIndex(code, ":"); i >= 0 {
+		code = code[i+1:]
+	}
+	return code == "INVALID_SESSION_ID"
+}
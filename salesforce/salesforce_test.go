@@ -0,0 +1,91 @@
+package salesforce
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient(t *testing.T) {
+	var srv *httptest.Server
+	var logins int
+	session := ""
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var msg struct {
+			Header struct {
+				SessionID string `xml:"urn:partner.soap.sforce.com SessionHeader>sessionId"`
+			}
+			Body struct {
+				Login *struct {
+					Username string `xml:"username"`
+					Password string `xml:"password"`
+				} `xml:"urn:partner.soap.sforce.com login"`
+				Query *struct{} `xml:"urn:partner.soap.sforce.com query"`
+			}
+		}
+		if err := xml.Unmarshal(data, &msg); err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+		var body string
+		switch {
+		case msg.Body.Login != nil:
+			if r.URL.Path != "/services/Soap/u/59.0" {
+				t.Errorf("login sent to %s", r.URL.Path)
+			}
+			if msg.Body.Login.Username != "user" || msg.Body.Login.Password != "secret" {
+				t.Errorf("wrong credentials: %s", data)
+			}
+			logins++
+			session = fmt.Sprintf("s%d", logins)
+			body = `<loginResponse><result><serverUrl>` + srv.URL + `/services/Soap/u/59.0/00D</serverUrl>
+<sessionId>` + session + `</sessionId></result></loginResponse>`
+		case msg.Body.Query != nil:
+			if r.URL.Path != "/services/Soap/u/59.0/00D" {
+				t.Errorf("query sent to %s", r.URL.Path)
+			}
+			if msg.Header.SessionID != session {
+				w.WriteHeader(http.StatusInternalServerError)
+				body = `<soapenv:Fault><faultcode>sf:INVALID_SESSION_ID</faultcode>
+<faultstring>INVALID_SESSION_ID: Invalid Session ID found in SessionHeader</faultstring></soapenv:Fault>`
+			} else {
+				body = `<queryResponse><result><size>0</size><done>true</done></result></queryResponse>`
+			}
+		default:
+			t.Errorf("unexpected request %s", data)
+		}
+		fmt.Fprintf(w, `<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/" xmlns="%s"><soapenv:Body>%s</soapenv:Body></soapenv:Envelope>`,
+			NsPartner, body)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	c := NewClient(srv.URL+"/services/Soap/u/59.0", "user", "secret")
+	query := struct {
+		XMLName     xml.Name `xml:"urn:partner.soap.sforce.com query"`
+		QueryString string   `xml:"queryString"`
+	}{QueryString: "SELECT Id FROM Account"}
+	var resp struct {
+		Done bool `xml:"result>done"`
+	}
+	if err := c.Call(ctx, "query", query, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if logins != 1 || !resp.Done {
+		t.Errorf("%d logins, response %+v", logins, resp)
+	}
+
+	// the server expires the session
+	session = "expired"
+	if err := c.Call(ctx, "query", query, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := c.Session(); logins != 2 || id != "s2" {
+		t.Errorf("%d logins, session %s", logins, id)
+	}
+}
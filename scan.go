@@ -0,0 +1,289 @@
+package soap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// A tokenizer reads the tokens of an XML document without resolving
+// namespace prefixes, as xml.Decoder.RawToken does. It is used to
+// split documents into elements when flattening them, which is where
+// most of the time of decoding a SOAP message is spent.
+//
+// The tokenizer is chosen at build time by newTokenizer: the Decoder
+// of encoding/xml by default, or the scanner below if the soapscan
+// build tag is set.
+type tokenizer interface {
+	RawToken() (xml.Token, error)
+	InputOffset() int64
+}
+
+// A scanner is a tokenizer reading a document held in memory. It is
+// faster than an xml.Decoder, as it finds the delimiters of tokens
+// with the bytes package rather than reading one byte at a time, and
+// returns slices of the document where no unescaping is needed. Like
+// RawToken, it does not check that start and end elements match.
+type scanner struct {
+	data []byte
+	pos  int
+	end  *xml.EndElement // end of the last element, if empty
+}
+
+func newScanner(data []byte) *scanner {
+	return &scanner{data: data}
+}
+
+func (s *scanner) InputOffset() int64 {
+	return int64(s.pos)
+}
+
+func (s *scanner) RawToken() (xml.Token, error) {
+	if s.end != nil {
+		end := *s.end
+		s.end = nil
+		return end, nil
+	}
+	if s.pos >= len(s.data) {
+		return nil, io.EOF
+	}
+	rest := s.data[s.pos:]
+	if rest[0] != '<' {
+		i := bytes.IndexByte(rest, '<')
+		if i < 0 {
+			i = len(rest)
+		}
+		text, err := s.unescape(rest[:i])
+		if err != nil {
+			return nil, err
+		}
+		s.pos += i
+		return xml.CharData(text), nil
+	}
+	switch {
+	case bytes.HasPrefix(rest, []byte("<!--")):
+		i := bytes.Index(rest[4:], []byte("-->"))
+		if i < 0 {
+			return nil, s.syntaxError("unterminated comment")
+		}
+		s.pos += 4 + i + 3
+		return xml.Comment(rest[4 : 4+i]), nil
+	case bytes.HasPrefix(rest, []byte("<![CDATA[")):
+		i := bytes.Index(rest[9:], []byte("]]>"))
+		if i < 0 {
+			return nil, s.syntaxError("unterminated CDATA section")
+		}
+		s.pos += 9 + i + 3
+		return xml.CharData(rest[9 : 9+i]), nil
+	case bytes.HasPrefix(rest, []byte("<!")):
+		return s.directive(rest)
+	case bytes.HasPrefix(rest, []byte("<?")):
+		i := bytes.Index(rest, []byte("?>"))
+		if i < 0 {
+			return nil, s.syntaxError("unterminated processing instruction")
+		}
+		s.pos += i + 2
+		pi := rest[2:i]
+		j := skipName(pi, 0)
+		return xml.ProcInst{Target: string(pi[:j]), Inst: pi[skipSpace(pi, j):]}, nil
+	case bytes.HasPrefix(rest, []byte("</")):
+		i := bytes.IndexByte(rest, '>')
+		if i < 0 {
+			return nil, s.syntaxError("unterminated end element")
+		}
+		name := strings.TrimRight(string(rest[2:i]), " \t\r\n")
+		if !validName(name) {
+			return nil, s.syntaxError("invalid end element name " + strconv.Quote(name))
+		}
+		s.pos += i + 1
+		return xml.EndElement{Name: splitName(name)}, nil
+	}
+	return s.startElement(rest)
+}
+
+// directive reads a directive, such as a DOCTYPE declaration, which
+// may contain an internal subset in brackets.
+func (s *scanner) directive(rest []byte) (xml.Token, error) {
+	depth, quote := 0, byte(0)
+	for i := 2; i < len(rest); i++ {
+		switch c := rest[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case c == '>' && depth == 0:
+			s.pos += i + 1
+			return xml.Directive(rest[2:i]), nil
+		}
+	}
+	return nil, s.syntaxError("unterminated directive")
+}
+
+func (s *scanner) startElement(rest []byte) (xml.Token, error) {
+	i := 1
+	name := scanName(rest, &i)
+	if name == "" {
+		return nil, s.syntaxError("expected element name after <")
+	}
+	start := xml.StartElement{Name: splitName(name)}
+	for {
+		i = skipSpace(rest, i)
+		if i >= len(rest) {
+			return nil, s.syntaxError("unterminated start element")
+		}
+		switch rest[i] {
+		case '>':
+			s.pos += i + 1
+			return start, nil
+		case '/':
+			if i+1 >= len(rest) || rest[i+1] != '>' {
+				return nil, s.syntaxError("expected /> in element")
+			}
+			s.pos += i + 2
+			s.end = &xml.EndElement{Name: start.Name}
+			return start, nil
+		}
+		attr := scanName(rest, &i)
+		if attr == "" {
+			return nil, s.syntaxError("expected attribute name in element")
+		}
+		i = skipSpace(rest, i)
+		if i >= len(rest) || rest[i] != '=' {
+			return nil, s.syntaxError("attribute name without = in element")
+		}
+		i = skipSpace(rest, i+1)
+		if i >= len(rest) || rest[i] != '"' && rest[i] != '\'' {
+			return nil, s.syntaxError("unquoted or missing attribute value in element")
+		}
+		j := bytes.IndexByte(rest[i+1:], rest[i])
+		if j < 0 {
+			return nil, s.syntaxError("unterminated attribute value")
+		}
+		value, err := s.unescape(rest[i+1 : i+1+j])
+		if err != nil {
+			return nil, err
+		}
+		start.Attr = append(start.Attr, xml.Attr{Name: splitName(attr), Value: string(value)})
+		i += j + 2
+	}
+}
+
+// scanName reads a name starting at data[*i], advancing *i past it.
+// It returns "" if there is no valid name.
+func scanName(data []byte, i *int) string {
+	start := *i
+	*i = skipName(data, start)
+	if name := string(data[start:*i]); validName(name) {
+		return name
+	}
+	return ""
+}
+
+// skipName returns the index of the first delimiter of a name in data
+// at or after i.
+func skipName(data []byte, i int) int {
+	for i < len(data) {
+		switch data[i] {
+		case ' ', '\t', '\r', '\n', '/', '>', '=':
+			return i
+		}
+		i++
+	}
+	return i
+}
+
+// validName reports whether s is a plausible XML name. Like the
+// rest of the scanner, it accepts more than the specification does,
+// but rejects names with several colons, as xml.Decoder does.
+func validName(s string) bool {
+	if s == "" || strings.Count(s, ":") > 1 {
+		return false
+	}
+	c := s[0]
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c == '_' || c == ':' || c >= utf8.RuneSelf
+}
+
+func skipSpace(data []byte, i int) int {
+	for i < len(data) && (data[i] == ' ' || data[i] == '\t' || data[i] == '\r' || data[i] == '\n') {
+		i++
+	}
+	return i
+}
+
+// splitName splits a valid name at its colon, as xml.Decoder does.
+func splitName(s string) xml.Name {
+	if space, local, ok := strings.Cut(s, ":"); ok && space != "" && local != "" {
+		return xml.Name{Space: space, Local: local}
+	}
+	return xml.Name{Local: s}
+}
+
+var entities = map[string]string{"lt": "<", "gt": ">", "amp": "&", "apos": "'", "quot": `"`}
+
+// unescape replaces the entity and character references of text,
+// and normalizes its line endings. If there are none, text itself is
+// returned.
+func (s *scanner) unescape(text []byte) ([]byte, error) {
+	if bytes.IndexByte(text, '&') < 0 && bytes.IndexByte(text, '\r') < 0 {
+		return text, nil
+	}
+	out := make([]byte, 0, len(text))
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; c {
+		case '\r':
+			out = append(out, '\n')
+			if i+1 < len(text) && text[i+1] == '\n' {
+				i++
+			}
+		case '&':
+			j := bytes.IndexByte(text[i:], ';')
+			if j < 0 {
+				return nil, s.syntaxError("unterminated entity reference")
+			}
+			ref := string(text[i+1 : i+j])
+			if v, ok := entities[ref]; ok {
+				out = append(out, v...)
+			} else if r, ok := charRef(ref); ok {
+				out = utf8.AppendRune(out, r)
+			} else {
+				return nil, s.syntaxError("invalid character entity &" + ref + ";")
+			}
+			i += j
+		default:
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+// charRef decodes the name of a character reference, such as #10
+// or #xA.
+func charRef(ref string) (rune, bool) {
+	var n uint64
+	var err error
+	switch {
+	case strings.HasPrefix(ref, "#x"):
+		n, err = strconv.ParseUint(ref[2:], 16, 32)
+	case strings.HasPrefix(ref, "#"):
+		n, err = strconv.ParseUint(ref[1:], 10, 32)
+	default:
+		return 0, false
+	}
+	if err != nil || !utf8.ValidRune(rune(n)) {
+		return 0, false
+	}
+	return rune(n), true
+}
+
+func (s *scanner) syntaxError(msg string) error {
+	return &xml.SyntaxError{Msg: msg, Line: 1 + bytes.Count(s.data[:s.pos], []byte("\n"))}
+}
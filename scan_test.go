@@ -0,0 +1,60 @@
+package soap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestScanner(t *testing.T) {
+	docs := []string{
+		`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE note [<!ENTITY x "y">]>
+<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/"><soapenv:Body>
+<!-- a comment -->
+<t:Get xmlns:t="urn:test" a='1 &lt; 2' b="&#x41;&#66;"><v>x &amp; y</v><empty/><e2 c="d" /></t:Get>
+<![CDATA[<raw & text>]]>
+</soapenv:Body></soapenv:Envelope>`,
+		"<a>\r\nline\rbreaks\r\n</a>",
+		`<a:b:c/>`,
+	}
+	for _, doc := range docs {
+		d := xml.NewDecoder(bytes.NewReader([]byte(doc)))
+		s := newScanner([]byte(doc))
+		for {
+			want, werr := d.RawToken()
+			got, gerr := s.RawToken()
+			if werr == io.EOF || gerr == io.EOF {
+				if werr != gerr {
+					t.Errorf("got %v, want %v", gerr, werr)
+				}
+				break
+			}
+			if werr != nil || gerr != nil {
+				if werr == nil || gerr == nil {
+					t.Errorf("got %v, want %v", gerr, werr)
+				}
+				break
+			}
+			if !reflect.DeepEqual(xml.CopyToken(got), xml.CopyToken(want)) {
+				t.Errorf("got token %#v, want %#v", got, want)
+			}
+			if gerr == nil && s.InputOffset() != d.InputOffset() {
+				t.Errorf("after %#v: offset %d, want %d", got, s.InputOffset(), d.InputOffset())
+			}
+		}
+	}
+
+	for _, bad := range []string{`<a`, `<a b>`, `<a b="1>`, `<!-- x`, `<a>&bogus;</a>`, `< a/>`} {
+		s := newScanner([]byte(bad))
+		var err error
+		for err == nil {
+			_, err = s.RawToken()
+		}
+		if err == io.EOF {
+			t.Errorf("%s: no syntax error", bad)
+		}
+	}
+}
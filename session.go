@@ -0,0 +1,81 @@
+package soap
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"sync"
+)
+
+// A Session holds state assigned by a server, in the form of cookies
+// and HTTP headers, and sends it back with subsequent requests. This
+// is required by stateful services, such as Axis services that use
+// transport sessions. A Session is safe for concurrent use, and may be
+// shared by several Clients talking to the same service.
+type Session struct {
+	// Jar stores cookies set by the server. If nil,
+	// cookies are not kept.
+	Jar http.CookieJar
+
+	// Headers lists the HTTP response headers whose values are
+	// captured and set on later requests.
+	Headers []string
+
+	mu     sync.Mutex
+	values http.Header
+}
+
+// NewSession returns a Session with an in-memory cookie jar that
+// also captures the named response headers.
+func NewSession(headers ...string) *Session {
+	jar, _ := cookiejar.New(nil)
+	return &Session{Jar: jar, Headers: headers}
+}
+
+// Header returns the captured value of the named header, or
+// the empty string if the server has not yet set it.
+func (s *Session) Header(name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.values.Get(name)
+}
+
+// Reset discards all captured header values. Cookies are left in
+// the Jar.
+func (s *Session) Reset() {
+	s.mu.Lock()
+	s.values = nil
+	s.mu.Unlock()
+}
+
+// prepare adds session state to an outgoing request.
+func (s *Session) prepare(req *http.Request) {
+	if s.Jar != nil {
+		for _, c := range s.Jar.Cookies(req.URL) {
+			req.AddCookie(c)
+		}
+	}
+	s.mu.Lock()
+	for k, v := range s.values {
+		req.Header[k] = append([]string(nil), v...)
+	}
+	s.mu.Unlock()
+}
+
+// capture records session state from a response.
+func (s *Session) capture(rsp *http.Response) {
+	if s.Jar != nil && rsp.Request != nil {
+		if cookies := rsp.Cookies(); len(cookies) > 0 {
+			s.Jar.SetCookies(rsp.Request.URL, cookies)
+		}
+	}
+	s.mu.Lock()
+	for _, name := range s.Headers {
+		if v := rsp.Header.Values(name); len(v) > 0 {
+			if s.values == nil {
+				s.values = make(http.Header)
+			}
+			s.values[http.CanonicalHeaderKey(name)] = append([]string(nil), v...)
+		}
+	}
+	s.mu.Unlock()
+}
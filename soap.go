@@ -2,6 +2,11 @@
 // SOAP 1.1. The soap package closely mirrors the standard encoding/xml
 // package. Unmarshaling rules are identical to that of encoding/xml,
 // with the exception that document-local links are dereferenced.
+//
+// The package-level functions use default settings. Settings are
+// grouped in structs rather than passed to variants of the functions:
+// a Flattener holds those of Unmarshal and Flatten, and a Profile
+// those of Parse and of Clients, including the Flattener to use.
 package soap
 
 import (
@@ -9,6 +14,9 @@ import (
 	"encoding/xml"
 	"io"
 	"net/http"
+	"runtime"
+	"sync"
+	"sync/atomic"
 )
 
 const (
@@ -16,9 +24,12 @@ const (
 	NsXSD     = "http://www.w3.org/2001/XMLSchema"
 	NsSoapEnv = "http://schemas.xmlsoap.org/soap/envelope/"
 	Encoding  = "http://schemas.xmlsoap.org/soap/encoding/"
+
+	NsSoap12Env = "http://www.w3.org/2003/05/soap-envelope"
 )
 
-// A Fault describes a standard SOAP 1.1 Fault message.
+// A Fault describes a standard SOAP 1.1 Fault message. Detail holds
+// the raw XML content of the fault's detail element.
 type Fault struct {
 	XMLName xml.Name `xml:"http://schemas.xmlsoap.org/soap/envelope/ Fault"`
 	Code    string   `xml:"faultcode"`
@@ -27,6 +38,28 @@ type Fault struct {
 	Detail  []byte   `xml:"faultDetail"`
 }
 
+// UnmarshalXML decodes a Fault, keeping the content of its detail
+// element as XML.
+func (f *Fault) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var v struct {
+		Code        string `xml:"faultcode"`
+		String      string `xml:"faultstring"`
+		Actor       string `xml:"faultactor"`
+		FaultDetail []byte `xml:"faultDetail"`
+		Detail      *struct {
+			Data []byte `xml:",innerxml"`
+		} `xml:"detail"`
+	}
+	if err := d.DecodeElement(&v, &start); err != nil {
+		return err
+	}
+	*f = Fault{XMLName: start.Name, Code: v.Code, String: v.String, Actor: v.Actor, Detail: v.FaultDetail}
+	if v.Detail != nil {
+		f.Detail = v.Detail.Data
+	}
+	return nil
+}
+
 func (f *Fault) Error() string {
 	if f == nil {
 		return ""
@@ -34,109 +67,455 @@ func (f *Fault) Error() string {
 	return f.String
 }
 
+// A RequestOption modifies a request made by NewRequest.
+type RequestOption func(*requestOptions)
+
+type requestOptions struct {
+	action  string
+	version Version
+	charset string
+	header  http.Header
+}
+
+// WithAction sets the SOAP action of a request, as SetAction does.
+func WithAction(action string) RequestOption {
+	return func(o *requestOptions) { o.action = action }
+}
+
+// WithSOAP12 makes a request carry a SOAP 1.2 message, with the
+// application/soap+xml media type, rather than a SOAP 1.1 message.
+func WithSOAP12() RequestOption {
+	return func(o *requestOptions) { o.version = V12 }
+}
+
+// WithCharset sets the charset parameter of the Content-Type of a
+// request, which is utf-8 by default. It does not change the
+// encoding of the body, which must match.
+func WithCharset(charset string) RequestOption {
+	return func(o *requestOptions) { o.charset = charset }
+}
+
+// WithHeader adds an HTTP header to a request.
+func WithHeader(key, value string) RequestOption {
+	return func(o *requestOptions) { o.header.Add(key, value) }
+}
+
 // NewRequest creates an http Request for use as a SOAP RPC
-// call. The necessary SOAP headers are set.
-func NewRequest(url string, body io.Reader) (*http.Request, error) {
+// call. The necessary SOAP headers are set: by default, those of a
+// SOAP 1.1 message in UTF-8 with an empty action. If url contains a
+// username and password, they are removed from the request URL
+// and sent using HTTP Basic authentication.
+func NewRequest(url string, body io.Reader, opts ...RequestOption) (*http.Request, error) {
+	o := requestOptions{charset: "utf-8", header: make(http.Header)}
+	for _, opt := range opts {
+		opt(&o)
+	}
 	req, err := http.NewRequest("POST", url, body)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("SOAPAction", "")
-	req.Header.Set("Content-Type", "text/xml")
-	req.Header.Set("charset", "utf-8")
-	
+	if user := req.URL.User; user != nil {
+		password, _ := user.Password()
+		req.SetBasicAuth(user.Username(), password)
+		req.URL.User = nil
+	}
+	for k, v := range o.header {
+		req.Header[k] = v
+	}
+	setAction(req, o.version, o.action, o.charset)
 	return req, nil
 }
 
 // Parse decodes an http response into a Go value. If the http
-// response contains a SOAP Fault, an error is returned.
+// response contains a SOAP Fault, an error is returned. The response
+// may be of any media type, including either text/xml or
+// application/soap+xml, or multipart/related with the message as its
+// root part; Profile.StrictMediaType rejects other media types. If v is of type Entries, the
+// entries of the response Body are decoded into its elements, as by
+// Client.Call, rather than the whole message into v. Parse uses the
+// default settings; Profile.Parse uses those of a Profile.
 func Parse(resp *http.Response, v interface{}) error {
+	return (*Profile)(nil).Parse(resp, v)
+}
+
+// readMessage reads a SOAP message from the body of an http request
+// or response with the given headers, as the profile p allows. If the
+// message contains a Fault, it is returned along with the message.
+// Compressed bodies are decompressed, and must not exceed the size
+// allowed by p.
+func readMessage(h http.Header, r io.Reader, p *Profile) ([]byte, error) {
 	var buf bytes.Buffer
+
+	strict := p != nil && p.StrictMediaType
+	if strict {
+		if err := checkMediaType(h); err != nil {
+			return nil, err
+		}
+	}
+	body, err := decodedBody(h, r)
+	if err != nil {
+		return nil, err
+	}
+	if body, err = rootPart(h, body, strict); err != nil {
+		return nil, err
+	}
+	if limit := p.maxMessageSize(); limit >= 0 {
+		if _, err := io.Copy(&buf, io.LimitReader(body, limit+1)); err != nil {
+			return nil, err
+		}
+		if int64(buf.Len()) > limit {
+			return nil, &SizeError{Limit: limit}
+		}
+	} else if _, err := io.Copy(&buf, body); err != nil {
+		return nil, err
+	}
+	if p != nil && (p.NestedFaults || p.LenientNamespaces) {
+		return buf.Bytes(), findFault(buf.Bytes(), p.NestedFaults, p.LenientNamespaces)
+	}
+	return buf.Bytes(), checkFault(buf.Bytes())
+}
+
+// checkFault returns the Fault contained in a SOAP message, or an
+// error if the message cannot be parsed.
+func checkFault(data []byte) error {
 	var msg struct {
-		XMLName xml.Name `xml:"http://schemas.xmlsoap.org/soap/envelope/ Envelope"`
+		XMLName xml.Name `xml:"Envelope"`
 		Body    struct {
-			Fault *Fault
-		} `xml:"http://schemas.xmlsoap.org/soap/envelope/ Body"`
+			Fault   *Fault
+			Fault12 *fault12
+		} `xml:"Body"`
 	}
-	
-	if _, err := io.Copy(&buf, resp.Body); err != nil {
-		return err
-	}
-	if err := xml.Unmarshal(buf.Bytes(), &msg); err != nil {
+	if err := xml.Unmarshal(data, &msg); err != nil {
 		return err
 	}
 	if msg.Body.Fault != nil {
 		return msg.Body.Fault
 	}
-	return Unmarshal(buf.Bytes(), v)
+	if msg.Body.Fault12 != nil {
+		return msg.Body.Fault12.fault()
+	}
+	return nil
 }
 
 // Unmarshal decodes XML data into a Go value. Unmarshal behaves identically
 // to xml.Unmarshal, with the addition that document links are dereferenced.
+// It uses DefaultFlattener.
 func Unmarshal(data []byte, v interface{}) error {
-	out, err := Flatten(data)
+	return DefaultFlattener.Unmarshal(data, v)
+}
+
+// Flatten reads XML data from a byte slice and returns a new XML
+// document where all references have been replaced with copies of
+// the referenced data. It uses DefaultFlattener.
+func Flatten(data []byte) ([]byte, error) {
+	return DefaultFlattener.Flatten(data)
+}
+
+// AppendFlatten appends the flattened form of XML data to dst and
+// returns the extended buffer, as Flatten does with a new one. It
+// uses DefaultFlattener.
+func AppendFlatten(dst, data []byte) ([]byte, error) {
+	return DefaultFlattener.AppendFlatten(dst, data)
+}
+
+// NewFlattenTokenReader returns an xml.TokenReader producing the
+// tokens of the XML document read from r, flattened by
+// DefaultFlattener, for use with xml.NewTokenDecoder and other
+// token-stream tools. Names are in resolved form, as returned by
+// xml.Decoder.Token.
+func NewFlattenTokenReader(r io.Reader) xml.TokenReader {
+	return DefaultFlattener.NewTokenReader(r)
+}
+
+// A Flattener dereferences the document links of SOAP-encoded
+// messages, where a value may be serialized once as an independent
+// element with an id attribute, and referenced from elsewhere with an
+// href attribute. The zero Flattener retains every element of the
+// document, including independent elements, and their id and href
+// attributes, for tools that need the original content as well as
+// the resolved values.
+type Flattener struct {
+	// Drop, if non-nil, reports whether an element is removed
+	// from the flattened document. It is called with the name of
+	// the element, with its namespace resolved, its attributes,
+	// and whether its id is the target of an href in the
+	// document. Independent elements are usually removed once
+	// their content has been copied to the elements referencing
+	// them, so that they are not decoded a second time. Drop
+	// may be called concurrently.
+	Drop func(name xml.Name, attr []xml.Attr, referenced bool) bool
+
+	// ShareInput makes the elements of a document refer to the
+	// document's bytes while it is flattened, rather than to
+	// copies made for every element at every level, which
+	// reduces allocation for large messages. The document must
+	// not be modified until Flatten returns. The content of
+	// elements is then kept as written, including comments.
+	ShareInput bool
+
+	// DuplicateIDs selects the element an href refers to when
+	// several elements of a document have its id. By default,
+	// the last one in the document is used.
+	DuplicateIDs DuplicateIDPolicy
+}
+
+// A DuplicateIDPolicy selects how a Flattener treats elements of a
+// document sharing an id, which a buggy serializer may write, or an
+// attacker may add to replace the content of a referenced value.
+type DuplicateIDPolicy int
+
+const (
+	DuplicateLast   DuplicateIDPolicy = iota // refer to the last element with the id
+	DuplicateFirst                           // refer to the first element with the id
+	DuplicateReject                          // return an error
+)
+
+// DefaultFlattener is used by Flatten and Unmarshal, and by Clients
+// whose Profile does not set a Flattener. It drops the independent
+// elements of Apache Axis services.
+var DefaultFlattener = &Flattener{Drop: DropMultiRef}
+
+// DropMultiRef is a Flattener's Drop function removing the multiRef
+// elements written by Apache Axis: every element named multiRef,
+// whatever its namespace and whether or not it is referenced.
+func DropMultiRef(name xml.Name, attr []xml.Attr, referenced bool) bool {
+	return name.Local == "multiRef"
+}
+
+// Unmarshal decodes XML data into a Go value, like xml.Unmarshal,
+// after flattening it.
+func (f *Flattener) Unmarshal(data []byte, v interface{}) error {
+	out, err := f.Flatten(data)
 	if err != nil {
 		return err
 	}
 	return xml.Unmarshal(out, v)
 }
 
+// NewTokenReader returns an xml.TokenReader producing the tokens of
+// the XML document read from r, once flattened. As references may
+// point forward, the whole document is read and flattened when the
+// first token is requested.
+func (f *Flattener) NewTokenReader(r io.Reader) xml.TokenReader {
+	return &flattenReader{f: f, r: r}
+}
+
+type flattenReader struct {
+	f   *Flattener
+	r   io.Reader
+	d   *xml.Decoder
+	err error
+}
+
+func (t *flattenReader) Token() (xml.Token, error) {
+	if t.d == nil && t.err == nil {
+		data, err := io.ReadAll(t.r)
+		if err == nil {
+			data, err = t.f.Flatten(data)
+		}
+		if err != nil {
+			t.err = err
+		} else {
+			t.d = xml.NewDecoder(bytes.NewReader(data))
+		}
+	}
+	if t.err != nil {
+		return nil, t.err
+	}
+	return t.d.Token()
+}
+
+// UnmarshalBuffer is like Unmarshal, but flattens data into buf,
+// overwriting its contents, instead of a new buffer. It returns the
+// buffer, which may have grown, so that it can be reused by the next
+// call. The Go value v does not retain buf.
+func (f *Flattener) UnmarshalBuffer(buf, data []byte, v interface{}) ([]byte, error) {
+	out, err := f.AppendFlatten(buf[:0], data)
+	if err != nil {
+		return buf, err
+	}
+	return out, xml.Unmarshal(out, v)
+}
+
 // Flatten reads XML data from a byte slice and returns a new XML
 // document where all references have been replaced with copies of
-// the referenced data.
-func Flatten(data []byte) ([]byte, error) {
-	var buf bytes.Buffer
-	mref, err := buildMRef(data)
+// the referenced data. The children of elements with many children,
+// such as the Body of a large message, are flattened concurrently.
+func (f *Flattener) Flatten(data []byte) ([]byte, error) {
+	return f.AppendFlatten(nil, data)
+}
+
+// AppendFlatten appends the flattened form of XML data to dst and
+// returns the extended buffer, so that loops flattening many
+// documents can reuse the same buffer.
+func (f *Flattener) AppendFlatten(dst, data []byte) ([]byte, error) {
+	out, _, err := f.appendFlatten(dst, data, false)
+	return out, err
+}
+
+// A Resolution records the dereferencing of an href attribute by a
+// Flattener.
+type Resolution struct {
+	// Path locates the element holding the href attribute in
+	// the flattened document, as the names of the element and
+	// its ancestors as written, such as
+	// "/soapenv:Envelope/soapenv:Body/getResponse/total".
+	Path string
+
+	// ID is the id referenced by the href attribute.
+	ID string
+
+	// Resolved reports whether an element with the id was
+	// found. Unresolved links are left as they are.
+	Resolved bool
+}
+
+// FlattenReport is like Flatten, but also returns a Resolution for
+// every href attribute in the flattened document, in document order,
+// for debugging services and reviewing the references they send.
+func (f *Flattener) FlattenReport(data []byte) ([]byte, []Resolution, error) {
+	return f.appendFlatten(nil, data, true)
+}
+
+// A flattening holds the state of the flattening of a document.
+type flattening struct {
+	mref   map[string]element
+	hrefs  map[string]bool
+	report bool // whether Resolutions are recorded
+}
 
+func (f *Flattener) appendFlatten(dst, data []byte, report bool) ([]byte, []Resolution, error) {
+	buf := bytes.NewBuffer(dst)
+	mref, hrefs, err := buildMRef(data, f.ShareInput, f.DuplicateIDs)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	if elem, err := elements(data); err != nil {
-		return nil, err
-	} else {
-		for _, el := range elem {
-			data, err := flattenXML(el, mref)
-			if err != nil {
-				return nil, err
-			}
-			if _, err := buf.Write(data); err != nil {
-				return nil, err
-			}
+	st := &flattening{mref: mref, hrefs: hrefs, report: report}
+	parse := elements
+	if f.ShareInput {
+		parse = sharedElements
+	}
+	elem, err := parse(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	var resolutions []Resolution
+	for _, el := range elem {
+		data, res, err := f.flattenXML(el, st, nil, "", true)
+		if err != nil {
+			return nil, nil, err
 		}
+		if _, err := buf.Write(data); err != nil {
+			return nil, nil, err
+		}
+		resolutions = append(resolutions, res...)
 	}
-	return buf.Bytes(), nil
+	return buf.Bytes(), resolutions, nil
 }
 
 //BUG(droyo) documents containing reference loops will probably kill
 // the program. This is a security vulnerability and should be addressed
 // before being put into production.
-func flattenXML(root element, mref map[string]element) ([]byte, error) {
+
+// flattenXML flattens an element, whose parent is at path and has the
+// namespace scope given, and returns the Resolutions of its href
+// attributes if they are recorded. If parallel is set, the first
+// elements with at least parallelMin children in each subtree have
+// their children flattened concurrently.
+func (f *Flattener) flattenXML(root element, st *flattening, scope map[string]string, path string, parallel bool) ([]byte, []Resolution, error) {
 	var buf bytes.Buffer
 
-	// heuristic for Apache axis 2 services
-	if root.Name.Local == "multiRef" {
-		return []byte(""), nil
+	scope = nsScope(scope, root.Attr)
+	if f.Drop != nil {
+		id, _ := findId(root.Attr)
+		if f.Drop(resolveName(scope, root.Name, true), resolveAttr(scope, root.Attr), st.hrefs[id]) {
+			return nil, nil, nil
+		}
 	}
 
+	var resolutions []Resolution
+	if st.report {
+		path += "/" + qualifiedName(root.Name)
+	}
 	if href, ok := findHref(root.Attr); ok {
-		if el, ok := mref[href]; ok {
+		el, ok := st.mref[href]
+		if ok {
 			root.Data = el.Data
+			scope = nsScope(scope, el.Attr)
+		}
+		if st.report {
+			resolutions = append(resolutions, Resolution{Path: path, ID: href, Resolved: ok})
 		}
 	}
 	children := root.Children()
 	if len(children) > 0 {
+		data, res, err := f.flattenChildren(children, st, scope, path, parallel)
+		if err != nil {
+			return nil, nil, err
+		}
+		root.Data = data
+		resolutions = append(resolutions, res...)
+	}
+	if err := root.marshal(&buf); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), resolutions, nil
+}
+
+// qualifiedName returns a name read with RawToken as written.
+func qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// parallelMin is the number of children from which the children of
+// an element are flattened concurrently.
+const parallelMin = 16
+
+// flattenChildren flattens the children of an element, in parallel
+// using up to GOMAXPROCS goroutines if parallel is set and there are
+// enough of them. Their own children are then flattened sequentially.
+func (f *Flattener) flattenChildren(children []element, st *flattening, scope map[string]string, path string, parallel bool) ([]byte, []Resolution, error) {
+	workers := runtime.GOMAXPROCS(0)
+	if !parallel || workers < 2 || len(children) < parallelMin {
 		var accum bytes.Buffer
+		var resolutions []Resolution
 		for _, el := range children {
-			if data, err := flattenXML(el, mref); err != nil {
-				return nil, err
-			} else if _, err := accum.Write(data); err != nil {
-				return nil, err
+			data, res, err := f.flattenXML(el, st, scope, path, parallel)
+			if err != nil {
+				return nil, nil, err
 			}
+			accum.Write(data)
+			resolutions = append(resolutions, res...)
 		}
-		root.Data = accum.Bytes()
+		return accum.Bytes(), resolutions, nil
 	}
-	if err := root.marshal(&buf); err != nil {
-		return nil, err
+
+	out := make([][]byte, len(children))
+	res := make([][]Resolution, len(children))
+	errs := make([]error, len(children))
+	var (
+		next int64 = -1
+		wg   sync.WaitGroup
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := int(atomic.AddInt64(&next, 1)); i < len(children); i = int(atomic.AddInt64(&next, 1)) {
+				out[i], res[i], errs[i] = f.flattenXML(children[i], st, scope, path, false)
+			}
+		}()
+	}
+	wg.Wait()
+	var resolutions []Resolution
+	for i, err := range errs {
+		if err != nil {
+			return nil, nil, err
+		}
+		resolutions = append(resolutions, res[i]...)
 	}
-	return buf.Bytes(), nil
+	return bytes.Join(out, nil), resolutions, nil
 }
@@ -0,0 +1,194 @@
+package soap
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// A TCPBinding exchanges SOAP messages over TCP connections, as some
+// enterprise service buses offer in place of HTTP. Each message is
+// framed either with a length prefix or with a trailing delimiter.
+// A connection carries one exchange at a time, and is kept for later
+// exchanges with the same endpoint once the response has been read.
+// Endpoints are URLs of the form tcp://host:port.
+//
+// A TCPBinding is used as the Transport of a Client:
+//
+//	c := &soap.Client{
+//		URL:       "tcp://esb.example.com:9000",
+//		Transport: soap.BindingTransport(new(soap.TCPBinding)),
+//	}
+type TCPBinding struct {
+	// Delimiter, if not empty, ends every message, which must
+	// not contain it. If empty, every message is preceded by
+	// its length in bytes, as a 4-byte big-endian integer.
+	Delimiter []byte
+
+	// MaxMessageSize, if positive, is the size in bytes above
+	// which a response message is rejected. If zero, 16MiB is
+	// used.
+	MaxMessageSize int
+
+	// DialContext, if non-nil, is used to open connections.
+	// Otherwise a net.Dialer is used.
+	DialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// IdleTimeout, if positive, is the time after which an
+	// unused connection is closed rather than reused.
+	IdleTimeout time.Duration
+
+	mu   sync.Mutex
+	idle map[string][]*tcpConn
+}
+
+type tcpConn struct {
+	net.Conn
+	r    *bufio.Reader
+	used time.Time
+}
+
+// ErrMessageTooLarge is returned when a response message exceeds the
+// size allowed by a binding. Errors of type *SizeError also match it
+// with errors.Is.
+var ErrMessageTooLarge = errors.New("soap: message too large")
+
+// Exchange implements the Binding interface. Requests sent by
+// Client.Send are written without waiting for a response, which the
+// service must not send.
+func (b *TCPBinding) Exchange(ctx context.Context, u *url.URL, action string, msg []byte) ([]byte, error) {
+	if u.Scheme != "tcp" {
+		return nil, fmt.Errorf("soap: TCPBinding cannot reach %s endpoint", u.Scheme)
+	}
+	if len(b.Delimiter) > 0 && bytes.Contains(msg, b.Delimiter) {
+		return nil, errors.New("soap: message contains the frame delimiter")
+	}
+	conn, err := b.conn(ctx, u.Host)
+	if err != nil {
+		return nil, err
+	}
+	if d, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(d)
+	} else {
+		conn.SetDeadline(time.Time{})
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+
+	var data []byte
+	err = b.writeMessage(conn, msg)
+	if err == nil && !OneWay(ctx) {
+		data, err = b.readMessage(conn.r)
+	}
+	if !stop() || err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	b.put(u.Host, conn)
+	return data, nil
+}
+
+func (b *TCPBinding) writeMessage(conn *tcpConn, msg []byte) error {
+	if len(b.Delimiter) > 0 {
+		_, err := conn.Write(append(msg[:len(msg):len(msg)], b.Delimiter...))
+		return err
+	}
+	frame := make([]byte, 4, 4+len(msg))
+	binary.BigEndian.PutUint32(frame, uint32(len(msg)))
+	_, err := conn.Write(append(frame, msg...))
+	return err
+}
+
+func (b *TCPBinding) maxSize() int {
+	if b.MaxMessageSize > 0 {
+		return b.MaxMessageSize
+	}
+	return 16 << 20
+}
+
+func (b *TCPBinding) readMessage(r *bufio.Reader) ([]byte, error) {
+	if len(b.Delimiter) == 0 {
+		var size [4]byte
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return nil, err
+		}
+		n := binary.BigEndian.Uint32(size[:])
+		if int64(n) > int64(b.maxSize()) {
+			return nil, ErrMessageTooLarge
+		}
+		msg := make([]byte, n)
+		_, err := io.ReadFull(r, msg)
+		return msg, err
+	}
+	last := b.Delimiter[len(b.Delimiter)-1]
+	var msg []byte
+	for !bytes.HasSuffix(msg, b.Delimiter) {
+		chunk, err := r.ReadSlice(last)
+		if err != nil && err != bufio.ErrBufferFull {
+			return nil, err
+		}
+		msg = append(msg, chunk...)
+		if len(msg) > b.maxSize()+len(b.Delimiter) {
+			return nil, ErrMessageTooLarge
+		}
+	}
+	return msg[:len(msg)-len(b.Delimiter)], nil
+}
+
+// conn returns an idle connection to addr, or a new one.
+func (b *TCPBinding) conn(ctx context.Context, addr string) (*tcpConn, error) {
+	b.mu.Lock()
+	for conns := b.idle[addr]; len(conns) > 0; conns = b.idle[addr] {
+		c := conns[len(conns)-1]
+		b.idle[addr] = conns[:len(conns)-1]
+		if b.IdleTimeout > 0 && time.Since(c.used) > b.IdleTimeout {
+			c.Close()
+			continue
+		}
+		b.mu.Unlock()
+		return c, nil
+	}
+	b.mu.Unlock()
+
+	dial := b.DialContext
+	if dial == nil {
+		dial = new(net.Dialer).DialContext
+	}
+	conn, err := dial(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &tcpConn{Conn: conn, r: bufio.NewReader(conn)}, nil
+}
+
+func (b *TCPBinding) put(addr string, c *tcpConn) {
+	c.used = time.Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.idle == nil {
+		b.idle = make(map[string][]*tcpConn)
+	}
+	b.idle[addr] = append(b.idle[addr], c)
+}
+
+// CloseIdleConnections closes the connections kept for reuse.
+func (b *TCPBinding) CloseIdleConnections() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, conns := range b.idle {
+		for _, c := range conns {
+			c.Close()
+		}
+	}
+	b.idle = nil
+}
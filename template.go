@@ -0,0 +1,105 @@
+package soap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"sync"
+)
+
+// An EnvelopeTemplate holds the serialized frame of SOAP messages
+// written with fixed Encoder settings: the start of the Envelope, its
+// Header and the start of the Body, and the tags closing them. Only
+// the entries of the Body are encoded for each message, which saves
+// encoding the frame again when many messages differing only in
+// their Body are sent. An EnvelopeTemplate is safe for concurrent
+// use.
+type EnvelopeTemplate struct {
+	start, end []byte
+	canonical  bool
+	prefixes   map[string]string
+}
+
+// NewEnvelopeTemplate encodes the frame of the messages written with
+// the settings of enc, including its Header entries, which must not
+// vary between messages.
+func NewEnvelopeTemplate(enc Encoder) (*EnvelopeTemplate, error) {
+	var buf bytes.Buffer
+	e := xml.NewEncoder(&buf)
+	ends, err := enc.writeStart(e)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.Flush(); err != nil {
+		return nil, err
+	}
+	t := &EnvelopeTemplate{start: append([]byte(nil), buf.Bytes()...), canonical: enc.canonical(), prefixes: enc.Prefixes}
+	buf.Reset()
+	for _, end := range ends {
+		if err := e.EncodeToken(end); err != nil {
+			return nil, err
+		}
+	}
+	if err := e.Flush(); err != nil {
+		return nil, err
+	}
+	t.end = buf.Bytes()
+	return t, nil
+}
+
+// Append appends the message for v to dst, as written by Encode with
+// the settings of the template, and returns the extended buffer.
+// Canonical messages are put in canonical form once assembled.
+func (t *EnvelopeTemplate) Append(dst []byte, v interface{}) ([]byte, error) {
+	if t.canonical {
+		msg, err := (&EnvelopeTemplate{start: t.start, end: t.end}).Append(nil, v)
+		if err != nil {
+			return nil, err
+		}
+		buf := bytes.NewBuffer(dst)
+		err = canonicalize(buf, msg, t.prefixes)
+		return buf.Bytes(), err
+	}
+	buf := bytes.NewBuffer(append(dst, t.start...))
+	e := xml.NewEncoder(buf)
+	entries, ok := v.(Entries)
+	if !ok {
+		entries = Entries{v}
+	}
+	for _, v := range entries {
+		if v == nil {
+			continue
+		}
+		if err := e.Encode(v); err != nil {
+			return nil, err
+		}
+	}
+	if err := e.Flush(); err != nil {
+		return nil, err
+	}
+	buf.Write(t.end)
+	return buf.Bytes(), nil
+}
+
+// templateKey identifies the settings of templates kept by
+// envelopeTemplate.
+type templateKey struct {
+	version Version
+	style   string
+}
+
+var templates sync.Map // of templateKey to *EnvelopeTemplate
+
+// envelopeTemplate returns a template for the settings of enc, which
+// must have no Header, made on first use.
+func envelopeTemplate(enc Encoder) (*EnvelopeTemplate, error) {
+	key := templateKey{enc.Version, enc.EncodingStyle}
+	if t, ok := templates.Load(key); ok {
+		return t.(*EnvelopeTemplate), nil
+	}
+	t, err := NewEnvelopeTemplate(enc)
+	if err != nil {
+		return nil, err
+	}
+	templates.Store(key, t)
+	return t, nil
+}
@@ -0,0 +1,105 @@
+package soap
+
+import (
+	"context"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timeouts bounds the phases of a call. A zero value means
+// no limit is imposed, beyond the deadline of the call's context.
+type Timeouts struct {
+	// Call bounds an entire call, including any retries.
+	Call time.Duration
+
+	// Connect bounds the time taken to obtain a connection for
+	// each request, including DNS resolution and TLS handshakes.
+	Connect time.Duration
+
+	// ResponseHeader bounds the time between writing each
+	// request and receiving the response headers.
+	ResponseHeader time.Duration
+}
+
+// A TimeoutError is returned when a phase of a request exceeds
+// its limit in Timeouts. It satisfies net.Error, so it is retried
+// by DefaultRetryable.
+type TimeoutError struct {
+	Phase string // "connect" or "response header"
+}
+
+func (e *TimeoutError) Error() string   { return "soap: " + e.Phase + " timeout" }
+func (e *TimeoutError) Timeout() bool   { return true }
+func (e *TimeoutError) Temporary() bool { return true }
+
+// timeouts returns the Timeouts in effect for an operation.
+func (c *Client) timeouts(action string) Timeouts {
+	t, op := c.Timeouts, c.Operations[action].Timeouts
+	if op.Call > 0 {
+		t.Call = op.Call
+	}
+	if op.Connect > 0 {
+		t.Connect = op.Connect
+	}
+	if op.ResponseHeader > 0 {
+		t.ResponseHeader = op.ResponseHeader
+	}
+	return t
+}
+
+// withPhaseTimeouts returns a context that is canceled with a
+// *TimeoutError if a request made with it exceeds the connect or
+// response header timeouts in t. The returned function releases
+// the context's resources, and must be called once the response
+// has been read.
+func withPhaseTimeouts(ctx context.Context, t Timeouts) (context.Context, func(error)) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	var (
+		mu        sync.Mutex
+		connTimer *time.Timer
+		hdrTimer  *time.Timer
+	)
+	stopTimers := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, t := range []*time.Timer{connTimer, hdrTimer} {
+			if t != nil {
+				t.Stop()
+			}
+		}
+	}
+	if t.Connect > 0 {
+		connTimer = time.AfterFunc(t.Connect, func() {
+			cancel(&TimeoutError{Phase: "connect"})
+		})
+	}
+	trace := &httptrace.ClientTrace{
+		GotConn: func(httptrace.GotConnInfo) {
+			if connTimer != nil {
+				connTimer.Stop()
+			}
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			if t.ResponseHeader <= 0 {
+				return
+			}
+			mu.Lock()
+			hdrTimer = time.AfterFunc(t.ResponseHeader, func() {
+				cancel(&TimeoutError{Phase: "response header"})
+			})
+			mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			mu.Lock()
+			if hdrTimer != nil {
+				hdrTimer.Stop()
+			}
+			mu.Unlock()
+		},
+	}
+	return httptrace.WithClientTrace(ctx, trace), func(error) {
+		stopTimers()
+		cancel(nil)
+	}
+}
@@ -0,0 +1,135 @@
+package soap
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"os"
+)
+
+// TLSOptions describes the TLS settings used to reach a service,
+// typically one requiring mutual TLS. A client certificate may be
+// given as files, as PEM data, or as a certificate with a
+// crypto.Signer for keys held in hardware or a key management service.
+type TLSOptions struct {
+	// CertFile and KeyFile name PEM files holding the client
+	// certificate chain and its private key.
+	CertFile, KeyFile string
+
+	// CertPEM and KeyPEM hold the PEM-encoded client certificate
+	// chain and its private key. They are used if CertFile is empty.
+	CertPEM, KeyPEM []byte
+
+	// Signer, if non-nil, is the private key of the client
+	// certificate, and KeyFile and KeyPEM are ignored.
+	Signer crypto.Signer
+
+	// CAFile and CAPEM hold PEM-encoded CA certificates. If either
+	// is set, only these CAs are trusted to sign the service's
+	// certificate, instead of the system roots.
+	CAFile string
+	CAPEM  []byte
+
+	// MinVersion and MaxVersion bound the TLS versions used. If
+	// MinVersion is zero, TLS 1.2 is the minimum.
+	MinVersion, MaxVersion uint16
+
+	// ServerName overrides the host name used to verify the
+	// service's certificate.
+	ServerName string
+}
+
+// Config returns a tls.Config built from the options.
+func (o *TLSOptions) Config() (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion: o.MinVersion,
+		MaxVersion: o.MaxVersion,
+		ServerName: o.ServerName,
+	}
+	if cfg.MinVersion == 0 {
+		cfg.MinVersion = tls.VersionTLS12
+	}
+
+	certPEM, keyPEM := o.CertPEM, o.KeyPEM
+	var err error
+	if o.CertFile != "" {
+		if certPEM, err = os.ReadFile(o.CertFile); err != nil {
+			return nil, err
+		}
+	}
+	if o.KeyFile != "" && o.Signer == nil {
+		if keyPEM, err = os.ReadFile(o.KeyFile); err != nil {
+			return nil, err
+		}
+	}
+	if len(certPEM) > 0 {
+		var cert tls.Certificate
+		if o.Signer != nil {
+			cert, err = signerCertificate(certPEM, o.Signer)
+		} else {
+			cert, err = tls.X509KeyPair(certPEM, keyPEM)
+		}
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	caPEM := o.CAPEM
+	if o.CAFile != "" {
+		b, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		caPEM = append(append([]byte(nil), caPEM...), b...)
+	}
+	if len(caPEM) > 0 {
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(caPEM) {
+			return nil, errors.New("soap: no CA certificates found in PEM data")
+		}
+	}
+	return cfg, nil
+}
+
+// Transport returns a copy of http.DefaultTransport using the TLS
+// settings in o. HTTP/2 remains enabled, which it would not be if
+// TLSClientConfig were set on a Transport by hand.
+func (o *TLSOptions) Transport() (*http.Transport, error) {
+	cfg, err := o.Config()
+	if err != nil {
+		return nil, err
+	}
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.TLSClientConfig = cfg
+	tr.ForceAttemptHTTP2 = true
+	return tr, nil
+}
+
+// signerCertificate pairs a PEM certificate chain with a signer
+// holding the private key of its leaf.
+func signerCertificate(certPEM []byte, signer crypto.Signer) (tls.Certificate, error) {
+	var cert tls.Certificate
+	for block, rest := pem.Decode(certPEM); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type == "CERTIFICATE" {
+			cert.Certificate = append(cert.Certificate, block.Bytes)
+		}
+	}
+	if len(cert.Certificate) == 0 {
+		return cert, errors.New("soap: no certificates found in PEM data")
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return cert, err
+	}
+	pub, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !pub.Equal(leaf.PublicKey) {
+		return cert, errors.New("soap: signer does not match client certificate")
+	}
+	cert.PrivateKey = signer
+	cert.Leaf = leaf
+	return cert, nil
+}
@@ -0,0 +1,122 @@
+package soap
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// A Token is a security token issued by a token service, such as
+// a WS-Trust STS or a vendor login operation.
+type Token struct {
+	Value string
+
+	// Expires is the time after which the token is no longer
+	// valid. The zero value means the token does not expire.
+	Expires time.Time
+}
+
+func (t Token) expired(now time.Time, margin time.Duration) bool {
+	return !t.Expires.IsZero() && !now.Add(margin).Before(t.Expires)
+}
+
+// A TokenCache caches tokens by key, typically a combination of
+// endpoint and credentials. Concurrent requests for a missing token
+// are coalesced into a single call to Fetch, and tokens nearing expiry
+// are renewed in the background while the current token continues to
+// be served. A TokenCache is safe for concurrent use.
+type TokenCache struct {
+	// Fetch obtains a new token for key.
+	Fetch func(ctx context.Context, key string) (Token, error)
+
+	// RenewBefore is how long before its expiry a token is
+	// renewed. If zero, one minute is used.
+	RenewBefore time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*tokenEntry
+}
+
+type tokenEntry struct {
+	tok      Token
+	err      error
+	ready    chan struct{} // closed once tok and err are set
+	renewing bool
+}
+
+func (tc *TokenCache) margin() time.Duration {
+	if tc.RenewBefore > 0 {
+		return tc.RenewBefore
+	}
+	return time.Minute
+}
+
+// Get returns a valid token for key, fetching one if the cache does
+// not hold one.
+func (tc *TokenCache) Get(ctx context.Context, key string) (Token, error) {
+	now := time.Now()
+	tc.mu.Lock()
+	e := tc.entries[key]
+	if e != nil && isDone(e.ready) && (e.err != nil || e.tok.expired(now, 0)) {
+		e = nil
+	}
+	if e == nil {
+		e = &tokenEntry{ready: make(chan struct{})}
+		if tc.entries == nil {
+			tc.entries = make(map[string]*tokenEntry)
+		}
+		tc.entries[key] = e
+		go tc.fetch(context.WithoutCancel(ctx), key, e)
+	} else if isDone(e.ready) && !e.renewing && e.tok.expired(now, tc.margin()) {
+		e.renewing = true
+		go tc.renew(key, e)
+	}
+	tc.mu.Unlock()
+
+	select {
+	case <-e.ready:
+		return e.tok, e.err
+	case <-ctx.Done():
+		return Token{}, ctx.Err()
+	}
+}
+
+// Invalidate removes the token for key from the cache, so that
+// the next call to Get fetches a new one. It should be called when
+// a service rejects a token before its expiry.
+func (tc *TokenCache) Invalidate(key string) {
+	tc.mu.Lock()
+	delete(tc.entries, key)
+	tc.mu.Unlock()
+}
+
+func (tc *TokenCache) fetch(ctx context.Context, key string, e *tokenEntry) {
+	e.tok, e.err = tc.Fetch(ctx, key)
+	close(e.ready)
+}
+
+// renew replaces the token held in e, which is still valid, with a
+// new one. On failure, the current token continues to be used until
+// a later Get retries the renewal.
+func (tc *TokenCache) renew(key string, e *tokenEntry) {
+	tok, err := tc.Fetch(context.Background(), key)
+
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	e.renewing = false
+	if err != nil || tc.entries[key] != e {
+		return
+	}
+	next := &tokenEntry{tok: tok, ready: make(chan struct{})}
+	close(next.ready)
+	tc.entries[key] = next
+}
+
+func isDone(c chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
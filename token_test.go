@@ -0,0 +1,53 @@
+package soap
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTokenCache(t *testing.T) {
+	var fetches int32
+	tc := &TokenCache{
+		RenewBefore: time.Hour,
+		Fetch: func(ctx context.Context, key string) (Token, error) {
+			n := atomic.AddInt32(&fetches, 1)
+			time.Sleep(10 * time.Millisecond)
+			return Token{Value: key + strconv.Itoa(int(n)), Expires: time.Now().Add(2 * time.Hour)}, nil
+		},
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tok, err := tc.Get(context.Background(), "k")
+			if err != nil || tok.Value != "k1" {
+				t.Errorf("got %q, %v", tok.Value, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Fatalf("%d fetches for concurrent Gets, want 1", n)
+	}
+
+	// Within RenewBefore of expiry: the current token is
+	// served while a new one is fetched.
+	tc.RenewBefore = 3 * time.Hour
+	if tok, _ := tc.Get(context.Background(), "k"); tok.Value != "k1" {
+		t.Errorf("got %q during renewal, want k1", tok.Value)
+	}
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if tok, _ := tc.Get(context.Background(), "k"); tok.Value == "k2" {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Error("token was not renewed")
+}
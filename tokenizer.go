@@ -0,0 +1,15 @@
+//go:build !soapscan
+
+package soap
+
+import (
+	"bytes"
+	"encoding/xml"
+)
+
+// newTokenizer returns the tokenizer of encoding/xml, which checks
+// documents most thoroughly. Build with the soapscan tag to use the
+// faster scanner instead.
+func newTokenizer(data []byte) tokenizer {
+	return xml.NewDecoder(bytes.NewReader(data))
+}
@@ -0,0 +1,8 @@
+//go:build soapscan
+
+package soap
+
+// newTokenizer returns a scanner, selected by the soapscan build tag.
+func newTokenizer(data []byte) tokenizer {
+	return newScanner(data)
+}
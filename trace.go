@@ -0,0 +1,109 @@
+package soap
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// A CallTrace records the timings of a single HTTP request made by
+// a Client. The durations of phases that did not take place, such as
+// DNS resolution on a reused connection, are zero. Comparing Wait
+// against the other phases separates the time spent by the server
+// processing a call from the time spent in the network.
+type CallTrace struct {
+	Action string
+	URL    string
+	Start  time.Time
+
+	// Reused is true if the request was sent over a
+	// previously established connection.
+	Reused bool
+
+	DNS     time.Duration // resolving the host name
+	Connect time.Duration // establishing a TCP connection
+	TLS     time.Duration // performing the TLS handshake
+
+	// Wait is the time between writing the request and reading
+	// the first byte of the response.
+	Wait time.Duration
+
+	// FirstByte is the time from Start until the first byte
+	// of the response was read.
+	FirstByte time.Duration
+
+	// Total is the time from Start until the response body was
+	// closed or the request failed.
+	Total time.Duration
+
+	// Err is the error returned by the transport, if any.
+	Err error
+}
+
+// A tracer collects a CallTrace from httptrace hooks, which may be
+// called concurrently.
+type tracer struct {
+	mu                            sync.Mutex
+	t                             CallTrace
+	dnsStart, connStart, tlsStart time.Time
+	wrote                         time.Time
+}
+
+func newTracer(action, url string) *tracer {
+	return &tracer{t: CallTrace{Action: action, URL: url, Start: time.Now()}}
+}
+
+func (tr *tracer) mark(t *time.Time) {
+	tr.mu.Lock()
+	if t.IsZero() {
+		*t = time.Now()
+	}
+	tr.mu.Unlock()
+}
+
+func (tr *tracer) since(d *time.Duration, t *time.Time) {
+	tr.mu.Lock()
+	if !t.IsZero() {
+		*d = time.Since(*t)
+	}
+	tr.mu.Unlock()
+}
+
+func (tr *tracer) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { tr.mark(&tr.dnsStart) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { tr.since(&tr.t.DNS, &tr.dnsStart) },
+		ConnectStart:      func(_, _ string) { tr.mark(&tr.connStart) },
+		ConnectDone:       func(_, _ string, _ error) { tr.since(&tr.t.Connect, &tr.connStart) },
+		TLSHandshakeStart: func() { tr.mark(&tr.tlsStart) },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			tr.since(&tr.t.TLS, &tr.tlsStart)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			tr.mu.Lock()
+			tr.t.Reused = info.Reused
+			tr.mu.Unlock()
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) { tr.mark(&tr.wrote) },
+		GotFirstResponseByte: func() {
+			tr.mu.Lock()
+			now := time.Now()
+			tr.t.FirstByte = now.Sub(tr.t.Start)
+			if !tr.wrote.IsZero() {
+				tr.t.Wait = now.Sub(tr.wrote)
+			}
+			tr.mu.Unlock()
+		},
+	}
+}
+
+// finish returns a snapshot of the timings collected so far.
+func (tr *tracer) finish(err error) *CallTrace {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	t := tr.t
+	t.Total = time.Since(t.Start)
+	t.Err = err
+	return &t
+}
@@ -0,0 +1,414 @@
+package soap
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func selfSigned(t *testing.T, cn string) ([]byte, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), key
+}
+
+func TestMutualTLS(t *testing.T) {
+	clientCert, clientKey := selfSigned(t, "client")
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			t.Error("no client certificate")
+		}
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: x509.NewCertPool()}
+	srv.TLS.ClientCAs.AppendCertsFromPEM(clientCert)
+	srv.StartTLS()
+	defer srv.Close()
+
+	serverCA := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	opts := &TLSOptions{CertPEM: clientCert, Signer: clientKey, CAPEM: serverCA}
+	tr, err := opts.Transport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp, err := (&http.Client{Transport: tr}).Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+
+	_, otherKey := selfSigned(t, "other")
+	opts.Signer = otherKey
+	if _, err := opts.Config(); err == nil {
+		t.Error("mismatched signer accepted")
+	}
+}
+
+func TestProxyOptions(t *testing.T) {
+	p := &ProxyOptions{
+		URL:      "http://proxy:3128",
+		HTTPS:    "socks5://socks:1080",
+		Hosts:    map[string]string{"partner.example.com:8443": "http://special:8080", "local": ""},
+		NoProxy:  []string{".internal", "10.0.0.0/8"},
+		Username: "u",
+		Password: "p",
+	}
+	tests := map[string]string{
+		"http://svc.example.com/x":           "http://u:p@proxy:3128",
+		"https://svc.example.com/x":          "socks5://u:p@socks:1080",
+		"https://partner.example.com:8443/x": "http://u:p@special:8080",
+		"http://local/x":                     "",
+		"http://a.b.internal/x":              "",
+		"http://10.1.2.3/x":                  "",
+	}
+	for target, want := range tests {
+		req, _ := http.NewRequest("POST", target, nil)
+		u, err := p.Proxy(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ""
+		if u != nil {
+			got = u.String()
+		}
+		if got != want {
+			t.Errorf("%s: got proxy %q, want %q", target, got, want)
+		}
+	}
+}
+
+func TestHTTP2(t *testing.T) {
+	echo := echoHandler(t)
+	var proto int
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proto = r.ProtoMajor
+		echo(w, r)
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	// Large enough to exceed the initial HTTP/2 flow control
+	// windows of the server, so the upload must wait for
+	// WINDOW_UPDATEs.
+	value := strings.Repeat("x", 3<<19)
+	c := &Client{URL: srv.URL, Transport: srv.Client().Transport}
+	var out echoResponse
+	if err := c.Call(context.Background(), "Echo", echoRequest{Value: value}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if proto != 2 {
+		t.Errorf("request used HTTP/%d", proto)
+	}
+	if len(out.Value) != len(value) {
+		t.Errorf("got %d bytes back, sent %d", len(out.Value), len(value))
+	}
+}
+
+func TestExpectContinue(t *testing.T) {
+	var bodyRead bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength < 1<<20 {
+			bodyRead = true
+			echoHandler(t)(w, r)
+			return
+		}
+		// Reject large requests without reading the body; the
+		// server then never sends 100 Continue.
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	var sent int64
+	c := &Client{URL: srv.URL, ExpectContinue: 1 << 20}
+	c.HTTPClient = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		r.Body = countingReader{r.Body, &sent}
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+
+	err := c.Call(context.Background(), "Echo", echoRequest{Value: strings.Repeat("x", 2<<20)}, nil)
+	if err, ok := err.(*StatusError); !ok || err.StatusCode != http.StatusUnauthorized {
+		t.Errorf("got %v, want 401", err)
+	}
+	if n := atomic.LoadInt64(&sent); n > 0 {
+		t.Errorf("%d bytes of body sent to a rejecting server", n)
+	}
+	if err := c.Call(context.Background(), "Echo", echoRequest{Value: "small"}, nil); err != nil || !bodyRead {
+		t.Errorf("small request: %v", err)
+	}
+}
+
+type countingReader struct {
+	io.ReadCloser
+	n *int64
+}
+
+func (r countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	atomic.AddInt64(r.n, int64(n))
+	return n, err
+}
+
+func TestTCPBinding(t *testing.T) {
+	for _, delim := range []string{"", "\x00"} {
+		b := &TCPBinding{Delimiter: []byte(delim)}
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var conns atomic.Int32
+		go func() {
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				conns.Add(1)
+				go func() {
+					defer conn.Close()
+					r := bufio.NewReader(conn)
+					for {
+						msg, err := b.readMessage(r)
+						if err != nil {
+							return
+						}
+						// answer with the response of an HTTP service
+						rec := httptest.NewRecorder()
+						echoHandler(t)(rec, httptest.NewRequest("POST", "/", bytes.NewReader(msg)))
+						resp := rec.Body.Bytes()
+						if delim == "" {
+							var size [4]byte
+							binary.BigEndian.PutUint32(size[:], uint32(len(resp)))
+							conn.Write(size[:])
+						}
+						conn.Write(append(resp, delim...))
+					}
+				}()
+			}
+		}()
+
+		c := &Client{URL: "tcp://" + ln.Addr().String(), Transport: BindingTransport(b)}
+		for _, v := range []string{"one", "two"} {
+			var out echoResponse
+			if err := c.Call(context.Background(), "Echo", echoRequest{Value: v}, &out); err != nil {
+				t.Fatalf("delimiter %q: %v", delim, err)
+			}
+			if out.Value != v {
+				t.Errorf("delimiter %q: got %q, want %q", delim, out.Value, v)
+			}
+		}
+		if n := conns.Load(); n != 1 {
+			t.Errorf("delimiter %q: %d connections opened", delim, n)
+		}
+		b.CloseIdleConnections()
+		ln.Close()
+	}
+}
+
+func TestTCPBindingOneWay(t *testing.T) {
+	b := new(TCPBinding)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	msgs := make(chan []byte, 2)
+	var conns atomic.Int32
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns.Add(1)
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					msg, err := b.readMessage(r)
+					if err != nil {
+						return
+					}
+					msgs <- msg
+				}
+			}()
+		}
+	}()
+
+	c := &Client{URL: "tcp://" + ln.Addr().String(), Transport: BindingTransport(b)}
+	defer b.CloseIdleConnections()
+	for _, v := range []string{"one", "two"} {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := c.Send(ctx, "Echo", echoRequest{Value: v})
+		cancel()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg := <-msgs; !bytes.Contains(msg, []byte(v)) {
+			t.Errorf("got message %s, want %s", msg, v)
+		}
+	}
+	if n := conns.Load(); n != 1 {
+		t.Errorf("%d connections opened", n)
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "soap.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ln.Close()
+	var path atomic.Value
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path.Store(r.URL.Path)
+		echoHandler(t)(w, r)
+	})}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	for _, tt := range []struct {
+		c    *Client
+		path string
+	}{
+		{&Client{URL: "unix://" + sock}, "/"},
+		{&Client{URL: "unix://" + sock + "/services/echo"}, "/services/echo"},
+		{&Client{
+			URL: "http://gateway/services/echo",
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", sock)
+			},
+		}, "/services/echo"},
+	} {
+		var out echoResponse
+		if err := tt.c.Call(context.Background(), "Echo", echoRequest{Value: "hi"}, &out); err != nil {
+			t.Fatalf("%s: %v", tt.c.URL, err)
+		}
+		if out.Value != "hi" {
+			t.Errorf("%s: got %q, want %q", tt.c.URL, out.Value, "hi")
+		}
+		if p := path.Load(); p != tt.path {
+			t.Errorf("%s: request sent to %v, want %s", tt.c.URL, p, tt.path)
+		}
+	}
+
+	var out echoResponse
+	err = (&Client{URL: "unix:///nonexistent/soap.sock"}).Call(context.Background(), "Echo", echoRequest{Value: "hi"}, &out)
+	if err == nil {
+		t.Error("call to missing socket succeeded")
+	}
+}
+
+// memQueue is a Queue whose queues are channels.
+type memQueue struct {
+	mu     sync.Mutex
+	queues map[string]chan *QueueMessage
+}
+
+func (q *memQueue) queue(name string) chan *QueueMessage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.queues == nil {
+		q.queues = make(map[string]chan *QueueMessage)
+	}
+	if q.queues[name] == nil {
+		q.queues[name] = make(chan *QueueMessage, 16)
+	}
+	return q.queues[name]
+}
+
+func (q *memQueue) Publish(ctx context.Context, msg *QueueMessage) error {
+	q.queue(msg.Queue) <- msg
+	return nil
+}
+
+func (q *memQueue) Receive(ctx context.Context, name string) (*QueueMessage, error) {
+	select {
+	case m := <-q.queue(name):
+		return m, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestQueueBinding(t *testing.T) {
+	q := new(memQueue)
+	var oneWay atomic.Int32
+	go func() {
+		for m := range q.queue("orders") {
+			if m.ReplyTo == "" {
+				oneWay.Add(1)
+				continue
+			}
+			rec := httptest.NewRecorder()
+			echoHandler(t)(rec, httptest.NewRequest("POST", "/", bytes.NewReader(m.Body)))
+			// replies are sent out of order
+			go func(m *QueueMessage, body []byte) {
+				time.Sleep(time.Duration(len(m.Body)%7) * time.Millisecond)
+				q.Publish(context.Background(), &QueueMessage{Queue: m.ReplyTo, CorrelationID: m.CorrelationID, Body: body})
+			}(m, rec.Body.Bytes())
+		}
+	}()
+
+	c := &Client{URL: "amqp://broker/orders", Transport: BindingTransport(&QueueBinding{Queue: q, ReplyTo: "replies"})}
+	var wg sync.WaitGroup
+	for _, v := range []string{"one", "two", "three", "four", "five"} {
+		wg.Add(1)
+		go func(v string) {
+			defer wg.Done()
+			var out echoResponse
+			if err := c.Call(context.Background(), "Echo", echoRequest{Value: v}, &out); err != nil {
+				t.Error(err)
+			} else if out.Value != v {
+				t.Errorf("got %q, want %q", out.Value, v)
+			}
+		}(v)
+	}
+	wg.Wait()
+	if err := c.Send(context.Background(), "Echo", echoRequest{Value: "one-way"}); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(time.Second); oneWay.Load() == 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if oneWay.Load() != 1 {
+		t.Error("one-way message not published")
+	}
+}
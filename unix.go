@@ -0,0 +1,97 @@
+package soap
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// defaultClient is used by Clients without an HTTPClient, Transport or
+// DialContext. It reaches endpoints with the unix scheme as well as
+// those reached by http.DefaultTransport.
+var defaultClient = &http.Client{Transport: &unixTransport{next: http.DefaultTransport}}
+
+// unixTransport sends requests for endpoints with the unix scheme over
+// HTTP on Unix domain sockets, and other requests through next. The
+// path of a unix URL is that of the socket, followed by the path of
+// the HTTP request, as in unix:///run/gateway.sock/services/Quote. The
+// socket is the shortest prefix of the path naming a socket file; if
+// the whole path names one, the request is sent to "/".
+type unixTransport struct {
+	next http.RoundTripper
+
+	mu      sync.Mutex
+	sockets map[string]*http.Transport
+}
+
+func (t *unixTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "unix" {
+		return t.next.RoundTrip(req)
+	}
+	socket, path, err := splitSocketPath(req.URL.Path)
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	u := *req.URL
+	u.Scheme, u.Host, u.Path, u.RawPath = "http", "localhost", path, ""
+	r := req.Clone(req.Context())
+	r.URL, r.Host = &u, "localhost"
+	return t.transport(socket).RoundTrip(r)
+}
+
+// transport returns the Transport keeping the connections to a
+// socket.
+func (t *unixTransport) transport(socket string) *http.Transport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if tr, ok := t.sockets[socket]; ok {
+		return tr
+	}
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.Proxy = nil
+	tr.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", socket)
+	}
+	if t.sockets == nil {
+		t.sockets = make(map[string]*http.Transport)
+	}
+	t.sockets[socket] = tr
+	return tr
+}
+
+func (t *unixTransport) CloseIdleConnections() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, tr := range t.sockets {
+		tr.CloseIdleConnections()
+	}
+	if ci, ok := t.next.(interface{ CloseIdleConnections() }); ok {
+		ci.CloseIdleConnections()
+	}
+}
+
+// splitSocketPath splits the path of a unix URL into the path of a
+// socket file and the path of the HTTP request.
+func splitSocketPath(p string) (socket, path string, err error) {
+	for i := 1; i <= len(p); i++ {
+		if i < len(p) && p[i] != '/' {
+			continue
+		}
+		if fi, err := os.Stat(p[:i]); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			path = p[i:]
+			if !strings.HasPrefix(path, "/") {
+				path = "/" + path
+			}
+			return p[:i], path, nil
+		}
+	}
+	return "", "", fmt.Errorf("soap: no Unix domain socket in path %s", p)
+}
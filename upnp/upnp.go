@@ -0,0 +1,86 @@
+// Package upnp provides helpers for UPnP control, the dialect of
+// SOAP used to invoke the actions of UPnP device services, such as
+// those of Internet gateways and media renderers.
+package upnp
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+
+	"aqwari.net/exp/soap"
+)
+
+// NsControl is the namespace of UPnP control errors.
+const NsControl = "urn:schemas-upnp-org:control-1-0"
+
+// A Service is a service of a UPnP device.
+type Service struct {
+	// Type is the service type, such as
+	// "urn:schemas-upnp-org:service:WANIPConnection:1".
+	Type string
+
+	// Client sends requests to the service. Its URL is the
+	// controlURL given in the device description.
+	Client *soap.Client
+}
+
+// NewService returns a Service of the given type, controlled at
+// controlURL.
+func NewService(serviceType, controlURL string) *Service {
+	return &Service{Type: serviceType, Client: &soap.Client{URL: controlURL}}
+}
+
+// An Error is a UPnP error returned by a service in the detail of
+// a Fault.
+type Error struct {
+	Code        int
+	Description string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("upnp: error %d: %s", e.Code, e.Description)
+}
+
+// action encodes an action request: an element named for the action
+// in the service type namespace, bound to the prefix "u" as UPnP
+// requires, whose children are the unqualified input arguments.
+type action struct {
+	service, name string
+	args          interface{}
+}
+
+func (a action) MarshalXML(e *xml.Encoder, _ xml.StartElement) error {
+	start := xml.StartElement{
+		Name: xml.Name{Local: "u:" + a.name},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns:u"}, Value: a.service}},
+	}
+	if a.args != nil {
+		return e.EncodeElement(a.args, start)
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// Call invokes an action of the service. The fields of in, a struct,
+// are sent as the input arguments of the action, in order, and the
+// output arguments are decoded into out, whose fields are matched by
+// name. Either may be nil. The SOAPACTION header is set to the
+// service type and action name, separated by "#". UPnP errors
+// returned by the service are of type *Error.
+func (s *Service) Call(ctx context.Context, name string, in, out interface{}) error {
+	req := action{service: s.Type, name: name, args: in}
+	err := s.Client.Call(ctx, s.Type+"#"+name, req, out, soap.WithEncodingStyle(soap.Encoding))
+	if f, ok := err.(*soap.Fault); ok {
+		var detail struct {
+			Code        int    `xml:"errorCode"`
+			Description string `xml:"errorDescription"`
+		}
+		if len(f.Detail) > 0 && xml.Unmarshal(f.Detail, &detail) == nil && detail.Code != 0 {
+			return &Error{Code: detail.Code, Description: detail.Description}
+		}
+	}
+	return err
+}
@@ -0,0 +1,67 @@
+package upnp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"aqwari.net/exp/soap"
+)
+
+const wanIP = "urn:schemas-upnp-org:service:WANIPConnection:1"
+
+func TestCall(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+		if !strings.Contains(string(data), `encodingStyle="`+soap.Encoding+`"`) {
+			t.Errorf("no encodingStyle: %s", data)
+		}
+		switch r.Header.Get("SOAPAction") {
+		case `"` + wanIP + `#GetExternalIPAddress"`:
+			if !strings.Contains(string(data), `<u:GetExternalIPAddress xmlns:u="`+wanIP+`"></u:GetExternalIPAddress>`) {
+				t.Errorf("unexpected request: %s", data)
+			}
+			io.WriteString(w, `<s:Envelope xmlns:s="`+soap.NsSoapEnv+`"><s:Body>
+<u:GetExternalIPAddressResponse xmlns:u="`+wanIP+`"><NewExternalIPAddress>192.0.2.7</NewExternalIPAddress></u:GetExternalIPAddressResponse>
+</s:Body></s:Envelope>`)
+		case `"` + wanIP + `#DeletePortMapping"`:
+			if !strings.Contains(string(data), `<NewExternalPort>8080</NewExternalPort><NewProtocol>TCP</NewProtocol>`) {
+				t.Errorf("unexpected arguments: %s", data)
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+			io.WriteString(w, `<s:Envelope xmlns:s="`+soap.NsSoapEnv+`"><s:Body><s:Fault>
+<faultcode>s:Client</faultcode><faultstring>UPnPError</faultstring>
+<detail><UPnPError xmlns="urn:schemas-upnp-org:control-1-0"><errorCode>714</errorCode><errorDescription>NoSuchEntryInArray</errorDescription></UPnPError></detail>
+</s:Fault></s:Body></s:Envelope>`)
+		default:
+			t.Errorf("unexpected SOAPAction %s", r.Header.Get("SOAPAction"))
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	s := NewService(wanIP, srv.URL)
+	var out struct {
+		IP string `xml:"NewExternalIPAddress"`
+	}
+	if err := s.Call(ctx, "GetExternalIPAddress", nil, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.IP != "192.0.2.7" {
+		t.Errorf("got address %q", out.IP)
+	}
+
+	in := struct {
+		RemoteHost   string `xml:"NewRemoteHost"`
+		ExternalPort int    `xml:"NewExternalPort"`
+		Protocol     string `xml:"NewProtocol"`
+	}{"", 8080, "TCP"}
+	err := s.Call(ctx, "DeletePortMapping", in, nil)
+	if e, ok := err.(*Error); !ok || e.Code != 714 {
+		t.Errorf("got %v, want UPnP error 714", err)
+	}
+}
@@ -0,0 +1,125 @@
+package soap
+
+import (
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// A Version is a version of the SOAP protocol.
+type Version int
+
+const (
+	V11 Version = iota // SOAP 1.1
+	V12                // SOAP 1.2
+)
+
+// envelopeNS returns the namespace of the SOAP Envelope.
+func (v Version) envelopeNS() string {
+	if v == V12 {
+		return NsSoap12Env
+	}
+	return NsSoapEnv
+}
+
+// prefix returns the prefix bound to the envelope namespace in
+// messages written by this package.
+func (v Version) prefix() string {
+	if v == V12 {
+		return "soap"
+	}
+	return "soapenv"
+}
+
+// accept returns the media types accepted in responses. Services
+// speaking SOAP 1.1 sometimes answer with the SOAP 1.2 media type,
+// so both are accepted.
+func (v Version) accept() string {
+	if v == V12 {
+		return "application/soap+xml, text/xml"
+	}
+	return "text/xml, application/soap+xml"
+}
+
+// checkMediaType returns an error if the Content-Type of an HTTP
+// response is not one used for SOAP messages of either version, or
+// multipart/related for messages wrapped in MIME. A missing
+// Content-Type is allowed.
+func checkMediaType(h http.Header) error {
+	ct := h.Get("Content-Type")
+	if ct == "" {
+		return nil
+	}
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return fmt.Errorf("soap: invalid response Content-Type %q: %v", ct, err)
+	}
+	switch mt {
+	case "text/xml", "application/soap+xml", "application/xml", "multipart/related":
+		return nil
+	}
+	return fmt.Errorf("soap: unexpected response Content-Type %q", ct)
+}
+
+// SetAction sets the SOAP action of an HTTP request. For SOAP 1.1,
+// the action is sent in the SOAPAction header, quoted as the
+// specification requires. For SOAP 1.2, it is sent as the action
+// parameter of an application/soap+xml Content-Type, and any
+// SOAPAction header is removed. The Content-Type is set for the
+// version, keeping its charset parameter, if any.
+func SetAction(req *http.Request, v Version, action string) {
+	charset := "utf-8"
+	if _, params, err := mime.ParseMediaType(req.Header.Get("Content-Type")); err == nil && params["charset"] != "" {
+		charset = params["charset"]
+	}
+	setAction(req, v, action, charset)
+}
+
+// setAction sets the SOAP action and Content-Type of an HTTP request.
+func setAction(req *http.Request, v Version, action, charset string) {
+	params := map[string]string{"charset": charset}
+	if v != V12 {
+		req.Header.Set("SOAPAction", `"`+action+`"`)
+		req.Header.Set("Content-Type", mime.FormatMediaType("text/xml", params))
+		return
+	}
+	req.Header.Del("SOAPAction")
+	if action != "" {
+		params["action"] = action
+	}
+	req.Header.Set("Content-Type", mime.FormatMediaType("application/soap+xml", params))
+}
+
+// A fault12 is a SOAP 1.2 Fault.
+type fault12 struct {
+	XMLName xml.Name `xml:"http://www.w3.org/2003/05/soap-envelope Fault"`
+	Code    struct {
+		Value   string `xml:"Value"`
+		Subcode struct {
+			Value string `xml:"Value"`
+		} `xml:"Subcode"`
+	} `xml:"Code"`
+	Reason []string `xml:"Reason>Text"`
+	Role   string   `xml:"Role"`
+	Detail struct {
+		Data []byte `xml:",innerxml"`
+	} `xml:"Detail"`
+}
+
+// fault converts a SOAP 1.2 fault to a Fault. Its Code is the value
+// of the fault's Code, followed by the value of its first Subcode,
+// if any, separated by a dot, as in SOAP 1.1.
+func (f *fault12) fault() *Fault {
+	code := f.Code.Value
+	if sub := f.Code.Subcode.Value; sub != "" {
+		code += "." + sub
+	}
+	return &Fault{
+		Code:   code,
+		String: strings.Join(f.Reason, "\n"),
+		Actor:  f.Role,
+		Detail: f.Detail.Data,
+	}
+}
@@ -0,0 +1,198 @@
+package vsphere
+
+import (
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"aqwari.net/exp/soap"
+)
+
+// A ManagedObjectReference identifies a managed object on the server,
+// such as a VirtualMachine or the PropertyCollector.
+type ManagedObjectReference struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+func (r ManagedObjectReference) String() string {
+	return r.Type + ":" + r.Value
+}
+
+// Arrays, as they appear in values of type AnyType.
+type (
+	ArrayOfString struct {
+		String []string `xml:"string"`
+	}
+	ArrayOfInt struct {
+		Int []int32 `xml:"int"`
+	}
+	ArrayOfLong struct {
+		Long []int64 `xml:"long"`
+	}
+	ArrayOfManagedObjectReference struct {
+		ManagedObjectReference []ManagedObjectReference `xml:"ManagedObjectReference"`
+	}
+)
+
+// xsdTypes are the types named by XML Schema, whose xsi:type values
+// carry the xsd prefix.
+var xsdTypes = map[string]reflect.Type{
+	"string":   reflect.TypeOf(""),
+	"boolean":  reflect.TypeOf(false),
+	"byte":     reflect.TypeOf(int8(0)),
+	"short":    reflect.TypeOf(int16(0)),
+	"int":      reflect.TypeOf(int32(0)),
+	"long":     reflect.TypeOf(int64(0)),
+	"float":    reflect.TypeOf(float32(0)),
+	"double":   reflect.TypeOf(float64(0)),
+	"dateTime": reflect.TypeOf(time.Time{}),
+}
+
+var (
+	typesMu sync.RWMutex
+	types   = map[string]reflect.Type{
+		"ManagedObjectReference":        reflect.TypeOf(ManagedObjectReference{}),
+		"ArrayOfString":                 reflect.TypeOf(ArrayOfString{}),
+		"ArrayOfInt":                    reflect.TypeOf(ArrayOfInt{}),
+		"ArrayOfLong":                   reflect.TypeOf(ArrayOfLong{}),
+		"ArrayOfManagedObjectReference": reflect.TypeOf(ArrayOfManagedObjectReference{}),
+	}
+)
+
+// RegisterType registers the Go type of v as the type decoded for
+// values of AnyType whose xsi:type is name, such as
+// "VirtualMachineConfigSummary". It panics if name is already
+// registered.
+func RegisterType(name string, v interface{}) {
+	typesMu.Lock()
+	defer typesMu.Unlock()
+	if _, ok := types[name]; ok {
+		panic("vsphere: type " + name + " registered twice")
+	}
+	types[name] = reflect.TypeOf(v)
+}
+
+func lookupType(name string) (reflect.Type, bool) {
+	if t, ok := xsdTypes[name]; ok {
+		return t, true
+	}
+	typesMu.RLock()
+	defer typesMu.RUnlock()
+	t, ok := types[name]
+	return t, ok
+}
+
+func typeName(t reflect.Type) (name string, xsd bool) {
+	for name, u := range xsdTypes {
+		if u == t {
+			return name, true
+		}
+	}
+	typesMu.RLock()
+	defer typesMu.RUnlock()
+	for name, u := range types {
+		if u == t {
+			return name, false
+		}
+	}
+	return "", false
+}
+
+// An AnyType holds a value whose type is given by its xsi:type
+// attribute, as vSphere uses for the values of properties and for
+// polymorphic fields such as the selectSet of a PropertyFilterSpec.
+type AnyType struct {
+	// Type is the name of the value's type, without prefix, such
+	// as "string" or "ArrayOfManagedObjectReference".
+	Type string
+
+	// Value is the value, of the Go type registered for Type. If
+	// Type is not registered, it holds the element's inner XML as
+	// a []byte.
+	Value interface{}
+}
+
+// UnmarshalXML decodes a value according to its xsi:type attribute.
+func (a *AnyType) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	a.Type = xsiType(start)
+	t, ok := lookupType(a.Type)
+	if !ok {
+		var raw struct {
+			Inner []byte `xml:",innerxml"`
+		}
+		if err := d.DecodeElement(&raw, &start); err != nil {
+			return err
+		}
+		a.Value = raw.Inner
+		return nil
+	}
+	v := reflect.New(t)
+	if err := d.DecodeElement(v.Interface(), &start); err != nil {
+		return err
+	}
+	a.Value = v.Elem().Interface()
+	return nil
+}
+
+// xsiType returns the xsi:type of an element, without its prefix.
+// vSphere uses both the xsd prefix and the default namespace in the
+// values of xsi:type, so the prefix is not resolved.
+func xsiType(start xml.StartElement) string {
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "type" && (attr.Name.Space == soap.NsXSI || attr.Name.Space == "xsi") {
+			if i := strings.Index(attr.Value, ":"); i >= 0 {
+				return attr.Value[i+1:]
+			}
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// MarshalXML encodes a value with an xsi:type attribute naming its
+// type. If a.Type is empty, the name registered for the Go type of
+// a.Value is used.
+func (a AnyType) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	name, xsd := a.Type, false
+	if _, ok := xsdTypes[name]; ok {
+		xsd = true
+	}
+	if name == "" {
+		name, xsd = typeName(reflect.TypeOf(a.Value))
+		if name == "" {
+			return fmt.Errorf("vsphere: no type registered for %T", a.Value)
+		}
+	}
+	// The attribute value is a QName, so the prefixes it uses are
+	// declared explicitly rather than left to the encoder.
+	start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "xmlns:xsi"}, Value: soap.NsXSI})
+	if xsd {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "xmlns:xsd"}, Value: soap.NsXSD})
+		name = "xsd:" + name
+	}
+	start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "xsi:type"}, Value: name})
+	return e.EncodeElement(a.Value, start)
+}
+
+// FaultType returns the type of the MethodFault in the detail of a
+// fault returned by the server, such as "InvalidLogin" or
+// "ManagedObjectNotFound", or the empty string if there is none.
+func FaultType(f *soap.Fault) string {
+	d := xml.NewDecoder(strings.NewReader(string(f.Detail)))
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return ""
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			if t := xsiType(start); t != "" {
+				return t
+			}
+			return strings.TrimSuffix(start.Name.Local, "Fault")
+		}
+	}
+}
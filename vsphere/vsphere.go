@@ -0,0 +1,146 @@
+// Package vsphere provides helpers for the VMware vSphere Web Services
+// API: a client configured for the server's conventions, the
+// ManagedObjectReference type identifying server objects, and the
+// AnyType wrapper that decodes values polymorphic through xsi:type.
+package vsphere
+
+import (
+	"context"
+	"encoding/xml"
+
+	"aqwari.net/exp/soap"
+)
+
+// NsVim25 is the namespace of the vSphere API.
+const NsVim25 = "urn:vim25"
+
+// Profile is the soap.Profile of vSphere servers. Their messages are
+// document/literal, so no elements are dropped when flattening them.
+var Profile = &soap.Profile{Name: "vsphere", Flattener: &soap.Flattener{}}
+
+// ServiceInstance is the root object of the server's inventory.
+var ServiceInstance = ManagedObjectReference{Type: "ServiceInstance", Value: "ServiceInstance"}
+
+// A Client calls methods of a vSphere server.
+type Client struct {
+	// SOAP sends requests. Its URL is the address of the SDK,
+	// such as "https://vcenter.example.com/sdk".
+	SOAP *soap.Client
+
+	// Version is the API version requested, such as "8.0.1.0".
+	// The server uses it to choose the types and properties it
+	// returns, and rejects versions it does not support.
+	Version string
+
+	// ServiceContent is retrieved by Login, and holds the
+	// references to the server's managers.
+	ServiceContent ServiceContent
+}
+
+// NewClient returns a Client for the SDK at url, requesting the given
+// API version. The server identifies the session by a cookie, so the
+// SOAP client keeps one.
+func NewClient(url, version string) *Client {
+	return &Client{
+		SOAP:    &soap.Client{URL: url, Profile: Profile, Session: soap.NewSession()},
+		Version: version,
+	}
+}
+
+// Call invokes a method. req is the method's request element, in the
+// NsVim25 namespace, whose _this field references the object on which
+// the method is invoked. The server identifies the API version from
+// the SOAPAction, "urn:vim25/" followed by c.Version.
+func (c *Client) Call(ctx context.Context, req, resp interface{}) error {
+	return c.SOAP.Call(ctx, NsVim25+"/"+c.Version, req, resp)
+}
+
+// ServiceContent holds the references to the managers of a server,
+// and a description of the server.
+type ServiceContent struct {
+	RootFolder        ManagedObjectReference `xml:"rootFolder"`
+	PropertyCollector ManagedObjectReference `xml:"propertyCollector"`
+	ViewManager       ManagedObjectReference `xml:"viewManager"`
+	SessionManager    ManagedObjectReference `xml:"sessionManager"`
+	About             struct {
+		FullName   string `xml:"fullName"`
+		APIType    string `xml:"apiType"`
+		APIVersion string `xml:"apiVersion"`
+	} `xml:"about"`
+}
+
+type retrieveServiceContent struct {
+	XMLName xml.Name               `xml:"urn:vim25 RetrieveServiceContent"`
+	This    ManagedObjectReference `xml:"urn:vim25 _this"`
+}
+
+type login struct {
+	XMLName  xml.Name               `xml:"urn:vim25 Login"`
+	This     ManagedObjectReference `xml:"urn:vim25 _this"`
+	UserName string                 `xml:"urn:vim25 userName"`
+	Password string                 `xml:"urn:vim25 password"`
+}
+
+// Login retrieves the ServiceContent of the server and logs in with
+// the given credentials, starting a session kept for later calls.
+func (c *Client) Login(ctx context.Context, username, password string) error {
+	var sc struct {
+		Returnval ServiceContent `xml:"returnval"`
+	}
+	if err := c.Call(ctx, retrieveServiceContent{This: ServiceInstance}, &sc); err != nil {
+		return err
+	}
+	c.ServiceContent = sc.Returnval
+	req := login{This: sc.Returnval.SessionManager, UserName: username, Password: password}
+	return c.Call(ctx, req, nil)
+}
+
+// ObjectContent holds properties of a managed object, as returned by
+// the PropertyCollector.
+type ObjectContent struct {
+	Obj     ManagedObjectReference `xml:"obj"`
+	PropSet []DynamicProperty      `xml:"propSet"`
+}
+
+// A DynamicProperty is a property of a managed object.
+type DynamicProperty struct {
+	Name string  `xml:"name"`
+	Val  AnyType `xml:"val"`
+}
+
+type retrieveProperties struct {
+	XMLName xml.Name               `xml:"urn:vim25 RetrievePropertiesEx"`
+	This    ManagedObjectReference `xml:"urn:vim25 _this"`
+	SpecSet struct {
+		PropSet struct {
+			Type    string   `xml:"urn:vim25 type"`
+			PathSet []string `xml:"urn:vim25 pathSet"`
+		} `xml:"urn:vim25 propSet"`
+		ObjectSet struct {
+			Obj ManagedObjectReference `xml:"urn:vim25 obj"`
+		} `xml:"urn:vim25 objectSet"`
+	} `xml:"urn:vim25 specSet"`
+	Options struct{} `xml:"urn:vim25 options"`
+}
+
+// RetrieveProperties returns the named properties of a managed object,
+// such as "name" and "runtime.powerState" of a VirtualMachine, using
+// the PropertyCollector. Login must be called first.
+func (c *Client) RetrieveProperties(ctx context.Context, obj ManagedObjectReference, paths ...string) ([]DynamicProperty, error) {
+	var req retrieveProperties
+	req.This = c.ServiceContent.PropertyCollector
+	req.SpecSet.PropSet.Type = obj.Type
+	req.SpecSet.PropSet.PathSet = paths
+	req.SpecSet.ObjectSet.Obj = obj
+	var resp struct {
+		Objects []ObjectContent `xml:"returnval>objects"`
+	}
+	if err := c.Call(ctx, req, &resp); err != nil {
+		return nil, err
+	}
+	var props []DynamicProperty
+	for _, o := range resp.Objects {
+		props = append(props, o.PropSet...)
+	}
+	return props, nil
+}
@@ -0,0 +1,134 @@
+package vsphere
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"aqwari.net/exp/soap"
+)
+
+func TestClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		if action := r.Header.Get("SOAPAction"); action != `"urn:vim25/8.0.1.0"` {
+			t.Errorf("SOAPAction %s", action)
+		}
+		var msg struct {
+			Body struct {
+				Content *struct{} `xml:"urn:vim25 RetrieveServiceContent"`
+				Login   *struct {
+					This     ManagedObjectReference `xml:"_this"`
+					Password string                 `xml:"password"`
+				} `xml:"urn:vim25 Login"`
+				Retrieve *struct {
+					Paths []string `xml:"specSet>propSet>pathSet"`
+				} `xml:"urn:vim25 RetrievePropertiesEx"`
+			}
+		}
+		if err := xml.Unmarshal(data, &msg); err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+		var body string
+		switch b := msg.Body; {
+		case b.Content != nil:
+			body = `<RetrieveServiceContentResponse xmlns="urn:vim25"><returnval>
+<rootFolder type="Folder">group-d1</rootFolder>
+<propertyCollector type="PropertyCollector">propertyCollector</propertyCollector>
+<sessionManager type="SessionManager">SessionManager</sessionManager>
+<about><fullName>VMware vCenter Server 8.0.1</fullName><apiVersion>8.0.1.0</apiVersion></about>
+</returnval></RetrieveServiceContentResponse>`
+		case b.Login != nil:
+			if b.Login.This.Value != "SessionManager" {
+				t.Errorf("Login invoked on %s", b.Login.This)
+			}
+			if b.Login.Password != "secret" {
+				w.WriteHeader(http.StatusInternalServerError)
+				body = `<soapenv:Fault><faultcode>ServerFaultCode</faultcode><faultstring>Cannot complete login</faultstring>
+<detail><InvalidLoginFault xmlns="urn:vim25" xsi:type="InvalidLogin"></InvalidLoginFault></detail></soapenv:Fault>`
+				break
+			}
+			http.SetCookie(w, &http.Cookie{Name: "vmware_soap_session", Value: "abc"})
+			body = `<LoginResponse xmlns="urn:vim25"><returnval><userName>root</userName></returnval></LoginResponse>`
+		case b.Retrieve != nil:
+			if c, err := r.Cookie("vmware_soap_session"); err != nil || c.Value != "abc" {
+				t.Error("session cookie not sent")
+			}
+			body = `<RetrievePropertiesExResponse xmlns="urn:vim25"><returnval><objects>
+<obj type="VirtualMachine">vm-42</obj>
+<propSet><name>name</name><val xsi:type="xsd:string">web01</val></propSet>
+<propSet><name>datastore</name><val xsi:type="ArrayOfManagedObjectReference">
+<ManagedObjectReference type="Datastore">datastore-1</ManagedObjectReference>
+<ManagedObjectReference type="Datastore">datastore-2</ManagedObjectReference></val></propSet>
+<propSet><name>guest</name><val xsi:type="GuestInfo"><toolsStatus>toolsOk</toolsStatus></val></propSet>
+</objects></returnval></RetrievePropertiesExResponse>`
+		default:
+			t.Errorf("unexpected request %s", data)
+		}
+		fmt.Fprintf(w, `<soapenv:Envelope xmlns:soapenv="%s" xmlns:xsd="%s" xmlns:xsi="%s"><soapenv:Body>%s</soapenv:Body></soapenv:Envelope>`,
+			soap.NsSoapEnv, soap.NsXSD, soap.NsXSI, body)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	c := NewClient(srv.URL+"/sdk", "8.0.1.0")
+	err := c.Login(ctx, "root", "wrong")
+	if f, ok := err.(*soap.Fault); !ok || FaultType(f) != "InvalidLogin" {
+		t.Fatalf("got error %v", err)
+	}
+	if err := c.Login(ctx, "root", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if c.ServiceContent.PropertyCollector.Value != "propertyCollector" {
+		t.Errorf("got service content %+v", c.ServiceContent)
+	}
+
+	vm := ManagedObjectReference{Type: "VirtualMachine", Value: "vm-42"}
+	props, err := c.RetrieveProperties(ctx, vm, "name", "datastore", "guest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(props) != 3 {
+		t.Fatalf("got %d properties", len(props))
+	}
+	if props[0].Val.Value != "web01" {
+		t.Errorf("name = %#v", props[0].Val)
+	}
+	ds, ok := props[1].Val.Value.(ArrayOfManagedObjectReference)
+	if !ok || len(ds.ManagedObjectReference) != 2 || ds.ManagedObjectReference[1].Value != "datastore-2" {
+		t.Errorf("datastore = %#v", props[1].Val)
+	}
+	if raw, ok := props[2].Val.Value.([]byte); props[2].Val.Type != "GuestInfo" || !ok || !strings.Contains(string(raw), "toolsOk") {
+		t.Errorf("guest = %#v", props[2].Val)
+	}
+}
+
+func TestAnyType(t *testing.T) {
+	for _, v := range []AnyType{
+		{Value: int32(7)},
+		{Value: ArrayOfString{String: []string{"a", "b"}}},
+		{Value: ManagedObjectReference{Type: "HostSystem", Value: "host-9"}},
+	} {
+		type prop struct {
+			Val AnyType `xml:"urn:vim25 val"`
+		}
+		data, err := xml.Marshal(prop{v})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got prop
+		if err := xml.Unmarshal(data, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got.Val.Value, v.Value) {
+			t.Errorf("%s decoded as %#v", data, got.Val)
+		}
+	}
+}
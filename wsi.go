@@ -0,0 +1,269 @@
+package soap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// A Violation is a failure of a message to meet a requirement of the
+// WS-I Basic Profile 1.1.
+type Violation struct {
+	// Rule identifies the requirement, such as "R1014", or the
+	// section of SOAP 1.1 whose requirement the profile includes.
+	Rule string
+
+	// Message describes the failure.
+	Message string
+}
+
+func (v Violation) String() string {
+	return v.Rule + ": " + v.Message
+}
+
+// CheckEnvelope checks a SOAP 1.1 message against the requirements
+// of the WS-I Basic Profile 1.1 on envelopes, and returns those it
+// violates. A message that cannot be parsed is reported as such.
+func CheckEnvelope(data []byte) []Violation {
+	var vs []Violation
+	report := func(rule, format string, args ...interface{}) {
+		vs = append(vs, Violation{Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.CharsetReader = func(_ string, r io.Reader) (io.Reader, error) {
+		return r, nil // the encoding is checked below
+	}
+	var stack []xml.Name
+	afterBody, inFault := false, false
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			report("SOAP 1.1 §4", "message is not well-formed: %v", err)
+			return vs
+		}
+		switch tok := tok.(type) {
+		case xml.Directive:
+			if bytes.HasPrefix(bytes.TrimSpace(tok), []byte("DOCTYPE")) {
+				report("R1008", "message contains a Document Type Declaration")
+			}
+		case xml.ProcInst:
+			if tok.Target == "xml" {
+				if enc := procInstEncoding(tok.Inst); enc != "" && !isUTF(enc) {
+					report("R1012", "message is encoded in %s", enc)
+				}
+			} else {
+				report("R1009", "message contains the processing instruction %s", tok.Target)
+			}
+		case xml.StartElement:
+			depth := len(stack)
+			stack = append(stack, tok.Name)
+			switch {
+			case depth == 0:
+				if tok.Name != (xml.Name{Space: NsSoapEnv, Local: "Envelope"}) {
+					report("SOAP 1.1 §4.1", "root element is {%s}%s, not a SOAP 1.1 Envelope", tok.Name.Space, tok.Name.Local)
+					return vs
+				}
+			case depth == 1 && afterBody:
+				report("R1011", "element %s follows the Body", tok.Name.Local)
+			case depth == 1 && tok.Name.Space == NsSoapEnv && tok.Name.Local == "Body":
+				afterBody = true
+			case depth == 2 && isBody(stack[1]):
+				if tok.Name.Space == "" {
+					report("R1014", "Body entry %s is not namespace qualified", tok.Name.Local)
+				}
+				inFault = tok.Name == xml.Name{Space: NsSoapEnv, Local: "Fault"}
+			case depth == 3 && inFault:
+				switch {
+				case tok.Name.Space != "":
+					report("R1001", "Fault child {%s}%s is qualified", tok.Name.Space, tok.Name.Local)
+				case tok.Name.Local == "faultcode":
+					var code string
+					if err := d.DecodeElement(&code, &tok); err != nil {
+						report("SOAP 1.1 §4", "message is not well-formed: %v", err)
+						return vs
+					}
+					stack = stack[:depth]
+					if !isFaultCode(code) {
+						report("R1004", "faultcode %q is neither a SOAP 1.1 fault code nor qualified", code)
+					}
+				case tok.Name.Local != "faultstring" && tok.Name.Local != "faultactor" && tok.Name.Local != "detail":
+					report("R1000", "Fault has child element %s", tok.Name.Local)
+				}
+			}
+			for _, a := range tok.Attr {
+				if a.Name.Space != NsSoapEnv {
+					continue
+				}
+				switch {
+				case a.Name.Local == "encodingStyle" && tok.Name.Space == NsSoapEnv:
+					report("R1005", "%s element has an encodingStyle attribute", tok.Name.Local)
+				case a.Name.Local == "encodingStyle" && depth == 2 && isBody(stack[1]):
+					report("R1006", "Body entry %s has an encodingStyle attribute", tok.Name.Local)
+				case a.Name.Local == "encodingStyle" && depth > 2 && isBody(stack[1]):
+					report("R1007", "element %s within the Body has an encodingStyle attribute", tok.Name.Local)
+				case a.Name.Local == "mustUnderstand" && a.Value != "0" && a.Value != "1":
+					report("R1013", "mustUnderstand attribute of %s is %q", tok.Name.Local, a.Value)
+				}
+			}
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+	if !afterBody {
+		report("SOAP 1.1 §4.3", "Envelope has no Body")
+	}
+	return vs
+}
+
+// CheckRequest checks an HTTP request carrying a SOAP 1.1 message
+// against the requirements of the WS-I Basic Profile 1.1 on requests
+// and envelopes, and returns those it violates. The request body is
+// read and replaced, so that the request can still be sent or served.
+func CheckRequest(req *http.Request) ([]Violation, error) {
+	var vs []Violation
+	if req.Method != http.MethodPost {
+		vs = append(vs, Violation{"R1132", "request method is " + req.Method})
+	}
+	vs = append(vs, checkProto(req.ProtoMajor, req.ProtoMinor)...)
+	if a, ok := req.Header["Soapaction"]; !ok {
+		vs = append(vs, Violation{"R2744", "request has no SOAPAction header"})
+	} else if len(a) != 1 || len(a[0]) < 2 || a[0][0] != '"' || a[0][len(a[0])-1] != '"' {
+		vs = append(vs, Violation{"R1109", fmt.Sprintf("SOAPAction %q is not a quoted string", strings.Join(a, ", "))})
+	}
+	data, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	vs = append(vs, checkMessageHeaders(req.Header)...)
+	return append(vs, checkBody(req.Header, data)...), nil
+}
+
+// CheckResponse checks an HTTP response carrying a SOAP 1.1 message
+// against the requirements of the WS-I Basic Profile 1.1 on responses
+// and envelopes, and returns those it violates. The response body is
+// read and replaced, so that the response can still be parsed.
+func CheckResponse(resp *http.Response) ([]Violation, error) {
+	vs := checkProto(resp.ProtoMajor, resp.ProtoMinor)
+	data, err := readBody(&resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return vs, nil
+	}
+	vs = append(vs, checkMessageHeaders(resp.Header)...)
+	msg, err := decodeBody(resp.Header, data)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := checkFault(msg).(*Fault); ok {
+		if resp.StatusCode != http.StatusInternalServerError {
+			vs = append(vs, Violation{"R1126", fmt.Sprintf("Fault returned with status %d", resp.StatusCode)})
+		}
+	} else if resp.StatusCode/100 != 2 {
+		vs = append(vs, Violation{"R1124", fmt.Sprintf("envelope returned with status %d", resp.StatusCode)})
+	} else if resp.StatusCode != http.StatusOK {
+		vs = append(vs, Violation{"R1111", fmt.Sprintf("envelope returned with status %d rather than 200", resp.StatusCode)})
+	}
+	return append(vs, CheckEnvelope(msg)...), nil
+}
+
+func checkProto(major, minor int) []Violation {
+	if major == 0 {
+		return nil // not set, as in requests being built
+	}
+	if major != 1 {
+		return []Violation{{"R1141", fmt.Sprintf("message is sent with HTTP/%d.%d", major, minor)}}
+	}
+	if minor != 1 {
+		return []Violation{{"R1140", fmt.Sprintf("message is sent with HTTP/%d.%d rather than HTTP/1.1", major, minor)}}
+	}
+	return nil
+}
+
+// checkMessageHeaders checks the media type and character set of a
+// message.
+func checkMessageHeaders(h http.Header) []Violation {
+	mt, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		return []Violation{{"SOAP 1.1 §6.1", fmt.Sprintf("invalid Content-Type %q", h.Get("Content-Type"))}}
+	}
+	var vs []Violation
+	if mt != "text/xml" {
+		vs = append(vs, Violation{"SOAP 1.1 §6.1", "Content-Type is " + mt + ", not text/xml"})
+	}
+	if cs := params["charset"]; cs != "" && !isUTF(cs) {
+		vs = append(vs, Violation{"R1012", "message is encoded in " + cs})
+	}
+	return vs
+}
+
+func checkBody(h http.Header, data []byte) []Violation {
+	msg, err := decodeBody(h, data)
+	if err != nil {
+		return []Violation{{"SOAP 1.1 §6.1", err.Error()}}
+	}
+	return CheckEnvelope(msg)
+}
+
+// readBody reads a message body, replacing it with a reader of the
+// same content.
+func readBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	data, err := io.ReadAll(*body)
+	(*body).Close()
+	*body = io.NopCloser(bytes.NewReader(data))
+	return data, err
+}
+
+// decodeBody returns a message body without its Content-Encoding.
+func decodeBody(h http.Header, data []byte) ([]byte, error) {
+	r, err := decodedBody(h, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func isBody(name xml.Name) bool {
+	return name.Space == NsSoapEnv && name.Local == "Body"
+}
+
+func isUTF(charset string) bool {
+	cs := strings.ToLower(charset)
+	return cs == "utf-8" || cs == "utf-16" || cs == "utf-16le" || cs == "utf-16be"
+}
+
+// isFaultCode reports whether code is qualified, as the fault codes
+// of SOAP 1.1 are.
+func isFaultCode(code string) bool {
+	prefix, local, ok := strings.Cut(strings.TrimSpace(code), ":")
+	return ok && prefix != "" && local != ""
+}
+
+// procInstEncoding returns the encoding declared by an XML
+// declaration, if any.
+func procInstEncoding(inst []byte) string {
+	s := string(inst)
+	i := strings.Index(s, "encoding=")
+	if i < 0 || len(s) < i+10 {
+		return ""
+	}
+	s = s[i+9:]
+	q := s[0]
+	if j := strings.IndexByte(s[1:], q); j >= 0 {
+		return s[1 : j+1]
+	}
+	return ""
+}
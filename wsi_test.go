@@ -0,0 +1,105 @@
+package soap
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func violationRules(vs []Violation) string {
+	rules := make([]string, len(vs))
+	for i, v := range vs {
+		rules[i] = v.Rule
+	}
+	return strings.Join(rules, " ")
+}
+
+func TestCheckEnvelope(t *testing.T) {
+	const env = `<s:Envelope xmlns:s="` + NsSoapEnv + `" xmlns:m="urn:example">`
+	tests := []struct {
+		msg, rules string
+	}{
+		{env + `<s:Body><m:Echo>hi</m:Echo></s:Body></s:Envelope>`, ""},
+		{`<?xml version="1.0" encoding="UTF-8"?>` + env + `<s:Body><m:Echo/></s:Body></s:Envelope>`, ""},
+		{`<?xml version="1.0" encoding="ISO-8859-1"?>` + env + `<s:Body><m:Echo/></s:Body></s:Envelope>`, "R1012"},
+		{`<!DOCTYPE Envelope>` + env + `<s:Body><m:Echo/></s:Body></s:Envelope>`, "R1008"},
+		{env + `<?app data?><s:Body><m:Echo/></s:Body></s:Envelope>`, "R1009"},
+		{env + `<s:Body><m:Echo/></s:Body><m:Trailer/></s:Envelope>`, "R1011"},
+		{env + `<s:Body><Echo/></s:Body></s:Envelope>`, "R1014"},
+		{`<s:Envelope xmlns:s="` + NsSoapEnv + `" s:encodingStyle="x"><s:Body/></s:Envelope>`, "R1005"},
+		{env + `<s:Body><m:Echo s:encodingStyle="x"><a s:encodingStyle="x"/></m:Echo></s:Body></s:Envelope>`, "R1006 R1007"},
+		{env + `<s:Header><m:Id s:mustUnderstand="true"/></s:Header><s:Body/></s:Envelope>`, "R1013"},
+		{env + `<s:Body><s:Fault><faultcode>s:Client</faultcode><faultstring>bad</faultstring></s:Fault></s:Body></s:Envelope>`, ""},
+		{env + `<s:Body><s:Fault><faultcode>Client</faultcode><m:faultstring>bad</m:faultstring><reason/></s:Fault></s:Body></s:Envelope>`, "R1004 R1001 R1000"},
+		{`<Envelope><Body/></Envelope>`, "SOAP 1.1 §4.1"},
+		{env + `</s:Envelope>`, "SOAP 1.1 §4.3"},
+	}
+	for _, tt := range tests {
+		if rules := violationRules(CheckEnvelope([]byte(tt.msg))); rules != tt.rules {
+			t.Errorf("CheckEnvelope(%s) violates %q, want %q", tt.msg, rules, tt.rules)
+		}
+	}
+}
+
+func TestCheckRequest(t *testing.T) {
+	const msg = `<s:Envelope xmlns:s="` + NsSoapEnv + `"><s:Body><m:Echo xmlns:m="urn:example"/></s:Body></s:Envelope>`
+	req := httptest.NewRequest("POST", "/", strings.NewReader(msg))
+	req.Header.Set("Content-Type", "text/xml; charset=utf-8")
+	req.Header.Set("SOAPAction", `"urn:example#Echo"`)
+	vs, err := CheckRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vs) != 0 {
+		t.Errorf("conforming request violates %v", vs)
+	}
+	if body, _ := io.ReadAll(req.Body); string(body) != msg {
+		t.Errorf("request body is %q after checking, want %q", body, msg)
+	}
+
+	req = httptest.NewRequest("PUT", "/", strings.NewReader(msg))
+	req.Header.Set("Content-Type", "application/soap+xml; charset=iso-8859-1")
+	req.Header.Set("SOAPAction", "urn:example#Echo")
+	if vs, err = CheckRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	if rules, want := violationRules(vs), "R1132 R1109 SOAP 1.1 §6.1 R1012"; rules != want {
+		t.Errorf("request violates %q, want %q", rules, want)
+	}
+}
+
+func TestCheckResponse(t *testing.T) {
+	const fault = `<s:Envelope xmlns:s="` + NsSoapEnv + `"><s:Body><s:Fault>` +
+		`<faultcode>s:Server</faultcode><faultstring>oops</faultstring></s:Fault></s:Body></s:Envelope>`
+	const reply = `<s:Envelope xmlns:s="` + NsSoapEnv + `"><s:Body><m:EchoResponse xmlns:m="urn:example"/></s:Body></s:Envelope>`
+	tests := []struct {
+		status int
+		msg    string
+		rules  string
+	}{
+		{http.StatusOK, reply, ""},
+		{http.StatusAccepted, reply, "R1111"},
+		{http.StatusBadRequest, reply, "R1124"},
+		{http.StatusInternalServerError, fault, ""},
+		{http.StatusOK, fault, "R1126"},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{
+			StatusCode: tt.status,
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     http.Header{"Content-Type": {"text/xml"}},
+			Body:       io.NopCloser(bytes.NewReader([]byte(tt.msg))),
+		}
+		vs, err := CheckResponse(resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rules := violationRules(vs); rules != tt.rules {
+			t.Errorf("status %d response violates %q, want %q", tt.status, rules, tt.rules)
+		}
+	}
+}
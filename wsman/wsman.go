@@ -0,0 +1,192 @@
+// Package wsman implements a client for DMTF WS-Management, the
+// protocol behind Windows Remote Management (WinRM). Requests are
+// sent with a soap.Client, so its authentication, retry and
+// transport settings apply; WinRM services typically require
+// NTLMTransport or NegotiateTransport.
+package wsman
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"time"
+
+	"aqwari.net/exp/soap"
+)
+
+const (
+	// NsWSMan is the namespace of WS-Management 1.x.
+	NsWSMan = "http://schemas.dmtf.org/wbem/wsman/1/wsman.xsd"
+
+	// NsTransfer is the namespace of WS-Transfer, whose actions
+	// are used for Get and Put.
+	NsTransfer = "http://schemas.xmlsoap.org/ws/2004/09/transfer"
+)
+
+// A Client makes WS-Management requests to a service.
+type Client struct {
+	// SOAP is used to send requests. WS-Management services
+	// expect SOAP 1.2.
+	SOAP *soap.Client
+
+	// OperationTimeout, if non-zero, is the time the service
+	// may take to complete an operation before faulting.
+	OperationTimeout time.Duration
+
+	// MaxEnvelopeSize, if non-zero, is the maximum size in bytes
+	// of the responses the service may send.
+	MaxEnvelopeSize int
+
+	// Locale, if not empty, is the language requested for
+	// messages in responses, such as "en-US".
+	Locale string
+}
+
+// NewClient returns a Client for the service at url, such as
+// "https://host:5986/wsman", speaking SOAP 1.2.
+func NewClient(url string) *Client {
+	return &Client{SOAP: &soap.Client{URL: url, Version: soap.V12}}
+}
+
+// Selectors identify an instance of a resource, by the values of
+// its keys.
+type Selectors map[string]string
+
+type text struct {
+	XMLName        xml.Name
+	MustUnderstand *xml.Attr `xml:",any,attr"`
+	Value          string    `xml:",chardata"`
+}
+
+type selectorSet struct {
+	XMLName  xml.Name   `xml:"http://schemas.dmtf.org/wbem/wsman/1/wsman.xsd SelectorSet"`
+	Selector []selector `xml:"http://schemas.dmtf.org/wbem/wsman/1/wsman.xsd Selector"`
+}
+
+type selector struct {
+	Name  string `xml:"Name,attr"`
+	Value string `xml:",chardata"`
+}
+
+type locale struct {
+	XMLName        xml.Name `xml:"http://schemas.dmtf.org/wbem/wsman/1/wsman.xsd Locale"`
+	MustUnderstand xml.Attr `xml:",any,attr"`
+	Lang           string   `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+}
+
+// header returns the WS-Management SOAP Header entries of a request.
+func (c *Client) header(resourceURI string, sel Selectors) []interface{} {
+	envNS := soap.NsSoapEnv
+	if c.SOAP.Version == soap.V12 {
+		envNS = soap.NsSoap12Env
+	}
+	mustUnderstand := func(v bool) xml.Attr {
+		value := "false"
+		if v {
+			value = "true"
+		}
+		return xml.Attr{Name: xml.Name{Space: envNS, Local: "mustUnderstand"}, Value: value}
+	}
+	mu := mustUnderstand(true)
+	h := []interface{}{text{xml.Name{Space: NsWSMan, Local: "ResourceURI"}, &mu, resourceURI}}
+	if len(sel) > 0 {
+		var set selectorSet
+		for name, value := range sel {
+			set.Selector = append(set.Selector, selector{name, value})
+		}
+		sort.Slice(set.Selector, func(i, j int) bool { return set.Selector[i].Name < set.Selector[j].Name })
+		h = append(h, set)
+	}
+	if c.OperationTimeout > 0 {
+		secs := c.OperationTimeout.Seconds()
+		h = append(h, text{XMLName: xml.Name{Space: NsWSMan, Local: "OperationTimeout"}, Value: fmt.Sprintf("PT%.3fS", secs)})
+	}
+	if c.MaxEnvelopeSize > 0 {
+		mu := mustUnderstand(true)
+		h = append(h, text{xml.Name{Space: NsWSMan, Local: "MaxEnvelopeSize"}, &mu, fmt.Sprint(c.MaxEnvelopeSize)})
+	}
+	if c.Locale != "" {
+		h = append(h, locale{MustUnderstand: mustUnderstand(false), Lang: c.Locale})
+	}
+	return h
+}
+
+func (c *Client) call(ctx context.Context, action, resourceURI string, sel Selectors, req, resp interface{}) error {
+	wsa := soap.Addressing{
+		Namespace: soap.NsWSA200408,
+		To:        c.SOAP.URL,
+		Action:    action,
+		MessageID: soap.NewMessageID(),
+		ReplyTo:   &soap.EndpointReference{Address: soap.NsWSA200408 + "/role/anonymous"},
+	}
+	header := append(wsa.Header(), c.header(resourceURI, sel)...)
+	return c.SOAP.Call(ctx, action, req, resp, soap.WithSOAPHeader(header...))
+}
+
+// Get retrieves the representation of the resource instance
+// identified by resourceURI and sel, decoding it into resp.
+func (c *Client) Get(ctx context.Context, resourceURI string, sel Selectors, resp interface{}) error {
+	return c.call(ctx, NsTransfer+"/Get", resourceURI, sel, nil, resp)
+}
+
+// Put replaces the representation of a resource instance with v, and
+// decodes the updated representation returned by the service into
+// resp, which may be nil.
+func (c *Client) Put(ctx context.Context, resourceURI string, sel Selectors, v, resp interface{}) error {
+	return c.call(ctx, NsTransfer+"/Put", resourceURI, sel, v, resp)
+}
+
+// Invoke invokes a method of a resource, such as the StartService
+// method of Win32_Service. req is the method's input, usually an
+// element named method+"_INPUT" in the resourceURI namespace, and
+// its output is decoded into resp.
+func (c *Client) Invoke(ctx context.Context, resourceURI, method string, sel Selectors, req, resp interface{}) error {
+	return c.call(ctx, resourceURI+"/"+method, resourceURI, sel, req, resp)
+}
+
+// EnumerateOptions holds the optional parameters of an enumeration.
+type EnumerateOptions struct {
+	// Filter and Dialect select the instances enumerated, for
+	// example with a WQL query.
+	Filter, Dialect string
+
+	// MaxElements is the maximum number of instances returned
+	// in each response. If zero, 1 is used.
+	MaxElements int
+}
+
+type enumerationOption struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
+}
+
+// Enumerate starts an enumeration of the instances of a resource.
+// The first instances are returned in the response to the Enumerate
+// request, as WS-Management's optimized enumerations allow.
+func (c *Client) Enumerate(ctx context.Context, resourceURI string, sel Selectors, opts *EnumerateOptions) (*soap.Enumerator, error) {
+	if opts == nil {
+		opts = new(EnumerateOptions)
+	}
+	max := opts.MaxElements
+	if max <= 0 {
+		max = 1
+	}
+	options := &soap.EnumerateOptions{
+		MaxElements: max,
+		Extensions: []interface{}{
+			enumerationOption{XMLName: xml.Name{Space: NsWSMan, Local: "OptimizeEnumeration"}},
+			enumerationOption{xml.Name{Space: NsWSMan, Local: "MaxElements"}, fmt.Sprint(max)},
+		},
+	}
+	if opts.Filter != "" {
+		options.Extensions = append(options.Extensions, filter{Dialect: opts.Dialect, Value: opts.Filter})
+	}
+	return soap.Enumerate(ctx, c.SOAP, options, soap.WithSOAPHeader(c.header(resourceURI, sel)...))
+}
+
+type filter struct {
+	XMLName xml.Name `xml:"http://schemas.dmtf.org/wbem/wsman/1/wsman.xsd Filter"`
+	Dialect string   `xml:"Dialect,attr,omitempty"`
+	Value   string   `xml:",chardata"`
+}
@@ -0,0 +1,113 @@
+package wsman
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"aqwari.net/exp/soap"
+)
+
+const winrmService = "http://schemas.microsoft.com/wbem/wsman/1/wmi/root/cimv2/Win32_Service"
+
+type service struct {
+	Name  string `xml:"Name"`
+	State string `xml:"State"`
+}
+
+func TestClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var msg struct {
+			Header struct {
+				Action           string `xml:"Action"`
+				ResourceURI      string `xml:"http://schemas.dmtf.org/wbem/wsman/1/wsman.xsd ResourceURI"`
+				OperationTimeout string `xml:"http://schemas.dmtf.org/wbem/wsman/1/wsman.xsd OperationTimeout"`
+				Selectors        []struct {
+					Name  string `xml:"Name,attr"`
+					Value string `xml:",chardata"`
+				} `xml:"http://schemas.dmtf.org/wbem/wsman/1/wsman.xsd SelectorSet>Selector"`
+			}
+		}
+		if err := xml.Unmarshal(data, &msg); err != nil {
+			t.Error(err)
+		}
+		if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/soap+xml") {
+			t.Errorf("Content-Type = %s", r.Header.Get("Content-Type"))
+		}
+		if msg.Header.ResourceURI != winrmService || msg.Header.OperationTimeout != "PT60.000S" {
+			t.Errorf("unexpected headers: %s", data)
+		}
+		var body string
+		switch msg.Header.Action {
+		case NsTransfer + "/Get":
+			if len(msg.Header.Selectors) != 1 || msg.Header.Selectors[0].Name != "Name" {
+				t.Errorf("unexpected selectors %+v", msg.Header.Selectors)
+			}
+			body = `<p:Win32_Service><p:Name>WinRM</p:Name><p:State>Running</p:State></p:Win32_Service>`
+		case winrmService + "/StopService":
+			body = `<p:StopService_OUTPUT><p:ReturnValue>0</p:ReturnValue></p:StopService_OUTPUT>`
+		case soap.NsWSEN + "/Enumerate":
+			if !strings.Contains(string(data), "OptimizeEnumeration") {
+				t.Errorf("enumeration not optimized: %s", data)
+			}
+			body = `<n:EnumerateResponse xmlns:n="` + soap.NsWSEN + `"><w:Items xmlns:w="` + NsWSMan + `">
+<p:Win32_Service><p:Name>Dnscache</p:Name></p:Win32_Service>
+<p:Win32_Service><p:Name>WinRM</p:Name></p:Win32_Service>
+</w:Items><w:EndOfSequence xmlns:w="` + NsWSMan + `"/></n:EnumerateResponse>`
+		default:
+			t.Errorf("unexpected action %s", msg.Header.Action)
+		}
+		w.Header().Set("Content-Type", "application/soap+xml; charset=utf-8")
+		fmt.Fprintf(w, `<s:Envelope xmlns:s="%s" xmlns:p="%s"><s:Body>%s</s:Body></s:Envelope>`,
+			soap.NsSoap12Env, winrmService, body)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	c := NewClient(srv.URL)
+	c.OperationTimeout = time.Minute
+
+	var svc service
+	if err := c.Get(ctx, winrmService, Selectors{"Name": "WinRM"}, &svc); err != nil {
+		t.Fatal(err)
+	}
+	if svc.Name != "WinRM" || svc.State != "Running" {
+		t.Errorf("got %+v", svc)
+	}
+
+	var out struct {
+		ReturnValue int `xml:"ReturnValue"`
+	}
+	in := struct {
+		XMLName xml.Name
+	}{xml.Name{Space: winrmService, Local: "StopService_INPUT"}}
+	if err := c.Invoke(ctx, winrmService, "StopService", Selectors{"Name": "WinRM"}, in, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := c.Enumerate(ctx, winrmService, nil, &EnumerateOptions{MaxElements: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for e.Next(ctx) {
+		var s service
+		if err := e.Decode(&s); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, s.Name)
+	}
+	if err := e.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(names, ","); got != "Dnscache,WinRM" {
+		t.Errorf("enumerated %s", got)
+	}
+}